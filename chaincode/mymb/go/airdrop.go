@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AirdropGrant 에어드랍 대상 한 명에게 지급할 포인트와 토큰
+// tokenNumber 가 비어 있으면 포인트만 지급한다
+type AirdropGrant struct {
+	NickName     string `json:"nickName"`
+	MymPoint     int64  `json:"mymPoint" metadata:",optional"`
+	TokenNumber  string `json:"tokenNumber" metadata:",optional"`
+	CategoryCode string `json:"categoryCode" metadata:",optional"`
+	FundingID    string `json:"fundingID" metadata:",optional"`
+	TicketID     string `json:"ticketID" metadata:",optional"`
+	TokenType    string `json:"tokenType" metadata:",optional"`
+	SellStage    string `json:"sellStage" metadata:",optional"`
+	ImageURL     string `json:"imageURL" metadata:",optional"`
+}
+
+// AirdropRecipient 캠페인에서 유저 한 명에 대한 지급 결과
+type AirdropRecipient struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	CampaignID    string    `json:"campaignID"`
+	NickName      string    `json:"nickName"`
+	Status        string    `json:"status"`
+	MymPoint      int64     `json:"mymPoint"`
+	TokenNumber   string    `json:"tokenNumber"`
+	ErrorCode     string    `json:"errorCode"`
+	ErrorMessage  string    `json:"errorMessage"`
+	TxID          string    `json:"txID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// AirdropCampaign 캠페인의 누적 지급 현황
+type AirdropCampaign struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	CampaignID    string    `json:"campaignID"`
+	Batches       int       `json:"batches"`
+	Granted       int       `json:"granted"`
+	Failed        int       `json:"failed"`
+	TotalMymPoint int64     `json:"totalMymPoint"`
+	TotalTokens   int       `json:"totalTokens"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// AirdropResult Airdrop 한 번의 배치 결과
+type AirdropResult struct {
+	CampaignID string             `json:"campaignID"`
+	Granted    int                `json:"granted"`
+	Failed     int                `json:"failed"`
+	Skipped    int                `json:"skipped"`
+	Results    []AirdropRecipient `json:"results"`
+	Campaign   AirdropCampaign    `json:"campaign"`
+}
+
+const (
+	airdropCampaignPrefix  = "airdropCampaign"
+	airdropRecipientPrefix = "airdropRecipient"
+
+	airdropGranted = "granted"
+	airdropFailed  = "failed"
+	airdropSkipped = "skipped"
+
+	// 트랜잭션 크기 제한을 넘지 않도록 한 번에 처리하는 최대 인원
+	maxAirdropBatch = 500
+
+	// 에어드랍을 실행할 수 있는 역할, 관리자도 실행할 수 있다
+	roleAirdrop = "airdrop"
+)
+
+// Airdrop 캠페인 단위로 여러 유저에게 포인트와 토큰을 지급하는 함수
+// 이미 지급된 유저는 건너뛰므로 같은 campaignID 로 다시 호출해도 중복 지급되지 않는다
+// continueOnError 가 true 이면 실패한 유저는 결과에 기록하고 나머지를 계속 처리하며, false 이면 배치 전체를 취소한다
+// 하루 동안 승인 없이 받은 합이 승인 정책의 LargePointGrant 에 이르는 유저는 APPROVAL_REQUIRED 로 실패한다
+func (c *TokenERC1155Contract) Airdrop(ctx contractapi.TransactionContextInterface, campaignID string, grants []AirdropGrant, continueOnError bool) (*AirdropResult, error) {
+
+	v := newValidator().
+		field("campaignID", campaignID).
+		check(len(grants) > 0, "grants", "required", "must not be empty").
+		check(len(grants) <= maxAirdropBatch, "grants", "maxItems", fmt.Sprintf("must contain at most %d recipients", maxAirdropBatch))
+	nickNames := make([]string, 0, len(grants))
+	tokenNumbers := []string{}
+	for i, grant := range grants {
+		nickNames = append(nickNames, grant.NickName)
+		v.nonNegative(fmt.Sprintf("grants[%d].mymPoint", i), grant.MymPoint).
+			check(grant.MymPoint > 0 || grant.TokenNumber != "", fmt.Sprintf("grants[%d]", i), "required", "must grant MymPoint or a token")
+		if grant.TokenNumber != "" {
+			tokenNumbers = append(tokenNumbers, grant.TokenNumber)
+			v.fieldAs("categoryCode", fmt.Sprintf("grants[%d].categoryCode", i), grant.CategoryCode).
+				fieldAs("fundingID", fmt.Sprintf("grants[%d].fundingID", i), grant.FundingID).
+				fieldAs("ticketID", fmt.Sprintf("grants[%d].ticketID", i), grant.TicketID).
+				fieldAs("tokenType", fmt.Sprintf("grants[%d].tokenType", i), grant.TokenType).
+				fieldAs("sellStage", fmt.Sprintf("grants[%d].sellStage", i), grant.SellStage).
+				fieldAs("imageURL", fmt.Sprintf("grants[%d].imageURL", i), grant.ImageURL)
+		}
+	}
+	// 같은 트랜잭션에서 한 유저나 토큰을 두 번 쓰면 앞의 쓰기가 보이지 않으므로 중복을 허용하지 않는다
+	v.list("nickName", "grants.nickName", nickNames).
+		list("tokenNumber", "grants.tokenNumber", tokenNumbers)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleAirdrop, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign, err := getAirdropCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		campaign = &AirdropCampaign{
+			SchemaVersion: airdropCampaignSchemaVersion,
+			CampaignID:    campaignID,
+		}
+	}
+
+	result := AirdropResult{
+		CampaignID: campaignID,
+		Results:    []AirdropRecipient{},
+	}
+
+	for _, grant := range grants {
+		previous, err := getAirdropRecipient(ctx, campaignID, grant.NickName)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil && previous.Status == airdropGranted {
+			skipped := *previous
+			skipped.Status = airdropSkipped
+			result.Skipped++
+			result.Results = append(result.Results, skipped)
+			continue
+		}
+
+		recipient := AirdropRecipient{
+			SchemaVersion: airdropRecipientSchemaVersion,
+			CampaignID:    campaignID,
+			NickName:      grant.NickName,
+			Status:        airdropGranted,
+			MymPoint:      grant.MymPoint,
+			TokenNumber:   grant.TokenNumber,
+			TxID:          ctx.GetStub().GetTxID(),
+			UpdatedTime:   now,
+		}
+
+		// 확인에서 실패한 유저만 건너뛸 수 있고, 쓰기 도중의 실패는 앞선 쓰기가 남으므로 배치 전체를 취소한다
+		user, token, window, err := c.checkAirdropGrant(ctx, grant, now)
+		if err == nil && campaign.TotalMymPoint > math.MaxInt64-grant.MymPoint {
+			err = newError(mymberr.InvalidArgument, params("field", "mymPoint", "campaignID", campaignID), "grant of %d MymPoint exceeds the MymPoint range of campaign %s", grant.MymPoint, campaignID)
+		}
+		if err != nil {
+			if !continueOnError {
+				return nil, err
+			}
+			coded, ok := mymberr.FromError(err)
+			if !ok {
+				coded = mymberr.New(mymberr.Internal, err.Error(), nil)
+			}
+			recipient.Status = airdropFailed
+			recipient.ErrorCode = string(coded.Code)
+			recipient.ErrorMessage = coded.Message
+			result.Failed++
+		} else {
+			if err := grantAirdrop(ctx, grant, user, token, window); err != nil {
+				return nil, err
+			}
+			result.Granted++
+			campaign.TotalMymPoint += grant.MymPoint
+			if grant.TokenNumber != "" {
+				campaign.TotalTokens++
+			}
+		}
+
+		if err := putAirdropRecipient(ctx, &recipient); err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, recipient)
+	}
+
+	campaign.Batches++
+	campaign.Granted += result.Granted
+	campaign.Failed += result.Failed
+	campaign.UpdatedTime = now
+	if err := putAirdropCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+	result.Campaign = *campaign
+
+	summaryBytes, err := json.Marshal(campaign)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal airdrop campaign")
+	}
+	if err := ctx.GetStub().SetEvent("Airdrop", summaryBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &result, nil
+}
+
+// GetAirdropCampaign 캠페인의 누적 지급 현황을 조회하는 함수
+func (c *TokenERC1155Contract) GetAirdropCampaign(ctx contractapi.TransactionContextInterface, campaignID string) (*AirdropCampaign, error) {
+
+	campaign, err := getAirdropCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, newError(mymberr.CampaignNotFound, params("campaignID", campaignID), "airdrop campaign %s does not exist", campaignID)
+	}
+	return campaign, nil
+}
+
+// GetAirdropRecipients 캠페인의 유저별 지급 결과를 조회하는 함수
+func (c *TokenERC1155Contract) GetAirdropRecipients(ctx contractapi.TransactionContextInterface, campaignID string) ([]AirdropRecipient, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(airdropRecipientPrefix, []string{campaignID})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	recipients := []AirdropRecipient{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var recipient AirdropRecipient
+		if err := unmarshalVersioned(airdropRecipientPrefix, queryResponse.Value, &recipient); err != nil {
+			return nil, wrapError(err, "failed to unmarshal airdrop recipient")
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// 유저 한 명에게 지급할 수 있는지 확인하고 지급할 유저, 새 토큰, 승인 없이 받은 MymPoint 누적을 반환하는 도우미 함수, 아무것도 쓰지 않는다
+func (c *TokenERC1155Contract) checkAirdropGrant(ctx contractapi.TransactionContextInterface, grant AirdropGrant, now time.Time) (*User, *Token1155, *PointGrantWindow, error) {
+
+	user, err := c.GetUser(ctx, grant.NickName)
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, nil, nil, newError(mymberr.UserNotFound, params("nickName", grant.NickName), "user %s does not exist", grant.NickName)
+	}
+	if err := checkNotFrozen(ctx, grant.NickName); err != nil {
+		return nil, nil, nil, err
+	}
+	// 큰 지급은 에어드랍 역할 하나로 할 수 없고 UpdateMymPoint 제안의 승인을 받아야 한다
+	window, err := checkLargePointGrant(ctx, grant.NickName, grant.MymPoint)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 더한 잔액이 int64 범위를 넘으면 음수가 되므로 미리 막는다
+	if user.MymPoint > math.MaxInt64-grant.MymPoint {
+		return nil, nil, nil, newError(mymberr.InvalidArgument, params("field", "mymPoint", "nickName", grant.NickName), "grant of %d MymPoint exceeds the MymPoint range of user %s", grant.MymPoint, grant.NickName)
+	}
+
+	var token *Token1155
+	if grant.TokenNumber != "" {
+		if err := checkCodesRegistered(ctx, grant.CategoryCode, grant.TokenType); err != nil {
+			return nil, nil, nil, err
+		}
+		exists, err := tokenExists(ctx, grant.TokenNumber)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if exists {
+			return nil, nil, nil, newError(mymberr.TokenAlreadyExists, params("tokenNumber", grant.TokenNumber), "token %s already exists", grant.TokenNumber)
+		}
+		token = &Token1155{
+			SchemaVersion:    tokenSchemaVersion,
+			TokenNumber:      grant.TokenNumber,
+			Owner:            grant.NickName,
+			CategoryCode:     grant.CategoryCode,
+			FundingID:        grant.FundingID,
+			TicketID:         grant.TicketID,
+			TokenType:        grant.TokenType,
+			SellStage:        grant.SellStage,
+			ImageURL:         grant.ImageURL,
+			TokenCreatedTime: now,
+		}
+		if err := checkKYCRequirement(ctx, token, grant.NickName, now); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return user, token, window, nil
+}
+
+// checkAirdropGrant 를 통과한 유저에게 포인트와 토큰을 지급하는 도우미 함수
+func grantAirdrop(ctx contractapi.TransactionContextInterface, grant AirdropGrant, user *User, token *Token1155, window *PointGrantWindow) error {
+	if err := putPointGrantWindow(ctx, window); err != nil {
+		return err
+	}
+	user.MymPoint += grant.MymPoint
+	if token != nil {
+		if err := putToken(ctx, token); err != nil {
+			return err
+		}
+		user.OwnedToken = append(user.OwnedToken, token.TokenNumber)
+		if err := updateHoldings(ctx, transferHolding(token, "", grant.NickName)); err != nil {
+			return err
+		}
+	}
+	return putUser(ctx, user)
+}
+
+// 캠페인 현황을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getAirdropCampaign(ctx contractapi.TransactionContextInterface, campaignID string) (*AirdropCampaign, error) {
+	campaignKey, err := ctx.GetStub().CreateCompositeKey(airdropCampaignPrefix, []string{campaignID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	campaignBytes, err := ctx.GetStub().GetState(campaignKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read airdrop campaign")
+	}
+	if campaignBytes == nil {
+		return nil, nil
+	}
+
+	var campaign AirdropCampaign
+	if err := unmarshalVersioned(airdropCampaignPrefix, campaignBytes, &campaign); err != nil {
+		return nil, wrapError(err, "failed to unmarshal airdrop campaign")
+	}
+	return &campaign, nil
+}
+
+func putAirdropCampaign(ctx contractapi.TransactionContextInterface, campaign *AirdropCampaign) error {
+	campaignKey, err := ctx.GetStub().CreateCompositeKey(airdropCampaignPrefix, []string{campaign.CampaignID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	campaignBytes, err := json.Marshal(campaign)
+	if err != nil {
+		return wrapError(err, "failed to marshal airdrop campaign")
+	}
+	if err := ctx.GetStub().PutState(campaignKey, campaignBytes); err != nil {
+		return wrapError(err, "failed to put state for airdrop campaign")
+	}
+	return nil
+}
+
+// 유저별 지급 결과를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getAirdropRecipient(ctx contractapi.TransactionContextInterface, campaignID string, nickName string) (*AirdropRecipient, error) {
+	recipientKey, err := ctx.GetStub().CreateCompositeKey(airdropRecipientPrefix, []string{campaignID, nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	recipientBytes, err := ctx.GetStub().GetState(recipientKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read airdrop recipient")
+	}
+	if recipientBytes == nil {
+		return nil, nil
+	}
+
+	var recipient AirdropRecipient
+	if err := unmarshalVersioned(airdropRecipientPrefix, recipientBytes, &recipient); err != nil {
+		return nil, wrapError(err, "failed to unmarshal airdrop recipient")
+	}
+	return &recipient, nil
+}
+
+func putAirdropRecipient(ctx contractapi.TransactionContextInterface, recipient *AirdropRecipient) error {
+	recipientKey, err := ctx.GetStub().CreateCompositeKey(airdropRecipientPrefix, []string{recipient.CampaignID, recipient.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	recipientBytes, err := json.Marshal(recipient)
+	if err != nil {
+		return wrapError(err, "failed to marshal airdrop recipient")
+	}
+	if err := ctx.GetStub().PutState(recipientKey, recipientBytes); err != nil {
+		return wrapError(err, "failed to put state for airdrop recipient")
+	}
+	return nil
+}
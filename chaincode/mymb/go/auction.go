@@ -0,0 +1,760 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TokenAuction 토큰 봉인 입찰 경매 정보
+type TokenAuction struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	AuctionID     string             `json:"auctionID"`
+	TokenNumber   string             `json:"tokenNumber"`
+	Seller        string             `json:"seller"`
+	Creator       string             `json:"creator"`
+	Orgs          []string           `json:"organizations"`
+	PrivateBids   map[string]BidHash `json:"privateBids"`
+	RevealedBids  map[string]FullBid `json:"revealedBids"`
+	Winner        string             `json:"winner"`
+	Price         int64              `json:"price"`
+	Status        string             `json:"status"`
+	CreatedTime   time.Time          `json:"createdTime"`
+}
+
+// FullBid 공개된 입찰 정보 (가격 단위는 MymPoint)
+type FullBid struct {
+	Price  int64  `json:"price"`
+	Org    string `json:"org"`
+	Bidder string `json:"bidder"`
+}
+
+// BidHash 비공개 입찰의 해시 정보
+type BidHash struct {
+	Org  string `json:"org"`
+	Hash string `json:"hash"`
+}
+
+const (
+	auctionPrefix      = "auction"
+	auctionTokenPrefix = "auctionToken"
+	bidPrefix          = "bid"
+
+	auctionOpen      = "open"
+	auctionClosed    = "closed"
+	auctionEnded     = "ended"
+	auctionCancelled = "cancelled"
+)
+
+// CreateAuction 토큰을 경매에 올리는 함수, 경매가 끝날 때까지 토큰 전송이 막힌다
+func (c *TokenERC1155Contract) CreateAuction(ctx contractapi.TransactionContextInterface, auctionID string, tokenNumber string) (*TokenAuction, error) {
+
+	if err := newValidator().field("auctionID", auctionID).field("tokenNumber", tokenNumber).err(); err != nil {
+		return nil, err
+	}
+
+	existing, err := getAuction(ctx, auctionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.AuctionExists, params("auctionID", auctionID), "auction %s already exists", auctionID)
+	}
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return nil, wrapError(err, "failed to get token")
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, wrapError(err, "failed to get client identity")
+	}
+
+	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, wrapError(err, "failed to get client MSP ID")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 전송할 수 없는 토큰은 경매에 올릴 수 없다
+	if err := checkTokenTransferable(ctx, token, now); err != nil {
+		return nil, err
+	}
+
+	if err := checkNotFrozen(ctx, token.Owner); err != nil {
+		return nil, err
+	}
+	if err := checkNotEnterprisePool(ctx, token.Owner); err != nil {
+		return nil, err
+	}
+
+	auction := TokenAuction{
+		SchemaVersion: auctionSchemaVersion,
+		AuctionID:     auctionID,
+		TokenNumber:   tokenNumber,
+		Seller:        token.Owner,
+		Creator:       clientID,
+		Orgs:          []string{clientOrgID},
+		PrivateBids:   map[string]BidHash{},
+		RevealedBids:  map[string]FullBid{},
+		Status:        auctionOpen,
+		CreatedTime:   now,
+	}
+
+	if err := putAuction(ctx, &auction); err != nil {
+		return nil, err
+	}
+
+	tokenAuctionKey, err := ctx.GetStub().CreateCompositeKey(auctionTokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(tokenAuctionKey, []byte(auctionID)); err != nil {
+		return nil, wrapError(err, "failed to lock token %s for auction", tokenNumber)
+	}
+
+	// 판매자 조직을 경매 키의 보증 조직으로 지정
+	if err := setAuctionEndorsement(ctx, auctionID, clientOrgID, false); err != nil {
+		return nil, wrapError(err, "failed setting state based endorsement for auction")
+	}
+
+	return &auction, nil
+}
+
+// Bid 입찰 내용을 transient 의 "bid" 로 받아 입찰자 조직의 implicit 컬렉션에 저장하는 함수
+// 반환되는 트랜잭션 ID 로 SubmitBid, RevealBid 에서 입찰을 식별한다
+func (c *TokenERC1155Contract) Bid(ctx contractapi.TransactionContextInterface, auctionID string) (string, error) {
+
+	if err := newValidator().field("auctionID", auctionID).err(); err != nil {
+		return "", err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", wrapError(err, "error getting transient")
+	}
+
+	bidJSON, ok := transientMap["bid"]
+	if !ok {
+		return "", newError(mymberr.InvalidArgument, params("field", "bid"), "bid key not found in the transient map")
+	}
+
+	collection, err := getImplicitCollectionName(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// 입찰은 입찰자 조직의 피어에만 저장할 수 있다
+	if err := verifyClientOrgMatchesPeerOrg(ctx); err != nil {
+		return "", wrapError(err, "cannot store bid on this peer")
+	}
+
+	txID := ctx.GetStub().GetTxID()
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(bidPrefix, []string{auctionID, txID})
+	if err != nil {
+		return "", wrapError(err, "failed to create composite key")
+	}
+
+	if err := ctx.GetStub().PutPrivateData(collection, bidKey, bidJSON); err != nil {
+		return "", wrapError(err, "failed to put bid into collection")
+	}
+
+	return txID, nil
+}
+
+// SubmitBid 비공개 컬렉션에 저장된 입찰의 해시를 경매에 등록하는 함수
+func (c *TokenERC1155Contract) SubmitBid(ctx contractapi.TransactionContextInterface, auctionID string, txID string) error {
+
+	if err := newValidator().field("auctionID", auctionID).field("txID", txID).err(); err != nil {
+		return err
+	}
+
+	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return wrapError(err, "failed to get client MSP ID")
+	}
+
+	auction, err := readAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.Status != auctionOpen {
+		return newError(mymberr.InvalidState, params("auctionID", auctionID, "status", auction.Status), "cannot join closed or ended auction %s", auctionID)
+	}
+
+	collection, err := getImplicitCollectionName(ctx)
+	if err != nil {
+		return err
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(bidPrefix, []string{auctionID, txID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+
+	bidHash, err := ctx.GetStub().GetPrivateDataHash(collection, bidKey)
+	if err != nil {
+		return wrapError(err, "failed to read bid hash from collection")
+	}
+	if bidHash == nil {
+		return newError(mymberr.BidNotFound, params("bidKey", bidKey), "bid hash does not exist: %s", bidKey)
+	}
+
+	auction.PrivateBids[bidKey] = BidHash{
+		Org:  clientOrgID,
+		Hash: fmt.Sprintf("%x", bidHash),
+	}
+
+	// 새로운 입찰 조직은 경매 키의 보증 조직으로 추가
+	if !contains(auction.Orgs, clientOrgID) {
+		auction.Orgs = append(auction.Orgs, clientOrgID)
+
+		if err := setAuctionEndorsement(ctx, auctionID, clientOrgID, true); err != nil {
+			return wrapError(err, "failed setting state based endorsement for new organization")
+		}
+	}
+
+	return putAuction(ctx, auction)
+}
+
+// RevealBid 경매가 닫힌 뒤 입찰자가 입찰 내용을 공개하는 함수
+func (c *TokenERC1155Contract) RevealBid(ctx contractapi.TransactionContextInterface, auctionID string, txID string) error {
+
+	if err := newValidator().field("auctionID", auctionID).field("txID", txID).err(); err != nil {
+		return err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return wrapError(err, "error getting transient")
+	}
+
+	transientBidJSON, ok := transientMap["bid"]
+	if !ok {
+		return newError(mymberr.InvalidArgument, params("field", "bid"), "bid key not found in the transient map")
+	}
+
+	collection, err := getImplicitCollectionName(ctx)
+	if err != nil {
+		return err
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(bidPrefix, []string{auctionID, txID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+
+	bidHash, err := ctx.GetStub().GetPrivateDataHash(collection, bidKey)
+	if err != nil {
+		return wrapError(err, "failed to read bid hash from collection")
+	}
+	if bidHash == nil {
+		return newError(mymberr.BidNotFound, params("bidKey", bidKey), "bid hash does not exist: %s", bidKey)
+	}
+
+	auction, err := readAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.Status != auctionClosed {
+		return newError(mymberr.InvalidState, params("auctionID", auctionID, "status", auction.Status), "cannot reveal bid for open or ended auction %s", auctionID)
+	}
+
+	// 공개된 입찰의 해시가 컬렉션에 저장된 해시와 같은지 확인
+	calculatedHash := sha256.Sum256(transientBidJSON)
+	if !bytes.Equal(calculatedHash[:], bidHash) {
+		return newError(mymberr.BidMismatch, nil, "hash %x for bid JSON %s does not match hash in collection: %x", calculatedHash, transientBidJSON, bidHash)
+	}
+
+	// 경매에 등록된 해시와도 같은지 확인 (등록 이후 입찰이 바뀌지 않았는지)
+	privateBid, ok := auction.PrivateBids[bidKey]
+	if !ok {
+		return newError(mymberr.BidNotFound, params("bidKey", bidKey, "auctionID", auctionID), "bid %s was not submitted to auction %s", bidKey, auctionID)
+	}
+	if privateBid.Hash != fmt.Sprintf("%x", bidHash) {
+		return newError(mymberr.BidMismatch, params("bidKey", bidKey), "hash %s for bid %s does not match hash in auction, bidder must have changed bid", privateBid.Hash, bidKey)
+	}
+
+	var bid FullBid
+	if err := json.Unmarshal(transientBidJSON, &bid); err != nil {
+		return wrapError(err, "failed to unmarshal bid")
+	}
+
+	err = newValidator().
+		positive("bid.price", bid.Price).
+		fieldAs("nickName", "bid.bidder", bid.Bidder).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if bid.Bidder == auction.Seller {
+		return newError(mymberr.InvalidArgument, params("field", "bidder", "nickName", auction.Seller), "seller %s cannot bid on own auction", auction.Seller)
+	}
+
+	bidder, err := c.GetUser(ctx, bid.Bidder)
+	if err != nil {
+		return wrapError(err, "failed to get bidder information")
+	}
+	if bidder.UserId == "" {
+		return newError(mymberr.UserNotFound, params("nickName", bid.Bidder), "bidder %s does not exist", bid.Bidder)
+	}
+
+	if err := checkNotFrozen(ctx, bid.Bidder); err != nil {
+		return err
+	}
+	if err := checkNotEnterprisePool(ctx, bid.Bidder); err != nil {
+		return err
+	}
+
+	auction.RevealedBids[bidKey] = bid
+
+	return putAuction(ctx, auction)
+}
+
+// CloseAuction 경매 입찰을 마감하고 입찰 공개 단계로 넘기는 함수
+func (c *TokenERC1155Contract) CloseAuction(ctx contractapi.TransactionContextInterface, auctionID string) error {
+
+	if err := newValidator().field("auctionID", auctionID).err(); err != nil {
+		return err
+	}
+
+	auction, err := readAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyAuctionCreator(ctx, auction); err != nil {
+		return err
+	}
+
+	if auction.Status != auctionOpen {
+		return newError(mymberr.InvalidState, params("auctionID", auctionID, "status", auction.Status), "cannot close auction %s that is not open", auctionID)
+	}
+
+	auction.Status = auctionClosed
+
+	return putAuction(ctx, auction)
+}
+
+// EndAuction 경매를 종료하고 낙찰자를 정하는 함수
+// 낙찰가만큼 낙찰자의 MymPoint 가 판매자에게 이동하고 토큰은 낙찰자에게 전송된다
+func (c *TokenERC1155Contract) EndAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*TokenAuction, error) {
+
+	if err := newValidator().field("auctionID", auctionID).err(); err != nil {
+		return nil, err
+	}
+
+	auction, err := readAuction(ctx, auctionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyAuctionCreator(ctx, auction); err != nil {
+		return nil, err
+	}
+
+	if auction.Status != auctionClosed {
+		return nil, newError(mymberr.InvalidState, params("auctionID", auctionID, "status", auction.Status), "can only end a closed auction")
+	}
+
+	if len(auction.RevealedBids) == 0 {
+		return nil, newError(mymberr.InvalidState, params("auctionID", auctionID), "no bids have been revealed, cannot end auction %s", auctionID)
+	}
+
+	// 가격이 높은 순으로 정렬하고, 같은 가격이면 입찰 키 순서로 정해 모든 피어에서 같은 결과를 낸다
+	bidKeys := make([]string, 0, len(auction.RevealedBids))
+	for bidKey := range auction.RevealedBids {
+		bidKeys = append(bidKeys, bidKey)
+	}
+	sort.Slice(bidKeys, func(i, j int) bool {
+		bi, bj := auction.RevealedBids[bidKeys[i]], auction.RevealedBids[bidKeys[j]]
+		if bi.Price != bj.Price {
+			return bi.Price > bj.Price
+		}
+		return bidKeys[i] < bidKeys[j]
+	})
+
+	token, err := c.GetToken(ctx, auction.TokenNumber)
+	if err != nil {
+		return nil, wrapError(err, "failed to get token")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// MymPoint 가 부족하거나 동결되었거나 KYC 단계가 모자란 입찰자는 건너뛴다
+	var winner *User
+	for _, bidKey := range bidKeys {
+		bid := auction.RevealedBids[bidKey]
+		bidder, err := c.GetUser(ctx, bid.Bidder)
+		if err != nil {
+			return nil, wrapError(err, "failed to get bidder information")
+		}
+		if bidder.UserId == "" || bidder.MymPoint < bid.Price {
+			continue
+		}
+		frozen, err := isFrozen(ctx, bid.Bidder)
+		if err != nil {
+			return nil, err
+		}
+		if frozen {
+			continue
+		}
+		if err := checkKYCRequirement(ctx, token, bid.Bidder, now); err != nil {
+			if mymberr.Is(err, mymberr.KYCRequired) {
+				continue
+			}
+			return nil, err
+		}
+		winner = bidder
+		auction.Winner = bid.Bidder
+		auction.Price = bid.Price
+		break
+	}
+
+	if winner == nil {
+		return nil, newError(mymberr.InsufficientPoints, params("auctionID", auctionID), "no revealed bidder has enough MymPoint, cannot end auction %s", auctionID)
+	}
+
+	// 공개되지 않은 더 높은 입찰이 있으면 종료할 수 없다
+	if err := checkUnrevealedBids(ctx, auction); err != nil {
+		return nil, wrapError(err, "cannot end auction")
+	}
+
+	seller, err := c.GetUser(ctx, auction.Seller)
+	if err != nil {
+		return nil, wrapError(err, "failed to get seller information")
+	}
+	if seller.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", auction.Seller), "seller %s does not exist", auction.Seller)
+	}
+	if err := checkNotFrozen(ctx, seller.NickName); err != nil {
+		return nil, err
+	}
+	if token.Owner != seller.NickName || !contains(seller.OwnedToken, token.TokenNumber) {
+		return nil, newError(mymberr.NotOwner, params("nickName", seller.NickName, "tokenNumber", token.TokenNumber), "seller %s no longer owns token %s", seller.NickName, token.TokenNumber)
+	}
+
+	if err := checkTransferPolicies(ctx, token, now); err != nil {
+		return nil, err
+	}
+
+	// 한 트랜잭션 안에서는 쓰기 결과를 다시 읽을 수 없으므로 유저마다 한 번씩만 저장한다
+	seller.MymPoint += auction.Price
+	seller.OwnedToken = removeToken(seller.OwnedToken, token.TokenNumber)
+	winner.MymPoint -= auction.Price
+	winner.OwnedToken = append(winner.OwnedToken, token.TokenNumber)
+	if err := updateHoldings(ctx, transferHolding(token, seller.NickName, winner.NickName)); err != nil {
+		return nil, err
+	}
+	recordTokenTransfer(token, winner.NickName, now)
+
+	if err := putUser(ctx, seller); err != nil {
+		return nil, err
+	}
+	if err := putUser(ctx, winner); err != nil {
+		return nil, err
+	}
+	if err := putToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	tokenAuctionKey, err := ctx.GetStub().CreateCompositeKey(auctionTokenPrefix, []string{auction.TokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(tokenAuctionKey); err != nil {
+		return nil, wrapError(err, "failed to unlock token %s", auction.TokenNumber)
+	}
+
+	auction.Status = auctionEnded
+	if err := putAuction(ctx, auction); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Auction %s ended: token %s sold to %s for %d MymPoint\n", auctionID, token.TokenNumber, winner.NickName, auction.Price)
+	return auction, nil
+}
+
+// CancelAuction 팔리지 않은 경매를 취소하고 토큰 잠금을 푸는 함수, 경매를 만든 클라이언트나 관리자만 호출할 수 있다
+// 공개된 입찰이 없거나 MymPoint 가 충분한 입찰자가 없어 EndAuction 이 실패한 경매의 토큰을 되돌리는 데 쓴다
+// 입찰은 종료할 때만 정산되므로 돌려줄 MymPoint 는 없다
+func (c *TokenERC1155Contract) CancelAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*TokenAuction, error) {
+
+	if err := newValidator().field("auctionID", auctionID).err(); err != nil {
+		return nil, err
+	}
+
+	auction, err := readAuction(ctx, auctionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyAuctionCreator(ctx, auction); err != nil {
+		if assertRole(ctx, roleAdmin) != nil {
+			return nil, err
+		}
+	}
+
+	if auction.Status != auctionOpen && auction.Status != auctionClosed {
+		return nil, newError(mymberr.InvalidState, params("auctionID", auctionID, "status", auction.Status), "cannot cancel auction %s that is %s", auctionID, auction.Status)
+	}
+
+	tokenAuctionKey, err := ctx.GetStub().CreateCompositeKey(auctionTokenPrefix, []string{auction.TokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(tokenAuctionKey); err != nil {
+		return nil, wrapError(err, "failed to unlock token %s", auction.TokenNumber)
+	}
+
+	auction.Status = auctionCancelled
+	if err := putAuction(ctx, auction); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Auction %s cancelled: token %s unlocked\n", auctionID, auction.TokenNumber)
+	return auction, nil
+}
+
+// QueryAuction 경매 정보를 조회하는 함수
+func (c *TokenERC1155Contract) QueryAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*TokenAuction, error) {
+	return readAuction(ctx, auctionID)
+}
+
+// QueryBid 입찰자 조직의 컬렉션에 저장된 본인 입찰을 조회하는 함수
+func (c *TokenERC1155Contract) QueryBid(ctx contractapi.TransactionContextInterface, auctionID string, txID string) (*FullBid, error) {
+
+	if err := verifyClientOrgMatchesPeerOrg(ctx); err != nil {
+		return nil, err
+	}
+
+	collection, err := getImplicitCollectionName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(bidPrefix, []string{auctionID, txID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+
+	bidJSON, err := ctx.GetStub().GetPrivateData(collection, bidKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to get bid %s", bidKey)
+	}
+	if bidJSON == nil {
+		return nil, newError(mymberr.BidNotFound, params("bidKey", bidKey), "bid %s does not exist", bidKey)
+	}
+
+	var bid FullBid
+	if err := json.Unmarshal(bidJSON, &bid); err != nil {
+		return nil, wrapError(err, "failed to unmarshal bid")
+	}
+	return &bid, nil
+}
+
+// 경매 정보를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*TokenAuction, error) {
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(auctionPrefix, []string{auctionID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+
+	auctionBytes, err := ctx.GetStub().GetState(auctionKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to get auction %s", auctionID)
+	}
+	if auctionBytes == nil {
+		return nil, nil
+	}
+
+	var auction TokenAuction
+	if err := unmarshalVersioned(auctionPrefix, auctionBytes, &auction); err != nil {
+		return nil, wrapError(err, "failed to unmarshal auction")
+	}
+	if auction.PrivateBids == nil {
+		auction.PrivateBids = map[string]BidHash{}
+	}
+	if auction.RevealedBids == nil {
+		auction.RevealedBids = map[string]FullBid{}
+	}
+	return &auction, nil
+}
+
+// 경매 정보를 조회하고 없으면 에러를 반환하는 도우미 함수
+func readAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*TokenAuction, error) {
+	auction, err := getAuction(ctx, auctionID)
+	if err != nil {
+		return nil, err
+	}
+	if auction == nil {
+		return nil, newError(mymberr.AuctionNotFound, params("auctionID", auctionID), "auction %s does not exist", auctionID)
+	}
+	return auction, nil
+}
+
+// 경매 정보를 저장하는 도우미 함수
+func putAuction(ctx contractapi.TransactionContextInterface, auction *TokenAuction) error {
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(auctionPrefix, []string{auction.AuctionID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	auctionBytes, err := json.Marshal(auction)
+	if err != nil {
+		return wrapError(err, "failed to marshal auction")
+	}
+	if err := ctx.GetStub().PutState(auctionKey, auctionBytes); err != nil {
+		return wrapError(err, "failed to put state for auction %s", auction.AuctionID)
+	}
+	return nil
+}
+
+// 토큰이 진행 중인 경매에 올라가 있으면 경매 ID 를 반환하는 도우미 함수
+func getTokenAuctionID(ctx contractapi.TransactionContextInterface, tokenNumber string) (string, error) {
+	tokenAuctionKey, err := ctx.GetStub().CreateCompositeKey(auctionTokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return "", wrapError(err, "failed to create composite key")
+	}
+	auctionID, err := ctx.GetStub().GetState(tokenAuctionKey)
+	if err != nil {
+		return "", wrapError(err, "failed to read auction lock for token %s", tokenNumber)
+	}
+	return string(auctionID), nil
+}
+
+// 경매를 만든 클라이언트만 마감, 종료할 수 있다
+func verifyAuctionCreator(ctx contractapi.TransactionContextInterface, auction *TokenAuction) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return wrapError(err, "failed to get client identity")
+	}
+	if clientID != auction.Creator {
+		return newError(mymberr.Unauthorized, params("auctionID", auction.AuctionID), "auction %s can only be closed or ended by its creator", auction.AuctionID)
+	}
+	return nil
+}
+
+// 낙찰가보다 높은 공개되지 않은 입찰이 있는지 확인하는 도우미 함수
+// 다른 조직의 입찰은 내용을 읽을 수 없으므로 해시가 있는지만 확인한다
+func checkUnrevealedBids(ctx contractapi.TransactionContextInterface, auction *TokenAuction) error {
+
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return wrapError(err, "failed getting the peer's MSPID")
+	}
+
+	for bidKey, privateBid := range auction.PrivateBids {
+		if _, revealed := auction.RevealedBids[bidKey]; revealed {
+			continue
+		}
+
+		collection := "_implicit_org_" + privateBid.Org
+
+		if privateBid.Org != peerMSPID {
+			hash, err := ctx.GetStub().GetPrivateDataHash(collection, bidKey)
+			if err != nil {
+				return wrapError(err, "failed to read bid hash from collection")
+			}
+			if hash == nil {
+				return newError(mymberr.BidNotFound, params("bidKey", bidKey), "bid hash does not exist: %s", bidKey)
+			}
+			continue
+		}
+
+		bidJSON, err := ctx.GetStub().GetPrivateData(collection, bidKey)
+		if err != nil {
+			return wrapError(err, "failed to get bid %s", bidKey)
+		}
+		if bidJSON == nil {
+			return newError(mymberr.BidNotFound, params("bidKey", bidKey), "bid %s does not exist", bidKey)
+		}
+
+		var bid FullBid
+		if err := json.Unmarshal(bidJSON, &bid); err != nil {
+			return wrapError(err, "failed to unmarshal bid")
+		}
+		if bid.Price > auction.Price {
+			return newError(mymberr.InvalidState, params("bidKey", bidKey), "bidder has an unrevealed higher bid %s", bidKey)
+		}
+	}
+
+	return nil
+}
+
+// 경매 키의 보증 정책에 조직을 지정하거나 추가하는 도우미 함수
+func setAuctionEndorsement(ctx contractapi.TransactionContextInterface, auctionID string, orgToEndorse string, extend bool) error {
+
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(auctionPrefix, []string{auctionID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+
+	var current []byte
+	if extend {
+		current, err = ctx.GetStub().GetStateValidationParameter(auctionKey)
+		if err != nil {
+			return wrapError(err, "failed to get validation parameter of auction")
+		}
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(current)
+	if err != nil {
+		return wrapError(err, "failed to create endorsement policy")
+	}
+	if err := endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgToEndorse); err != nil {
+		return wrapError(err, "failed to add org to endorsement policy")
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return wrapError(err, "failed to create endorsement policy bytes from org")
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(auctionKey, policy); err != nil {
+		return wrapError(err, "failed to set validation parameter on auction")
+	}
+	return nil
+}
+
+// 요청한 클라이언트 조직의 implicit 컬렉션 이름을 반환하는 도우미 함수
+func getImplicitCollectionName(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", wrapError(err, "failed to get verified MSPID")
+	}
+	return "_implicit_org_" + clientMSPID, nil
+}
+
+// 클라이언트 조직과 피어 조직이 같은지 확인하는 도우미 함수
+func verifyClientOrgMatchesPeerOrg(ctx contractapi.TransactionContextInterface) error {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return wrapError(err, "failed getting the client's MSPID")
+	}
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return wrapError(err, "failed getting the peer's MSPID")
+	}
+	if clientMSPID != peerMSPID {
+		return newError(mymberr.Unauthorized, params("clientMSPID", clientMSPID, "peerMSPID", peerMSPID), "client from org %s is not authorized to read or write private data from an org %s peer", clientMSPID, peerMSPID)
+	}
+	return nil
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BridgeConfig MymPoint 와 ERC-20 의 교환 비율과 브리지 운영 계정, ERC-20 1 개가 PointsPerToken MymPoint
+// OperatorAccount 는 token-erc-20 에서 발행, 소각할 수 있는 유일한 클라이언트이며 브리지 준비금을 보관한다
+type BridgeConfig struct {
+	SchemaVersion   int       `json:"schemaVersion"`
+	PointsPerToken  int64     `json:"pointsPerToken"`
+	OperatorAccount string    `json:"operatorAccount"`
+	UpdatedTime     time.Time `json:"updatedTime"`
+}
+
+// BridgeEscrow 브리지에 묶인 MymPoint, 유저에게 나간 ERC-20, 운영 계정에 남은 준비금의 합계
+type BridgeEscrow struct {
+	SchemaVersion     int       `json:"schemaVersion"`
+	EscrowPoints      int64     `json:"escrowPoints"`
+	OutstandingTokens int64     `json:"outstandingTokens"`
+	ReserveTokens     int64     `json:"reserveTokens"`
+	Locks             int       `json:"locks"`
+	Unlocks           int       `json:"unlocks"`
+	Mints             int       `json:"mints"`
+	Burns             int       `json:"burns"`
+	UpdatedTime       time.Time `json:"updatedTime"`
+}
+
+// BridgeRequest Lock, Unlock, 준비금 발행, 소각 요청 기록, 같은 requestID 로 다시 요청하면 이 기록을 그대로 돌려준다
+type BridgeRequest struct {
+	SchemaVersion  int       `json:"schemaVersion"`
+	RequestID      string    `json:"requestID"`
+	Direction      string    `json:"direction"`
+	NickName       string    `json:"nickName"`
+	AccountID      string    `json:"accountID"`
+	Points         int64     `json:"points"`
+	Tokens         int64     `json:"tokens"`
+	PointsPerToken int64     `json:"pointsPerToken"`
+	TxID           string    `json:"txID"`
+	RequestedTime  time.Time `json:"requestedTime"`
+}
+
+// BridgeReconciliation 요청 기록을 다시 더한 값, token-erc-20 의 운영 계정 잔액과 에스크로 합계를 비교한 결과
+type BridgeReconciliation struct {
+	Escrow              BridgeEscrow `json:"escrow"`
+	RequestPoints       int64        `json:"requestPoints"`
+	RequestTokens       int64        `json:"requestTokens"`
+	RequestReserve      int64        `json:"requestReserve"`
+	RequestLocks        int          `json:"requestLocks"`
+	RequestUnlocks      int          `json:"requestUnlocks"`
+	RequestMints        int          `json:"requestMints"`
+	RequestBurns        int          `json:"requestBurns"`
+	ERC20ReserveBalance int64        `json:"erc20ReserveBalance"`
+	Balanced            bool         `json:"balanced"`
+}
+
+const (
+	bridgeConfigPrefix  = "bridgeConfig"
+	bridgeEscrowPrefix  = "bridgeEscrow"
+	bridgeRequestPrefix = "bridgeRequest"
+
+	bridgeLock   = "lock"
+	bridgeUnlock = "unlock"
+	bridgeMint   = "mint"
+	bridgeBurn   = "burn"
+)
+
+// SetBridgeConfig 교환 비율과 운영 계정을 설정하는 함수, operatorAccount 는 운영 클라이언트의 token-erc-20 ClientAccountID
+// 유저에게 나간 ERC-20 이 있는 동안에는 비율을 바꿀 수 없다 (먼저 잠근 MymPoint 가 바뀐 비율로 빠져나가지 않게 한다)
+func (c *TokenERC1155Contract) SetBridgeConfig(ctx contractapi.TransactionContextInterface, pointsPerToken int64, operatorAccount string) (*BridgeConfig, error) {
+
+	err := newValidator().
+		positive("pointsPerToken", pointsPerToken).
+		fieldAs("accountID", "operatorAccount", operatorAccount).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	existing, err := getBridgeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := getBridgeEscrow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.PointsPerToken != pointsPerToken && escrow.OutstandingTokens > 0 {
+		return nil, newError(mymberr.InvalidState, params("outstandingTokens", strconv.FormatInt(escrow.OutstandingTokens, 10)),
+			"bridge rate cannot change while %d tokens are outstanding", escrow.OutstandingTokens)
+	}
+	// 준비금은 운영 계정의 잔액이므로 준비금이 남아 있으면 계정을 바꿀 수 없다
+	if existing != nil && existing.OperatorAccount != operatorAccount && escrow.ReserveTokens > 0 {
+		return nil, newError(mymberr.InvalidState, params("reserveTokens", strconv.FormatInt(escrow.ReserveTokens, 10)),
+			"bridge operator cannot change while %d reserve tokens are held", escrow.ReserveTokens)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := BridgeConfig{SchemaVersion: bridgeConfigSchemaVersion, PointsPerToken: pointsPerToken, OperatorAccount: operatorAccount, UpdatedTime: now}
+	configKey, err := ctx.GetStub().CreateCompositeKey(bridgeConfigPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal bridge config")
+	}
+	if err := ctx.GetStub().PutState(configKey, configBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for bridge config")
+	}
+	if err := ctx.GetStub().SetEvent("SetBridgeConfig", configBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+	return &config, nil
+}
+
+// GetBridgeConfig 교환 비율과 운영 계정을 조회하는 함수
+func (c *TokenERC1155Contract) GetBridgeConfig(ctx contractapi.TransactionContextInterface) (*BridgeConfig, error) {
+	config, err := getBridgeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, newError(mymberr.InvalidState, nil, "bridge is not configured")
+	}
+	return config, nil
+}
+
+// MintBridgeReserve 운영 계정에 ERC-20 준비금을 발행하는 함수, 운영 클라이언트만 호출할 수 있다
+// token-erc-20 의 Mint 는 호출한 클라이언트에게 발행하므로 발행 권한은 운영 계정에만 주어야 한다
+func (c *TokenERC1155Contract) MintBridgeReserve(ctx contractapi.TransactionContextInterface, requestID string, tokens int64) (*BridgeRequest, error) {
+	return c.changeBridgeReserve(ctx, requestID, bridgeMint, tokens)
+}
+
+// BurnBridgeReserve 운영 계정의 ERC-20 준비금을 소각하는 함수, 운영 클라이언트만 호출할 수 있다
+func (c *TokenERC1155Contract) BurnBridgeReserve(ctx contractapi.TransactionContextInterface, requestID string, tokens int64) (*BridgeRequest, error) {
+	return c.changeBridgeReserve(ctx, requestID, bridgeBurn, tokens)
+}
+
+// LockMymPoint 유저의 MymPoint 를 에스크로에 묶고 같은 가치의 ERC-20 을 준비금에서 유저 계정으로 보내는 함수
+// 운영 클라이언트가 제출하며, 한 트랜잭션에서는 방금 쓴 잔액을 다시 읽을 수 없어 발행은 MintBridgeReserve 로 미리 해 둔다
+func (c *TokenERC1155Contract) LockMymPoint(ctx contractapi.TransactionContextInterface, requestID string, nickName string, points int64) (*BridgeRequest, error) {
+
+	err := newValidator().
+		field("requestID", requestID).
+		field("nickName", nickName).
+		positive("points", points).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	// 비율이 바뀐 뒤의 재시도도 기존 기록을 돌려주도록 비율을 쓰기 전에 확인한다
+	existing, err := getBridgeRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return sameBridgeRequest(existing, bridgeLock, nickName, existing.Points == points)
+	}
+
+	config, err := c.checkBridgeOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if points%config.PointsPerToken != 0 {
+		return nil, newError(mymberr.InvalidArgument, params("field", "points"), "points must be a multiple of %d", config.PointsPerToken)
+	}
+	request := BridgeRequest{
+		Direction: bridgeLock,
+		NickName:  nickName,
+		Points:    points,
+		Tokens:    points / config.PointsPerToken,
+	}
+
+	account, user, err := c.checkBridgeAccount(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotEnterprisePool(ctx, nickName); err != nil {
+		return nil, err
+	}
+	if user.MymPoint < points {
+		return nil, newError(mymberr.InsufficientPoints, params("nickName", nickName, "mymPoint", strconv.FormatInt(user.MymPoint, 10)),
+			"%s has %d MymPoint, cannot lock %d", nickName, user.MymPoint, points)
+	}
+
+	erc20, err := c.GetERC20Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := getBridgeEscrow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.ReserveTokens < request.Tokens {
+		return nil, newError(mymberr.InvalidState, params("reserveTokens", strconv.FormatInt(escrow.ReserveTokens, 10)),
+			"bridge reserve of %d tokens cannot cover %d tokens, mint more with MintBridgeReserve", escrow.ReserveTokens, request.Tokens)
+	}
+
+	if _, err := invokeERC20(ctx, erc20, "Transfer", account.AccountID, strconv.FormatInt(request.Tokens, 10)); err != nil {
+		return nil, err
+	}
+
+	user.MymPoint -= points
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+	escrow.EscrowPoints += points
+	escrow.OutstandingTokens += request.Tokens
+	escrow.ReserveTokens -= request.Tokens
+	escrow.Locks++
+
+	return putBridgeRequest(ctx, requestID, &request, account.AccountID, config, escrow, "LockMymPoint")
+}
+
+// UnlockMymPoint 유저 계정의 ERC-20 을 준비금으로 되돌려 받고 같은 가치의 MymPoint 를 에스크로에서 돌려주는 함수
+// 운영 클라이언트가 TransferFrom 으로 가져가므로 유저가 먼저 token-erc-20 의 Approve 로 운영 계정에 tokens 이상을 허용해야 한다
+func (c *TokenERC1155Contract) UnlockMymPoint(ctx contractapi.TransactionContextInterface, requestID string, nickName string, tokens int64) (*BridgeRequest, error) {
+
+	err := newValidator().
+		field("requestID", requestID).
+		field("nickName", nickName).
+		positive("tokens", tokens).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := getBridgeRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return sameBridgeRequest(existing, bridgeUnlock, nickName, existing.Tokens == tokens)
+	}
+
+	config, err := c.checkBridgeOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account, user, err := c.checkBridgeAccount(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+
+	erc20, err := c.GetERC20Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := getBridgeEscrow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.OutstandingTokens < tokens {
+		return nil, newError(mymberr.InsufficientPoints,
+			params("escrowPoints", strconv.FormatInt(escrow.EscrowPoints, 10), "outstandingTokens", strconv.FormatInt(escrow.OutstandingTokens, 10)),
+			"bridge escrow of %d MymPoint for %d tokens cannot cover %d tokens", escrow.EscrowPoints, escrow.OutstandingTokens, tokens)
+	}
+	// 나간 ERC-20 이 있는 동안 비율이 고정되므로 에스크로는 항상 OutstandingTokens x 비율이고 곱이 넘치지 않는다
+	request := BridgeRequest{
+		Direction: bridgeUnlock,
+		NickName:  nickName,
+		Points:    tokens * config.PointsPerToken,
+		Tokens:    tokens,
+	}
+
+	if _, err := invokeERC20(ctx, erc20, "TransferFrom", account.AccountID, config.OperatorAccount, strconv.FormatInt(tokens, 10)); err != nil {
+		return nil, err
+	}
+
+	user.MymPoint += request.Points
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+	escrow.EscrowPoints -= request.Points
+	escrow.OutstandingTokens -= tokens
+	escrow.ReserveTokens += tokens
+	escrow.Unlocks++
+
+	return putBridgeRequest(ctx, requestID, &request, account.AccountID, config, escrow, "UnlockMymPoint")
+}
+
+// GetBridgeRequest 브리지 요청 기록을 조회하는 함수
+func (c *TokenERC1155Contract) GetBridgeRequest(ctx contractapi.TransactionContextInterface, requestID string) (*BridgeRequest, error) {
+	request, err := getBridgeRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, newError(mymberr.BridgeRequestNotFound, params("requestID", requestID), "bridge request %s does not exist", requestID)
+	}
+	return request, nil
+}
+
+// GetBridgeEscrow 브리지 에스크로 합계를 조회하는 함수
+func (c *TokenERC1155Contract) GetBridgeEscrow(ctx contractapi.TransactionContextInterface) (*BridgeEscrow, error) {
+	return getBridgeEscrow(ctx)
+}
+
+// ReconcileBridge 모든 요청 기록을 다시 더하고 token-erc-20 의 운영 계정 잔액을 읽어 에스크로 합계와 맞는지 확인하는 함수
+// 운영 계정이 브리지를 거치지 않고 발행하거나 보내면 잔액이 준비금과 달라져 Balanced 가 false 가 된다
+func (c *TokenERC1155Contract) ReconcileBridge(ctx contractapi.TransactionContextInterface) (*BridgeReconciliation, error) {
+
+	config, err := c.GetBridgeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	erc20, err := c.GetERC20Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := getBridgeEscrow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bridgeRequestPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	reconciliation := BridgeReconciliation{Escrow: *escrow}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+		var request BridgeRequest
+		if err := unmarshalVersioned(bridgeRequestPrefix, queryResponse.Value, &request); err != nil {
+			return nil, wrapError(err, "failed to unmarshal bridge request")
+		}
+		switch request.Direction {
+		case bridgeLock:
+			reconciliation.RequestPoints += request.Points
+			reconciliation.RequestTokens += request.Tokens
+			reconciliation.RequestReserve -= request.Tokens
+			reconciliation.RequestLocks++
+		case bridgeUnlock:
+			reconciliation.RequestPoints -= request.Points
+			reconciliation.RequestTokens -= request.Tokens
+			reconciliation.RequestReserve += request.Tokens
+			reconciliation.RequestUnlocks++
+		case bridgeMint:
+			reconciliation.RequestReserve += request.Tokens
+			reconciliation.RequestMints++
+		case bridgeBurn:
+			reconciliation.RequestReserve -= request.Tokens
+			reconciliation.RequestBurns++
+		}
+	}
+
+	payload, err := invokeERC20(ctx, erc20, "BalanceOf", config.OperatorAccount)
+	if err != nil {
+		return nil, err
+	}
+	reconciliation.ERC20ReserveBalance, err = strconv.ParseInt(strings.TrimSpace(string(payload)), 10, 64)
+	if err != nil {
+		return nil, newError(mymberr.CrossChaincodeFailed, params("chaincode", erc20.ChaincodeName, "function", "BalanceOf"), "invalid balance %q", payload)
+	}
+
+	reconciliation.Balanced = reconciliation.RequestPoints == escrow.EscrowPoints &&
+		reconciliation.RequestTokens == escrow.OutstandingTokens &&
+		reconciliation.RequestReserve == escrow.ReserveTokens &&
+		reconciliation.RequestLocks == escrow.Locks &&
+		reconciliation.RequestUnlocks == escrow.Unlocks &&
+		reconciliation.RequestMints == escrow.Mints &&
+		reconciliation.RequestBurns == escrow.Burns &&
+		reconciliation.ERC20ReserveBalance == escrow.ReserveTokens &&
+		escrow.EscrowPoints == escrow.OutstandingTokens*config.PointsPerToken
+	return &reconciliation, nil
+}
+
+// 운영 계정의 준비금을 발행하거나 소각하는 도우미 함수
+func (c *TokenERC1155Contract) changeBridgeReserve(ctx contractapi.TransactionContextInterface, requestID string, direction string, tokens int64) (*BridgeRequest, error) {
+
+	err := newValidator().
+		field("requestID", requestID).
+		positive("tokens", tokens).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := getBridgeRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return sameBridgeRequest(existing, direction, "", existing.Tokens == tokens)
+	}
+
+	config, err := c.checkBridgeOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	erc20, err := c.GetERC20Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := getBridgeEscrow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	function := "Mint"
+	eventName := "MintBridgeReserve"
+	if direction == bridgeBurn {
+		if escrow.ReserveTokens < tokens {
+			return nil, newError(mymberr.InvalidState, params("reserveTokens", strconv.FormatInt(escrow.ReserveTokens, 10)),
+				"bridge reserve of %d tokens cannot burn %d tokens", escrow.ReserveTokens, tokens)
+		}
+		function = "Burn"
+		eventName = "BurnBridgeReserve"
+	}
+
+	if _, err := invokeERC20(ctx, erc20, function, strconv.FormatInt(tokens, 10)); err != nil {
+		return nil, err
+	}
+
+	if direction == bridgeMint {
+		escrow.ReserveTokens += tokens
+		escrow.Mints++
+	} else {
+		escrow.ReserveTokens -= tokens
+		escrow.Burns++
+	}
+
+	request := BridgeRequest{Direction: direction, Tokens: tokens}
+	return putBridgeRequest(ctx, requestID, &request, config.OperatorAccount, config, escrow, eventName)
+}
+
+// 요청한 클라이언트가 설정된 운영 계정인지 확인하고 브리지 설정을 반환하는 도우미 함수
+func (c *TokenERC1155Contract) checkBridgeOperator(ctx contractapi.TransactionContextInterface) (*BridgeConfig, error) {
+	config, err := c.GetBridgeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clientID != config.OperatorAccount {
+		return nil, newError(mymberr.Unauthorized, params("clientID", clientID), "bridge requests must be submitted by the bridge operator")
+	}
+	return config, nil
+}
+
+// 유저가 브리지를 쓸 수 있는지 확인하고 정산 계정과 유저 정보를 반환하는 도우미 함수
+func (c *TokenERC1155Contract) checkBridgeAccount(ctx contractapi.TransactionContextInterface, nickName string) (*SettlementAccount, *User, error) {
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+	if err := checkNotFrozen(ctx, nickName); err != nil {
+		return nil, nil, err
+	}
+
+	account, err := c.GetSettlementAccount(ctx, nickName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, user, nil
+}
+
+// 같은 requestID 의 재요청이면 기존 기록을, 다른 내용이면 오류를 반환하는 도우미 함수
+// 금액은 호출한 쪽이 넘긴 값만 비교하므로 비율이 바뀐 뒤의 재시도도 기존 기록을 받는다
+func sameBridgeRequest(existing *BridgeRequest, direction string, nickName string, sameAmount bool) (*BridgeRequest, error) {
+	if existing.Direction != direction || existing.NickName != nickName || !sameAmount {
+		return nil, newError(mymberr.BridgeRequestConflict, params("requestID", existing.RequestID),
+			"bridge request %s was already used for a different %s", existing.RequestID, existing.Direction)
+	}
+	return existing, nil
+}
+
+// 브리지 요청 기록과 에스크로 합계를 저장하는 도우미 함수
+func putBridgeRequest(ctx contractapi.TransactionContextInterface, requestID string, request *BridgeRequest, accountID string,
+	config *BridgeConfig, escrow *BridgeEscrow, eventName string) (*BridgeRequest, error) {
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request.SchemaVersion = bridgeRequestSchemaVersion
+	request.RequestID = requestID
+	request.AccountID = accountID
+	request.PointsPerToken = config.PointsPerToken
+	request.TxID = ctx.GetStub().GetTxID()
+	request.RequestedTime = now
+
+	requestKey, err := ctx.GetStub().CreateCompositeKey(bridgeRequestPrefix, []string{requestID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal bridge request")
+	}
+	if err := ctx.GetStub().PutState(requestKey, requestBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for bridge request")
+	}
+
+	escrow.SchemaVersion = bridgeEscrowSchemaVersion
+	escrow.UpdatedTime = now
+	escrowKey, err := ctx.GetStub().CreateCompositeKey(bridgeEscrowPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	escrowBytes, err := json.Marshal(escrow)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal bridge escrow")
+	}
+	if err := ctx.GetStub().PutState(escrowKey, escrowBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for bridge escrow")
+	}
+
+	if err := ctx.GetStub().SetEvent(eventName, requestBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+	return request, nil
+}
+
+// 브리지 설정을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getBridgeConfig(ctx contractapi.TransactionContextInterface) (*BridgeConfig, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(bridgeConfigPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	configBytes, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read bridge config")
+	}
+	if configBytes == nil {
+		return nil, nil
+	}
+
+	var config BridgeConfig
+	if err := unmarshalVersioned(bridgeConfigPrefix, configBytes, &config); err != nil {
+		return nil, wrapError(err, "failed to unmarshal bridge config")
+	}
+	return &config, nil
+}
+
+// 브리지 에스크로 합계를 조회하는 도우미 함수, 없으면 0 을 반환
+func getBridgeEscrow(ctx contractapi.TransactionContextInterface) (*BridgeEscrow, error) {
+	escrowKey, err := ctx.GetStub().CreateCompositeKey(bridgeEscrowPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	escrowBytes, err := ctx.GetStub().GetState(escrowKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read bridge escrow")
+	}
+	if escrowBytes == nil {
+		return &BridgeEscrow{SchemaVersion: bridgeEscrowSchemaVersion}, nil
+	}
+
+	var escrow BridgeEscrow
+	if err := unmarshalVersioned(bridgeEscrowPrefix, escrowBytes, &escrow); err != nil {
+		return nil, wrapError(err, "failed to unmarshal bridge escrow")
+	}
+	return &escrow, nil
+}
+
+// 브리지 요청 기록을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getBridgeRequest(ctx contractapi.TransactionContextInterface, requestID string) (*BridgeRequest, error) {
+	requestKey, err := ctx.GetStub().CreateCompositeKey(bridgeRequestPrefix, []string{requestID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	requestBytes, err := ctx.GetStub().GetState(requestKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read bridge request")
+	}
+	if requestBytes == nil {
+		return nil, nil
+	}
+
+	var request BridgeRequest
+	if err := unmarshalVersioned(bridgeRequestPrefix, requestBytes, &request); err != nil {
+		return nil, wrapError(err, "failed to unmarshal bridge request")
+	}
+	return &request, nil
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -13,6 +15,7 @@ type TokenERC1155Contract struct {
 }
 
 type Token1155 struct {
+	SchemaVersion    int       `json:"schemaVersion"`
 	TokenNumber      string    `json:"tokenNumber"`
 	Owner            string    `json:"owner"`
 	CategoryCode     string    `json:"categoryCode"`
@@ -22,14 +25,19 @@ type Token1155 struct {
 	SellStage        string    `json:"sellStage"`
 	ImageURL         string    `json:"imageURL"`
 	TokenCreatedTime time.Time `json:"tokenCreatedTime"`
+	TransferCount    int       `json:"transferCount"`
+	LastTransferTime time.Time `json:"lastTransferTime"`
 }
 
 type User struct {
+	SchemaVersion    int       `json:"schemaVersion"`
 	UserId           string    `json:"userID"`
 	NickName         string    `json:"nickName"`
 	MymPoint         int64     `json:"mymPoint"`
 	OwnedToken       []string  `json:"ownedToken"`
 	BlockCreatedTime time.Time `json:"blockCreatedTime"`
+	// 컬렉션에 저장된 개인 정보의 sha256 해시, 개인 정보가 없으면 빈 문자열
+	PIIHash string `json:"piiHash"`
 }
 
 const (
@@ -41,16 +49,55 @@ const (
 func (c *TokenERC1155Contract) MintToken(ctx contractapi.TransactionContextInterface, tokenNumber string, owner string,
 	categoryCode string, fundingID string, ticketID string, tokenType string, sellStage string, imageURL string) (*Token1155, error) {
 
+	err := newValidator().
+		field("tokenNumber", tokenNumber).
+		fieldAs("nickName", "owner", owner).
+		field("categoryCode", categoryCode).
+		field("fundingID", fundingID).
+		field("ticketID", ticketID).
+		field("tokenType", tokenType).
+		field("sellStage", sellStage).
+		field("imageURL", imageURL).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCodesRegistered(ctx, categoryCode, tokenType); err != nil {
+		return nil, err
+	}
+
 	user, err := c.GetUser(ctx, owner)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user information: %v", err)
+		return nil, wrapError(err, "failed to get user information")
 	}
 
 	if user.UserId == "" {
-		return nil, fmt.Errorf("user %s does not exist", owner)
+		return nil, newError(mymberr.UserNotFound, params("nickName", owner), "user %s does not exist", owner)
+	}
+
+	if err := checkNotFrozen(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	exists, err := tokenExists(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, newError(mymberr.TokenAlreadyExists, params("tokenNumber", tokenNumber), "token %s already exists", tokenNumber)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKYCRequirement(ctx, &Token1155{TokenNumber: tokenNumber, FundingID: fundingID, TokenType: tokenType}, owner, txTime); err != nil {
+		return nil, err
 	}
 
 	token := Token1155{
+		SchemaVersion:    tokenSchemaVersion,
 		TokenNumber:      tokenNumber,
 		Owner:            owner,
 		CategoryCode:     categoryCode,
@@ -59,33 +106,20 @@ func (c *TokenERC1155Contract) MintToken(ctx contractapi.TransactionContextInter
 		TokenType:        tokenType,
 		SellStage:        sellStage,
 		ImageURL:         imageURL,
-		TokenCreatedTime: time.Now(),
-	}
-
-	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key: %v", err)
-	}
-
-	tokenBytes, err := json.Marshal(token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal token: %v", err)
+		TokenCreatedTime: txTime,
 	}
 
-	err = ctx.GetStub().PutState(tokenKey, tokenBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to put state: %v", err)
+	if err := putToken(ctx, &token); err != nil {
+		return nil, err
 	}
 
 	user.OwnedToken = append(user.OwnedToken, tokenNumber)
 
-	userKey := owner
-	userBytes, err := json.Marshal(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal user information: %v", err)
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
 	}
-	if err := ctx.GetStub().PutState(userKey, userBytes); err != nil {
-		return nil, fmt.Errorf("failed to update user information: %v", err)
+	if err := updateHoldings(ctx, transferHolding(&token, "", owner)); err != nil {
+		return nil, err
 	}
 
 	return &token, nil
@@ -96,24 +130,23 @@ func (c *TokenERC1155Contract) GetToken(ctx contractapi.TransactionContextInterf
 
 	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key: %v", err)
+		return nil, wrapError(err, "failed to create composite key")
 	}
 
 	tokenBytes, err := ctx.GetStub().GetState(tokenKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get state: %v", err)
+		return nil, wrapError(err, "failed to get state")
 	}
 
 	if tokenBytes == nil {
-		return nil, fmt.Errorf("token %s does not exist", tokenNumber)
+		return nil, newError(mymberr.TokenNotFound, params("tokenNumber", tokenNumber), "token %s does not exist", tokenNumber)
 	}
 
-	var token Token1155
-	err = json.Unmarshal(tokenBytes, &token)
+	token, err := unmarshalToken(tokenBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+		return nil, wrapError(err, "failed to unmarshal token")
 	}
-	return &token, nil
+	return token, nil
 }
 
 // GetAllTokens 모든 토큰들을 조회하는 함수
@@ -121,7 +154,7 @@ func (c *TokenERC1155Contract) GetAllTokens(ctx contractapi.TransactionContextIn
 
 	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenPrefix, []string{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+		return nil, wrapError(err, "failed to get state by partial composite key")
 	}
 	defer resultsIterator.Close()
 
@@ -130,43 +163,31 @@ func (c *TokenERC1155Contract) GetAllTokens(ctx contractapi.TransactionContextIn
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get next query response: %v", err)
+			return nil, wrapError(err, "failed to get next query response")
 		}
 
-		var token Token1155
-		err = json.Unmarshal(queryResponse.Value, &token)
+		token, err := unmarshalToken(queryResponse.Value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+			return nil, wrapError(err, "failed to unmarshal token")
 		}
 
-		tokens = append(tokens, token)
+		tokens = append(tokens, *token)
 	}
 
 	fmt.Printf("total: %d tokens\n", len(tokens))
 	return tokens, nil
 }
 
-// GetTotalTokens 모든 토큰의 총 개수를 반환하는 함수
+// GetTotalTokens 모든 토큰의 총 개수를 반환하는 함수, RebuildCounters 뒤에는 토큰을 순회하지 않고 집계 값을 읽는다
 func (c *TokenERC1155Contract) GetTotalTokens(ctx contractapi.TransactionContextInterface) (int, error) {
 
-	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenPrefix, []string{})
+	totalCount, err := c.getCounter(ctx, counterTokens, "")
 	if err != nil {
-		return 0, fmt.Errorf("failed to get state by partial composite key: %v", err)
-	}
-	defer resultsIterator.Close()
-
-	var totalCount int
-
-	for resultsIterator.HasNext() {
-		_, err := resultsIterator.Next()
-		if err != nil {
-			return 0, fmt.Errorf("failed to get next query response: %v", err)
-		}
-		totalCount++
+		return 0, err
 	}
 
 	fmt.Printf("total: %d tokens\n", totalCount)
-	return totalCount, nil
+	return int(totalCount), nil
 }
 
 // GetUserOwnedTokens 해당 유저가 가지고 있는 토큰들을 조회하는 함수
@@ -174,11 +195,11 @@ func (c *TokenERC1155Contract) GetUserOwnedTokens(ctx contractapi.TransactionCon
 
 	user, err := c.GetUser(ctx, nickName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user information: %v", err)
+		return nil, wrapError(err, "failed to get user information")
 	}
 
 	if user.UserId == "" {
-		return nil, fmt.Errorf("user %s does not exist", nickName)
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
 	}
 
 	var ownedTokens []*Token1155
@@ -186,7 +207,7 @@ func (c *TokenERC1155Contract) GetUserOwnedTokens(ctx contractapi.TransactionCon
 	for _, tokenNumber := range user.OwnedToken {
 		token, err := c.GetToken(ctx, tokenNumber)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get token %s: %v", tokenNumber, err)
+			return nil, wrapError(err, "failed to get token %s", tokenNumber)
 		}
 		ownedTokens = append(ownedTokens, token)
 	}
@@ -197,53 +218,75 @@ func (c *TokenERC1155Contract) GetUserOwnedTokens(ctx contractapi.TransactionCon
 // UpdateSellStage sellStage 필드값을 변경하는 함수
 func (c *TokenERC1155Contract) UpdateSellStage(ctx contractapi.TransactionContextInterface, tokenNumber string, newSellStage string) error {
 
+	err := newValidator().
+		field("tokenNumber", tokenNumber).
+		fieldAs("sellStage", "newSellStage", newSellStage).
+		check(newSellStage != "", "newSellStage", "required", "must not be empty").
+		err()
+	if err != nil {
+		return err
+	}
+
 	token, err := c.GetToken(ctx, tokenNumber)
 	if err != nil {
-		return fmt.Errorf("failed to get token: %v", err)
+		return wrapError(err, "failed to get token")
 	}
 
 	if token.TokenNumber == "" {
-		return fmt.Errorf("token %s does not exist", tokenNumber)
+		return newError(mymberr.TokenNotFound, params("tokenNumber", tokenNumber), "token %s does not exist", tokenNumber)
+	}
+
+	if err := checkNotFrozen(ctx, token.Owner); err != nil {
+		return err
 	}
 
 	token.SellStage = newSellStage
 
-	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
-	if err != nil {
-		return fmt.Errorf("failed to create composite key: %v", err)
-	}
+	return putToken(ctx, token)
+}
 
-	tokenBytes, err := json.Marshal(token)
+// TransferToken 지정된 토큰을 전송하는 함수
+func (c *TokenERC1155Contract) TransferToken(ctx contractapi.TransactionContextInterface, from string, to string, tokenNumber string) error {
+
+	err := newValidator().
+		fieldAs("nickName", "from", from).
+		fieldAs("nickName", "to", to).
+		field("tokenNumber", tokenNumber).
+		check(from != to, "to", "different", "sender and receiver must be different").
+		err()
 	if err != nil {
-		return fmt.Errorf("failed to marshal token: %v", err)
+		return err
 	}
 
-	err = ctx.GetStub().PutState(tokenKey, tokenBytes)
-	if err != nil {
-		return fmt.Errorf("failed to put state: %v", err)
+	if err := checkNotEnterprisePool(ctx, from); err != nil {
+		return err
 	}
-	return nil
+	return c.transferToken(ctx, from, to, tokenNumber)
 }
 
-// TransferToken 지정된 토큰을 전송하는 함수
-func (c *TokenERC1155Contract) TransferToken(ctx contractapi.TransactionContextInterface, from string, to string, tokenNumber string) error {
+// 인자 검증을 마친 토큰 전송 도우미 함수, 기업 공용 풀의 배분도 이 함수로 전송한다
+func (c *TokenERC1155Contract) transferToken(ctx contractapi.TransactionContextInterface, from string, to string, tokenNumber string) error {
 
 	fromUser, err := c.GetUser(ctx, from)
 	if err != nil {
-		return fmt.Errorf("failed to get sender information: %v", err)
+		return wrapError(err, "failed to get sender information")
 	}
 
 	if fromUser.UserId == "" {
-		return fmt.Errorf("sender %s does not exist", from)
+		return newError(mymberr.UserNotFound, params("nickName", from), "sender %s does not exist", from)
 	}
 
 	toUser, err := c.GetUser(ctx, to)
 	if err != nil {
-		return fmt.Errorf("failed to get receiver information: %v", err)
+		return wrapError(err, "failed to get receiver information")
 	}
 
 	if toUser.UserId == "" {
-		return fmt.Errorf("receiver %s does not exist", to)
+		return newError(mymberr.UserNotFound, params("nickName", to), "receiver %s does not exist", to)
+	}
+
+	if err := checkNotFrozen(ctx, from, to); err != nil {
+		return err
 	}
 
 	found := false
@@ -255,56 +298,43 @@ func (c *TokenERC1155Contract) TransferToken(ctx contractapi.TransactionContextI
 	}
 
 	if !found {
-		return fmt.Errorf("sender %s does not own the specified token %s", from, tokenNumber)
+		return newError(mymberr.NotOwner, params("nickName", from, "tokenNumber", tokenNumber), "sender %s does not own the specified token %s", from, tokenNumber)
 	}
 
-	fromUser.OwnedToken = removeToken(fromUser.OwnedToken, tokenNumber)
-
-	fromUserKey := from
-	fromUserBytes, err := json.Marshal(fromUser)
+	token, err := c.GetToken(ctx, tokenNumber)
 	if err != nil {
-		return fmt.Errorf("failed to marshal sender user: %v", err)
+		return wrapError(err, "failed to get token information")
 	}
-	if err := ctx.GetStub().PutState(fromUserKey, fromUserBytes); err != nil {
-		return fmt.Errorf("failed to update sender balance: %v", err)
-	}
-
-	toUser.OwnedToken = append(toUser.OwnedToken, tokenNumber)
 
-	toUserKey := to
-	toUserBytes, err := json.Marshal(toUser)
+	now, err := getTxTime(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal receiver user: %v", err)
-	}
-	if err := ctx.GetStub().PutState(toUserKey, toUserBytes); err != nil {
-		return fmt.Errorf("failed to update receiver balance: %v", err)
+		return err
 	}
 
-	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
-	if err != nil {
-		return fmt.Errorf("failed to create composite key for token: %v", err)
+	if err := checkTokenTransferable(ctx, token, now); err != nil {
+		return err
 	}
-	tokenBytes, err := ctx.GetStub().GetState(tokenKey)
-	if err != nil {
-		return fmt.Errorf("failed to get token information: %v", err)
-	}
-	if tokenBytes == nil {
-		return fmt.Errorf("token %s does not exist", tokenNumber)
+	if err := checkKYCRequirement(ctx, token, to, now); err != nil {
+		return err
 	}
 
-	var token Token1155
-	if err := json.Unmarshal(tokenBytes, &token); err != nil {
-		return fmt.Errorf("failed to unmarshal token: %v", err)
+	fromUser.OwnedToken = removeToken(fromUser.OwnedToken, tokenNumber)
+	if err := putUser(ctx, fromUser); err != nil {
+		return wrapError(err, "failed to update sender balance")
 	}
 
-	token.Owner = to
+	toUser.OwnedToken = append(toUser.OwnedToken, tokenNumber)
+	if err := putUser(ctx, toUser); err != nil {
+		return wrapError(err, "failed to update receiver balance")
+	}
 
-	tokenBytes, err = json.Marshal(token)
-	if err != nil {
-		return fmt.Errorf("failed to marshal token: %v", err)
+	if err := updateHoldings(ctx, transferHolding(token, from, to)); err != nil {
+		return err
 	}
-	if err := ctx.GetStub().PutState(tokenKey, tokenBytes); err != nil {
-		return fmt.Errorf("failed to update token owner: %v", err)
+	recordTokenTransfer(token, to, now)
+
+	if err := putToken(ctx, token); err != nil {
+		return wrapError(err, "failed to update token owner")
 	}
 
 	txID := ctx.GetStub().GetTxID()
@@ -316,127 +346,188 @@ func (c *TokenERC1155Contract) TransferToken(ctx contractapi.TransactionContextI
 // TransferAllTokens 해당 유저의 모든 토큰들을 전송하는 함수
 func (c *TokenERC1155Contract) TransferAllTokens(ctx contractapi.TransactionContextInterface, from string, to string) error {
 
+	err := newValidator().
+		fieldAs("nickName", "from", from).
+		fieldAs("nickName", "to", to).
+		check(from != to, "to", "different", "sender and receiver must be different").
+		err()
+	if err != nil {
+		return err
+	}
+
 	fromUser, err := c.GetUser(ctx, from)
 	if err != nil {
-		return fmt.Errorf("failed to get user %s: %v", from, err)
+		return wrapError(err, "failed to get user %s", from)
 	}
 
 	if fromUser.UserId == "" {
-		return fmt.Errorf("sender %s does not exist", from)
+		return newError(mymberr.UserNotFound, params("nickName", from), "sender %s does not exist", from)
 	}
 
 	toUser, err := c.GetUser(ctx, to)
 	if err != nil {
-		return fmt.Errorf("failed to get user %s: %v", to, err)
+		return wrapError(err, "failed to get user %s", to)
 	}
 
 	if toUser.UserId == "" {
-		return fmt.Errorf("receiver %s does not exist", to)
+		return newError(mymberr.UserNotFound, params("nickName", to), "receiver %s does not exist", to)
 	}
 
-	toUser.OwnedToken = append(toUser.OwnedToken, fromUser.OwnedToken...)
-	fromUser.OwnedToken = []string{}
+	if err := checkNotFrozen(ctx, from, to); err != nil {
+		return err
+	}
+	if err := checkNotEnterprisePool(ctx, from); err != nil {
+		return err
+	}
 
-	fromUserBytes, err := json.Marshal(fromUser)
+	now, err := getTxTime(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user %s: %v", from, err)
+		return err
 	}
-	err = ctx.GetStub().PutState(from, fromUserBytes)
-	if err != nil {
-		return fmt.Errorf("failed to put state for user %s: %v", from, err)
+
+	// 하나라도 전송할 수 없는 토큰이 있으면 전체 전송을 거부한다
+	tokens := make([]*Token1155, 0, len(fromUser.OwnedToken))
+	for _, tokenNumber := range fromUser.OwnedToken {
+		token, err := c.GetToken(ctx, tokenNumber)
+		if err != nil {
+			return wrapError(err, "failed to get token %s", tokenNumber)
+		}
+		if err := checkTokenTransferable(ctx, token, now); err != nil {
+			return err
+		}
+		if err := checkKYCRequirement(ctx, token, to, now); err != nil {
+			return err
+		}
+		tokens = append(tokens, token)
 	}
 
-	toUserBytes, err := json.Marshal(toUser)
-	if err != nil {
-		return fmt.Errorf("failed to marshal user %s: %v", to, err)
+	changes := []holdingChange{}
+	for _, token := range tokens {
+		changes = append(changes, transferHolding(token, from, to)...)
+		recordTokenTransfer(token, to, now)
+		if err := putToken(ctx, token); err != nil {
+			return err
+		}
 	}
-	err = ctx.GetStub().PutState(to, toUserBytes)
-	if err != nil {
-		return fmt.Errorf("failed to put state for user %s: %v", to, err)
+	if err := updateHoldings(ctx, changes); err != nil {
+		return err
 	}
 
-	return nil
+	toUser.OwnedToken = append(toUser.OwnedToken, fromUser.OwnedToken...)
+	fromUser.OwnedToken = []string{}
+
+	if err := putUser(ctx, fromUser); err != nil {
+		return err
+	}
+	return putUser(ctx, toUser)
 }
 
 // DeleteTokens 지정된 토큰들을 삭제하는 함수
 func (c *TokenERC1155Contract) DeleteTokens(ctx contractapi.TransactionContextInterface, nickName string, tokenNumbers []string) error {
+	err := newValidator().
+		field("nickName", nickName).
+		list("tokenNumber", "tokenNumbers", tokenNumbers).
+		err()
+	if err != nil {
+		return err
+	}
+
 	user, err := c.GetUser(ctx, nickName)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %v", err)
+		return wrapError(err, "failed to get user")
 	}
 
 	if user.UserId == "" {
-		return fmt.Errorf("user %s does not exist", nickName)
+		return newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	if err := checkNotFrozen(ctx, nickName); err != nil {
+		return err
 	}
 
 	for _, tokenNumber := range tokenNumbers {
 		user.OwnedToken = removeToken(user.OwnedToken, tokenNumber)
-
-		tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
-		if err != nil {
-			return fmt.Errorf("failed to create composite key: %v", err)
-		}
-		if err := ctx.GetStub().DelState(tokenKey); err != nil {
-			return fmt.Errorf("failed to delete token: %v", err)
-		}
 	}
-
-	userKey := user.NickName
-	userBytes, err := json.Marshal(user)
-	if err != nil {
-		return fmt.Errorf("failed to marshal user: %v", err)
+	if err := deleteTokens(ctx, tokenNumbers); err != nil {
+		return err
 	}
-	if err := ctx.GetStub().PutState(userKey, userBytes); err != nil {
-		return fmt.Errorf("failed to update user: %v", err)
+
+	if err := putUser(ctx, user); err != nil {
+		return wrapError(err, "failed to update user")
 	}
 
 	return nil
 }
 
-// DeleteAllTokens 해당 유저가 가지고 있는 모든 토큰들을 삭제하는 함수
+// DeleteAllTokens 해당 유저가 가지고 있는 모든 토큰들을 삭제하는 함수, 승인 정책이 있으면 ProposeOperation 으로만 실행할 수 있다
 func (c *TokenERC1155Contract) DeleteAllTokens(ctx contractapi.TransactionContextInterface, nickName string) error {
+	if err := c.requireMultisig(ctx, opDeleteAllTokens); err != nil {
+		return err
+	}
+	return c.deleteAllTokens(ctx, nickName)
+}
+
+// 유저의 모든 토큰을 삭제하는 도우미 함수, 승인된 제안도 이 함수로 실행한다
+func (c *TokenERC1155Contract) deleteAllTokens(ctx contractapi.TransactionContextInterface, nickName string) error {
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return err
+	}
+
 	user, err := c.GetUser(ctx, nickName)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %v", err)
+		return wrapError(err, "failed to get user")
 	}
 
 	if user.UserId == "" {
-		return fmt.Errorf("user %s does not exist", nickName)
+		return newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
 	}
 
-	for _, tokenNumber := range user.OwnedToken {
-		tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
-		if err != nil {
-			return fmt.Errorf("failed to create composite key: %v", err)
-		}
-		if err := ctx.GetStub().DelState(tokenKey); err != nil {
-			return fmt.Errorf("failed to delete token: %v", err)
-		}
+	if err := checkNotFrozen(ctx, nickName); err != nil {
+		return err
+	}
+
+	if err := deleteTokens(ctx, user.OwnedToken); err != nil {
+		return err
 	}
 
 	user.OwnedToken = []string{}
 
-	userKey := user.NickName
-	userBytes, err := json.Marshal(user)
-	if err != nil {
-		return fmt.Errorf("failed to marshal user: %v", err)
-	}
-	if err := ctx.GetStub().PutState(userKey, userBytes); err != nil {
-		return fmt.Errorf("failed to update user: %v", err)
+	if err := putUser(ctx, user); err != nil {
+		return wrapError(err, "failed to update user")
 	}
 
 	return nil
 }
 
-// CreateUserBlock 유저 정보 블록을 생성하는 함수
+// CreateUserBlock 유저 정보 블록을 생성하는 함수, 승인 정책의 LargePointGrant 이상의 시작 잔액은 받지 않는다
 func (c *TokenERC1155Contract) CreateUserBlock(ctx contractapi.TransactionContextInterface, userId string, nickName string, mymPoint int64, ownedToken []string) error {
 
+	err := newValidator().
+		field("userID", userId).
+		field("nickName", nickName).
+		nonNegative("mymPoint", mymPoint).
+		list("tokenNumber", "ownedToken", ownedToken).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if err := checkTokensExist(ctx, "ownedToken", ownedToken); err != nil {
+		return err
+	}
+	// 시작 잔액도 지급과 같으므로 큰 금액은 0 으로 만든 뒤 UpdateMymPoint 제안으로 지급한다
+	window, err := checkLargePointGrant(ctx, nickName, mymPoint)
+	if err != nil {
+		return err
+	}
+
 	userBytes, err := ctx.GetStub().GetState(nickName)
 	if err == nil && userBytes != nil {
-		return fmt.Errorf("user %s already exists", nickName)
+		return newError(mymberr.UserAlreadyExists, params("nickName", nickName), "user %s already exists", nickName)
 	}
 
 	user := User{
+		SchemaVersion:    userSchemaVersion,
 		UserId:           userId,
 		NickName:         nickName,
 		MymPoint:         mymPoint,
@@ -444,17 +535,10 @@ func (c *TokenERC1155Contract) CreateUserBlock(ctx contractapi.TransactionContex
 		BlockCreatedTime: time.Now(),
 	}
 
-	userKey := nickName
-	userBytes, err = json.Marshal(user)
-	if err != nil {
-		return fmt.Errorf("failed to marshal user block: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(userKey, userBytes)
-	if err != nil {
-		return fmt.Errorf("failed to put state for user block: %v", err)
+	if err := putUser(ctx, &user); err != nil {
+		return wrapError(err, "failed to put state for user block")
 	}
-	return nil
+	return putPointGrantWindow(ctx, window)
 }
 
 // GetUser 해당 유저 정보를 조회하는 함수
@@ -463,7 +547,7 @@ func (c *TokenERC1155Contract) GetUser(ctx contractapi.TransactionContextInterfa
 	userKey := nickName
 	userBytes, err := ctx.GetStub().GetState(userKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read user block: %v", err)
+		return nil, wrapError(err, "failed to read user block")
 	}
 
 	if userBytes == nil {
@@ -473,17 +557,12 @@ func (c *TokenERC1155Contract) GetUser(ctx contractapi.TransactionContextInterfa
 		}, nil
 	}
 
-	var user User
-	err = json.Unmarshal(userBytes, &user)
+	user, err := unmarshalUser(userBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user block: %v", err)
-	}
-
-	if user.OwnedToken == nil {
-		user.OwnedToken = []string{}
+		return nil, wrapError(err, "failed to unmarshal user block")
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetAllUsers 모든 유저 정보를 조회하는 함수
@@ -491,7 +570,7 @@ func (c *TokenERC1155Contract) GetAllUsers(ctx contractapi.TransactionContextInt
 
 	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get state by range: %v", err)
+		return nil, wrapError(err, "failed to get state by range")
 	}
 	defer resultsIterator.Close()
 
@@ -500,81 +579,87 @@ func (c *TokenERC1155Contract) GetAllUsers(ctx contractapi.TransactionContextInt
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get next query response: %v", err)
+			return nil, wrapError(err, "failed to get next query response")
 		}
 
-		var user User
-		err = json.Unmarshal(queryResponse.Value, &user)
+		user, err := unmarshalUser(queryResponse.Value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal user: %v", err)
+			return nil, wrapError(err, "failed to unmarshal user")
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 	fmt.Printf("total: %d users\n", len(users))
 	return users, nil
 }
 
-// GetTotalUsers 모든 유저들의 total 값을 반환하는 함수
+// GetTotalUsers 모든 유저들의 total 값을 반환하는 함수, RebuildCounters 뒤에는 유저를 순회하지 않고 집계 값을 읽는다
 func (c *TokenERC1155Contract) GetTotalUsers(ctx contractapi.TransactionContextInterface) (int, error) {
 
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	totalCount, err := c.getCounter(ctx, counterUsers, "")
 	if err != nil {
-		return 0, fmt.Errorf("failed to get state by range: %v", err)
-	}
-	defer resultsIterator.Close()
-
-	var totalCount int
-
-	for resultsIterator.HasNext() {
-		_, err := resultsIterator.Next()
-		if err != nil {
-			return 0, fmt.Errorf("failed to get next query response: %v", err)
-		}
-		totalCount++
+		return 0, err
 	}
 
 	fmt.Printf("total: %d users\n", totalCount)
-	return totalCount, nil
+	return int(totalCount), nil
 }
 
 // DeleteUser 해당 닉네임을 가진 유저 블록을 삭제하는 함수
 func (c *TokenERC1155Contract) DeleteUser(ctx contractapi.TransactionContextInterface, nickName string) error {
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return err
+	}
+
 	userKey := nickName
 
 	userBytes, err := ctx.GetStub().GetState(userKey)
 	if err != nil {
-		return fmt.Errorf("failed to read user block: %v", err)
+		return wrapError(err, "failed to read user block")
 	}
 	if userBytes == nil {
-		return fmt.Errorf("user with nickname %s does not exist", nickName)
+		return newError(mymberr.UserNotFound, params("nickName", nickName), "user with nickname %s does not exist", nickName)
 	}
 
-	err = ctx.GetStub().DelState(userKey)
-	if err != nil {
-		return fmt.Errorf("failed to delete user block: %v", err)
+	if err := checkNotFrozen(ctx, nickName); err != nil {
+		return err
+	}
+
+	if err := deleteUser(ctx, userKey, userBytes); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// DeleteAllUserBlocks 모든 유저 정보 블록을 삭제하는 함수
+// DeleteAllUserBlocks 모든 유저 정보 블록을 삭제하는 함수, 승인 정책이 있으면 ProposeOperation 으로만 실행할 수 있다
 func (c *TokenERC1155Contract) DeleteAllUserBlocks(ctx contractapi.TransactionContextInterface) error {
+	if err := c.requireMultisig(ctx, opDeleteAllUserBlocks); err != nil {
+		return err
+	}
+	return c.deleteAllUserBlocks(ctx)
+}
+
+// 모든 유저 정보 블록을 삭제하는 도우미 함수, 승인된 제안도 이 함수로 실행한다
+func (c *TokenERC1155Contract) deleteAllUserBlocks(ctx contractapi.TransactionContextInterface) error {
 
 	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
-		return fmt.Errorf("failed to get state by range: %v", err)
+		return wrapError(err, "failed to get state by range")
 	}
 	defer resultsIterator.Close()
 
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			return fmt.Errorf("failed to get next query response: %v", err)
+			return wrapError(err, "failed to get next query response")
 		}
 
-		err = ctx.GetStub().DelState(queryResponse.Key)
-		if err != nil {
-			return fmt.Errorf("failed to delete user block: %v", err)
+		if err := checkNotFrozen(ctx, queryResponse.Key); err != nil {
+			return err
+		}
+
+		if err := deleteUser(ctx, queryResponse.Key, queryResponse.Value); err != nil {
+			return err
 		}
 	}
 
@@ -582,38 +667,57 @@ func (c *TokenERC1155Contract) DeleteAllUserBlocks(ctx contractapi.TransactionCo
 	return nil
 }
 
-// UpdateMymPoint 커뮤니티 활동 포인트 적립하는 함수
+// UpdateMymPoint 커뮤니티 활동 포인트 적립하는 함수, 하루 동안 승인 없이 받은 합이 승인 정책의 LargePointGrant 에 이르는 적립은 ProposeOperation 으로만 할 수 있다
 func (c *TokenERC1155Contract) UpdateMymPoint(ctx contractapi.TransactionContextInterface, nickName string, delta int64) error {
 
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return err
+	}
+
+	window, err := checkLargePointGrant(ctx, nickName, delta)
+	if err != nil {
+		return err
+	}
+	if err := c.updateMymPoint(ctx, nickName, delta); err != nil {
+		return err
+	}
+	return putPointGrantWindow(ctx, window)
+}
+
+// MymPoint 를 더하거나 빼는 도우미 함수, 승인된 제안도 이 함수로 실행한다
+func (c *TokenERC1155Contract) updateMymPoint(ctx contractapi.TransactionContextInterface, nickName string, delta int64) error {
+
 	userKey := nickName
 	userBytes, err := ctx.GetStub().GetState(userKey)
 	if err != nil {
-		return fmt.Errorf("failed to read user block: %v", err)
+		return wrapError(err, "failed to read user block")
 	}
 	if userBytes == nil {
-		return fmt.Errorf("user with nickname %s does not exist", nickName)
+		return newError(mymberr.UserNotFound, params("nickName", nickName), "user with nickname %s does not exist", nickName)
+	}
+
+	if err := checkNotFrozen(ctx, nickName); err != nil {
+		return err
+	}
+	if delta < 0 {
+		if err := checkNotEnterprisePool(ctx, nickName); err != nil {
+			return err
+		}
 	}
 
-	var user User
-	err = json.Unmarshal(userBytes, &user)
+	user, err := unmarshalUser(userBytes)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal user block: %v", err)
+		return wrapError(err, "failed to unmarshal user block")
 	}
 
 	newMymPoint := user.MymPoint + delta
 	if newMymPoint < 0 {
-		return fmt.Errorf("MymPoint cannot be negative")
+		return newError(mymberr.InsufficientPoints, params("nickName", nickName, "mymPoint", strconv.FormatInt(user.MymPoint, 10)), "MymPoint cannot be negative")
 	}
 	user.MymPoint = newMymPoint
 
-	userBytes, err = json.Marshal(user)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated user block: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(userKey, userBytes)
-	if err != nil {
-		return fmt.Errorf("failed to put state for updated user block: %v", err)
+	if err := putUser(ctx, user); err != nil {
+		return wrapError(err, "failed to put state for updated user block")
 	}
 	return nil
 }
@@ -629,8 +733,164 @@ func removeToken(tokens []string, tokenNumber string) []string {
 	return newTokens
 }
 
+// 유저 정보 블록을 닉네임 키로 저장하는 도우미 함수
+// 같은 트랜잭션에서 쓴 값은 다시 읽을 수 없으므로 유저는 트랜잭션마다 한 번만 저장해야 한다
+func putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	oldBytes, err := ctx.GetStub().GetState(user.NickName)
+	if err != nil {
+		return wrapError(err, "failed to read user %s", user.NickName)
+	}
+	var oldUser *User
+	if oldBytes != nil {
+		if oldUser, err = unmarshalUser(oldBytes); err != nil {
+			return err
+		}
+	}
+	if err := updatePointsRank(ctx, oldUser, user); err != nil {
+		return err
+	}
+	if err := updateUserCounters(ctx, oldUser, user); err != nil {
+		return err
+	}
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return wrapError(err, "failed to marshal user %s", user.NickName)
+	}
+	if err := ctx.GetStub().PutState(user.NickName, userBytes); err != nil {
+		return wrapError(err, "failed to put state for user %s", user.NickName)
+	}
+	return nil
+}
+
+// 유저 정보 블록과 MymPoint 순위 인덱스를 삭제하고 집계에서 빼는 도우미 함수
+func deleteUser(ctx contractapi.TransactionContextInterface, nickName string, userBytes []byte) error {
+	user, err := unmarshalUser(userBytes)
+	if err != nil {
+		return err
+	}
+	if err := deletePointsRank(ctx, nickName, user.MymPoint); err != nil {
+		return err
+	}
+	if err := updateUserCounters(ctx, user, nil); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(nickName); err != nil {
+		return wrapError(err, "failed to delete user block")
+	}
+	return nil
+}
+
+// 토큰을 복합키로 저장하고 집계 변화량을 쓰는 도우미 함수, 유저와 마찬가지로 트랜잭션마다 한 번만 저장해야 한다
+// 새 토큰에는 펀딩의 보증 정책을 적용한다
+func putToken(ctx contractapi.TransactionContextInterface, token *Token1155) error {
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{token.TokenNumber})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	oldBytes, err := ctx.GetStub().GetState(tokenKey)
+	if err != nil {
+		return wrapError(err, "failed to read token %s", token.TokenNumber)
+	}
+	var oldToken *Token1155
+	if oldBytes != nil {
+		if oldToken, err = unmarshalToken(oldBytes); err != nil {
+			return err
+		}
+	}
+	if err := updateTokenCounters(ctx, oldToken, token); err != nil {
+		return err
+	}
+	if oldToken == nil {
+		if err := applyFundingEndorsement(ctx, tokenKey, token.FundingID); err != nil {
+			return err
+		}
+	}
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return wrapError(err, "failed to marshal token")
+	}
+	if err := ctx.GetStub().PutState(tokenKey, tokenBytes); err != nil {
+		return wrapError(err, "failed to put state for token %s", token.TokenNumber)
+	}
+	return nil
+}
+
+// 토큰들을 삭제하고 소유자의 보유 수와 집계에서 빼는 도우미 함수, 없는 토큰은 건너뛴다
+// 경매, 대여, 공동 소유 풀에 잠긴 토큰이 하나라도 있으면 아무것도 지우지 않는다
+func deleteTokens(ctx contractapi.TransactionContextInterface, tokenNumbers []string) error {
+	for _, tokenNumber := range tokenNumbers {
+		if err := checkTokenUnlocked(ctx, tokenNumber); err != nil {
+			return err
+		}
+	}
+
+	changes := []holdingChange{}
+	for _, tokenNumber := range tokenNumbers {
+		tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
+		if err != nil {
+			return wrapError(err, "failed to create composite key")
+		}
+		tokenBytes, err := ctx.GetStub().GetState(tokenKey)
+		if err != nil {
+			return wrapError(err, "failed to read token")
+		}
+		if tokenBytes == nil {
+			continue
+		}
+		token, err := unmarshalToken(tokenBytes)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, transferHolding(token, token.Owner, "")...)
+		if err := updateTokenCounters(ctx, token, nil); err != nil {
+			return err
+		}
+
+		if err := ctx.GetStub().DelState(tokenKey); err != nil {
+			return wrapError(err, "failed to delete token")
+		}
+	}
+	return updateHoldings(ctx, changes)
+}
+
+// 토큰이 이미 발행되었는지 확인하는 도우미 함수
+func tokenExists(ctx contractapi.TransactionContextInterface, tokenNumber string) (bool, error) {
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return false, wrapError(err, "failed to create composite key")
+	}
+	tokenBytes, err := ctx.GetStub().GetState(tokenKey)
+	if err != nil {
+		return false, wrapError(err, "failed to read token")
+	}
+	return tokenBytes != nil, nil
+}
+
+// 트랜잭션 타임스탬프를 time.Time 으로 반환하는 도우미 함수
+// (time.Now() 는 피어마다 값이 달라 보증 결과가 어긋날 수 있다)
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, wrapError(err, "failed to get transaction timestamp")
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// 문자열 슬라이스에 특정 값이 있는지 확인하는 도우미 함수
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
-	cc, err := contractapi.NewChaincode(new(TokenERC1155Contract))
+	contract := new(TokenERC1155Contract)
+	contract.BeforeTransaction = contract.checkPaused
+
+	cc, err := contractapi.NewChaincode(contract)
 	if err != nil {
 		panic(err.Error())
 	}
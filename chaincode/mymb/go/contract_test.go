@@ -0,0 +1,2006 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/merkle"
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const testMSPID = "Org1MSP"
+
+// testStub 는 MockStub 이 지원하지 않는 transient 와 private data hash 를 채워 넣는다
+type testStub struct {
+	*shimtest.MockStub
+	transient map[string][]byte
+	function  string
+}
+
+// 실제 shim 처럼 빈 시작, 끝 키를 바꿔 복합키가 범위 조회에 섞이지 않게 한다
+func (s *testStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	if endKey == "" {
+		endKey = string(utf8.MaxRune)
+	}
+	return shimtest.NewMockStateRangeQueryIterator(s.MockStub, startKey, endKey), nil
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, []string{}
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// MockStub 은 DelPrivateData 를 지원하지 않는다
+func (s *testStub) DelPrivateData(collection, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+func (s *testStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value, err := s.GetPrivateData(collection, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+// testIdentity 는 cid.ClientIdentity 의 테스트용 구현
+type testIdentity struct {
+	id    string
+	mspID string
+	attrs map[string]string
+}
+
+func (i *testIdentity) GetID() (string, error) {
+	return i.id, nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, ok := i.attrs[attrName]
+	return value, ok, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	value, ok := i.attrs[attrName]
+	if !ok || value != attrValue {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// testERC20 는 token-erc-20 의 함수 중 mymb 가 호출하는 것만 흉내 내며, 실제 체인코드처럼 트랜잭션을 제출한 클라이언트(client)를 기준으로 동작한다
+type testERC20 struct {
+	client     string
+	balances   map[string]int
+	allowances map[[2]string]int
+}
+
+func newTestERC20(client string) *testERC20 {
+	return &testERC20{client: client, balances: map[string]int{}, allowances: map[[2]string]int{}}
+}
+
+func (e *testERC20) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+func (e *testERC20) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	fcn, args := stub.GetFunctionAndParameters()
+	switch fcn {
+	case "Allowance":
+		return shim.Success([]byte(strconv.Itoa(e.allowances[[2]string{args[0], args[1]}])))
+	case "TransferFrom":
+		value, _ := strconv.Atoi(args[2])
+		allowanceKey := [2]string{args[0], e.client}
+		if e.allowances[allowanceKey] < value {
+			return shim.Error("spender does not have enough allowance for transfer")
+		}
+		if e.balances[args[0]] < value {
+			return shim.Error("client account " + args[0] + " has insufficient funds")
+		}
+		e.allowances[allowanceKey] -= value
+		e.balances[args[0]] -= value
+		e.balances[args[1]] += value
+		return shim.Success(nil)
+	case "Transfer":
+		value, _ := strconv.Atoi(args[1])
+		if e.balances[e.client] < value {
+			return shim.Error("client account " + e.client + " has insufficient funds")
+		}
+		e.balances[e.client] -= value
+		e.balances[args[0]] += value
+		return shim.Success(nil)
+	case "BalanceOf":
+		return shim.Success([]byte(strconv.Itoa(e.balances[args[0]])))
+	case "Mint":
+		value, _ := strconv.Atoi(args[0])
+		e.balances[e.client] += value
+		return shim.Success(nil)
+	case "Burn":
+		value, _ := strconv.Atoi(args[0])
+		if e.balances[e.client] < value {
+			return shim.Error("minter does not have enough balance for burn")
+		}
+		e.balances[e.client] -= value
+		return shim.Success(nil)
+	}
+	return shim.Error("unknown function " + fcn)
+}
+
+func newTestContext(t *testing.T) (*contractapi.TransactionContext, *testStub) {
+	t.Helper()
+	os.Setenv("CORE_PEER_LOCALMSPID", testMSPID)
+
+	stub := &testStub{MockStub: shimtest.NewMockStub("mymb", nil)}
+	stub.MockTransactionStart("tx0")
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	// 테스트에서 쓰는 카테고리와 토큰 종류를 미리 등록해 둔다
+	c := new(TokenERC1155Contract)
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetCategory(ctx, "C01", "공연", "Performance", ""); err != nil {
+		t.Fatalf("SetCategory failed: %v", err)
+	}
+	for _, tokenType := range []string{"ticket", "badge"} {
+		if _, err := c.SetTokenType(ctx, tokenType, tokenType, tokenType, false, false, 0, 0, 0); err != nil {
+			t.Fatalf("SetTokenType failed: %v", err)
+		}
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+	return ctx, stub
+}
+
+// 새 트랜잭션을 시작한 것처럼 트랜잭션 ID 를 바꾼다
+func nextTx(stub *testStub, txID string) {
+	stub.MockTransactionEnd(stub.TxID)
+	stub.MockTransactionStart(txID)
+}
+
+func mustCreateUser(t *testing.T, c *TokenERC1155Contract, ctx contractapi.TransactionContextInterface, nickName string, mymPoint int64) {
+	t.Helper()
+	if err := c.CreateUserBlock(ctx, "id-"+nickName, nickName, mymPoint, []string{}); err != nil {
+		t.Fatalf("CreateUserBlock(%s) failed: %v", nickName, err)
+	}
+}
+
+func mustGetUser(t *testing.T, c *TokenERC1155Contract, ctx contractapi.TransactionContextInterface, nickName string) *User {
+	t.Helper()
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		t.Fatalf("GetUser(%s) failed: %v", nickName, err)
+	}
+	return user
+}
+
+func TestNewChaincode(t *testing.T) {
+	if _, err := contractapi.NewChaincode(new(TokenERC1155Contract)); err != nil {
+		t.Fatalf("NewChaincode failed: %v", err)
+	}
+}
+
+func TestAuctionSettlesInMymPoint(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "seller", 0)
+	mustCreateUser(t, c, ctx, "alice", 500)
+	mustCreateUser(t, c, ctx, "bob", 100)
+
+	if _, err := c.MintToken(ctx, "T1", "seller", "C01", "F1", "TK1", "ticket", "onSale", "https://img/1.png"); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	if _, err := c.CreateAuction(ctx, "A1", "T1"); err != nil {
+		t.Fatalf("CreateAuction failed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "seller", "bob", "T1"); err == nil {
+		t.Fatalf("expected transfer of auctioned token to fail")
+	}
+	if err := c.DeleteTokens(ctx, "seller", []string{"T1"}); !mymberr.Is(err, mymberr.TokenLocked) {
+		t.Fatalf("expected deletion of auctioned token to fail, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if err := c.DeleteAllTokens(ctx, "seller"); !mymberr.Is(err, mymberr.TokenLocked) {
+		t.Fatalf("expected deletion of all tokens including an auctioned one to fail, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	bids := map[string][]byte{}
+	for _, b := range []FullBid{
+		{Price: 300, Org: testMSPID, Bidder: "alice"},
+		{Price: 900, Org: testMSPID, Bidder: "bob"},
+	} {
+		bidJSON, _ := json.Marshal(b)
+		nextTx(stub, "bid-"+b.Bidder)
+		stub.transient = map[string][]byte{"bid": bidJSON}
+		txID, err := c.Bid(ctx, "A1")
+		if err != nil {
+			t.Fatalf("Bid failed: %v", err)
+		}
+		if err := c.SubmitBid(ctx, "A1", txID); err != nil {
+			t.Fatalf("SubmitBid failed: %v", err)
+		}
+		bids[txID] = bidJSON
+	}
+
+	if err := c.CloseAuction(ctx, "A1"); err != nil {
+		t.Fatalf("CloseAuction failed: %v", err)
+	}
+	for txID, bidJSON := range bids {
+		stub.transient = map[string][]byte{"bid": bidJSON}
+		if err := c.RevealBid(ctx, "A1", txID); err != nil {
+			t.Fatalf("RevealBid failed: %v", err)
+		}
+	}
+
+	// bob 의 입찰이 가장 높지만 MymPoint 가 부족하므로 alice 가 낙찰된다
+	auction, err := c.EndAuction(ctx, "A1")
+	if err != nil {
+		t.Fatalf("EndAuction failed: %v", err)
+	}
+	if auction.Winner != "alice" || auction.Price != 300 {
+		t.Fatalf("unexpected winner %s at %d", auction.Winner, auction.Price)
+	}
+
+	token, err := c.GetToken(ctx, "T1")
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token.Owner != "alice" {
+		t.Fatalf("token owner = %s, want alice", token.Owner)
+	}
+	if got := mustGetUser(t, c, ctx, "alice").MymPoint; got != 200 {
+		t.Fatalf("alice MymPoint = %d, want 200", got)
+	}
+	if got := mustGetUser(t, c, ctx, "seller").MymPoint; got != 300 {
+		t.Fatalf("seller MymPoint = %d, want 300", got)
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("token should be unlocked after auction: %v", err)
+	}
+}
+
+func TestCancelUnsoldAuctions(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "seller", 0)
+	mustCreateUser(t, c, ctx, "bob", 100)
+	for _, tokenNumber := range []string{"T1", "T2"} {
+		if _, err := c.MintToken(ctx, tokenNumber, "seller", "C01", "F1", "", "ticket", "onSale", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+
+	// 입찰이 없는 경매
+	if _, err := c.CreateAuction(ctx, "A1", "T1"); err != nil {
+		t.Fatalf("CreateAuction failed: %v", err)
+	}
+	if err := c.CloseAuction(ctx, "A1"); err != nil {
+		t.Fatalf("CloseAuction failed: %v", err)
+	}
+	if _, err := c.EndAuction(ctx, "A1"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected an auction without bids not to end, got %v", err)
+	}
+
+	// MymPoint 가 부족한 입찰자만 있는 경매
+	if _, err := c.CreateAuction(ctx, "A2", "T2"); err != nil {
+		t.Fatalf("CreateAuction failed: %v", err)
+	}
+	bidJSON, _ := json.Marshal(FullBid{Price: 900, Org: testMSPID, Bidder: "bob"})
+	nextTx(stub, "bid-bob")
+	stub.transient = map[string][]byte{"bid": bidJSON}
+	txID, err := c.Bid(ctx, "A2")
+	if err != nil {
+		t.Fatalf("Bid failed: %v", err)
+	}
+	if err := c.SubmitBid(ctx, "A2", txID); err != nil {
+		t.Fatalf("SubmitBid failed: %v", err)
+	}
+	if err := c.CloseAuction(ctx, "A2"); err != nil {
+		t.Fatalf("CloseAuction failed: %v", err)
+	}
+	if err := c.RevealBid(ctx, "A2", txID); err != nil {
+		t.Fatalf("RevealBid failed: %v", err)
+	}
+	if _, err := c.EndAuction(ctx, "A2"); !mymberr.Is(err, mymberr.InsufficientPoints) {
+		t.Fatalf("expected an auction with only insolvent bidders not to end, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "other", mspID: testMSPID})
+	if _, err := c.CancelAuction(ctx, "A1"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a cancel from another client to be rejected, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+	if auction, err := c.CancelAuction(ctx, "A1"); err != nil || auction.Status != auctionCancelled {
+		t.Fatalf("expected the creator to cancel, got %+v, %v", auction, err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.CancelAuction(ctx, "A2"); err != nil {
+		t.Fatalf("expected an admin to cancel, got %v", err)
+	}
+	if _, err := c.CancelAuction(ctx, "A2"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected a cancelled auction not to be cancelled again, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.TransferToken(ctx, "seller", "bob", "T1"); err != nil {
+		t.Fatalf("expected the token of a cancelled auction to be unlocked, got %v", err)
+	}
+	if err := c.TransferToken(ctx, "seller", "bob", "T2"); err != nil {
+		t.Fatalf("expected the token of a cancelled auction to be unlocked, got %v", err)
+	}
+}
+
+func TestTransferPolicies(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, _ := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+
+	for _, tokenNumber := range []string{"B1", "T1"} {
+		tokenType := "ticket"
+		if tokenNumber == "B1" {
+			tokenType = "badge"
+		}
+		if _, err := c.MintToken(ctx, tokenNumber, "alice", "C01", "F1", "", tokenType, "sold", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+
+	if _, err := c.SetTransferPolicy(ctx, policyScopeTokenType, "badge", true, 0, 0, 0); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected SetTransferPolicy to require the admin role, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetTransferPolicy(ctx, policyScopeTokenType, "badge", true, 0, 0, 0); err != nil {
+		t.Fatalf("SetTransferPolicy failed: %v", err)
+	}
+	if _, err := c.SetTransferPolicy(ctx, policyScopeFunding, "F1", false, 0, 0, 1); err != nil {
+		t.Fatalf("SetTransferPolicy failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+	if err := c.DeleteTransferPolicy(ctx, policyScopeTokenType, "badge"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected DeleteTransferPolicy to require the admin role, got %v", err)
+	}
+
+	if err := c.TransferToken(ctx, "alice", "bob", "B1"); err == nil {
+		t.Fatalf("expected non-transferable badge to be rejected")
+	}
+	if err := c.TransferAllTokens(ctx, "alice", "bob"); err == nil {
+		t.Fatalf("expected TransferAllTokens to reject the badge")
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("first transfer should be allowed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "bob", "alice", "T1"); err == nil {
+		t.Fatalf("expected second transfer to exceed maxTransfers")
+	}
+}
+
+func TestTransferLockDurationUsesTxTime(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetTransferPolicy(ctx, policyScopeFunding, "F1", false, 0, 3600, 0); err != nil {
+		t.Fatalf("SetTransferPolicy failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	// 발행 시각은 피어 시계가 아니라 트랜잭션 시각이어야 보증 결과가 같다
+	token, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", "")
+	if err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	if token.TokenCreatedTime.Unix() != stub.TxTimestamp.Seconds {
+		t.Fatalf("expected the creation time to be the tx time, got %v", token.TokenCreatedTime)
+	}
+
+	nextTx(stub, "tx1")
+	stub.TxTimestamp.Seconds += 3599
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); !mymberr.Is(err, mymberr.TransferRestricted) {
+		t.Fatalf("expected a transfer within the lock duration to be rejected, got %v", err)
+	}
+	stub.TxTimestamp.Seconds++
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("expected the transfer after the lock duration to succeed: %v", err)
+	}
+}
+
+func TestFrozenUserIsRejected(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, _ := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 100)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	if _, err := c.FreezeUser(ctx, "bob", "chargeback fraud", "ops"); err == nil {
+		t.Fatalf("expected FreezeUser to require the admin role")
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.FreezeUser(ctx, "bob", "chargeback fraud", "ops"); err != nil {
+		t.Fatalf("FreezeUser failed: %v", err)
+	}
+
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err == nil {
+		t.Fatalf("expected transfer to a frozen receiver to fail")
+	}
+	if err := c.UpdateMymPoint(ctx, "bob", 10); err == nil {
+		t.Fatalf("expected point update of a frozen user to fail")
+	}
+
+	frozen, err := c.GetFrozenUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetFrozenUsers failed: %v", err)
+	}
+	if len(frozen) != 1 || frozen[0].NickName != "bob" || frozen[0].Actor != "ops" {
+		t.Fatalf("unexpected frozen users %+v", frozen)
+	}
+
+	if _, err := c.UnfreezeUser(ctx, "bob", "cleared", "ops"); err != nil {
+		t.Fatalf("UnfreezeUser failed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("transfer after unfreeze failed: %v", err)
+	}
+}
+
+func TestPauseBlocksWrites(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+
+	if _, err := c.Pause(ctx, "ledger migration", []string{"UpdateMymPoint"}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+
+	for fcn, allowed := range map[string]bool{
+		"TokenERC1155Contract:MintToken": false,
+		"TransferToken":                  false,
+		"UpdateMymPoint":                 true,
+		"GetAllUsers":                    true,
+		"Unpause":                        true,
+	} {
+		stub.function = fcn
+		if err := c.checkPaused(ctx); (err == nil) != allowed {
+			t.Fatalf("checkPaused(%s) = %v, want allowed=%v", fcn, err, allowed)
+		}
+	}
+
+	if _, err := c.Unpause(ctx, "migration finished"); err != nil {
+		t.Fatalf("Unpause failed: %v", err)
+	}
+	stub.function = "MintToken"
+	if err := c.checkPaused(ctx); err != nil {
+		t.Fatalf("writes should be allowed after Unpause: %v", err)
+	}
+}
+
+func TestMigrateUpcastsLegacyRecords(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+
+	// schemaVersion 이 없는 기존 레코드
+	for _, nickName := range []string{"alice", "bob", "carol"} {
+		stub.MockStub.PutState(nickName, []byte(`{"userID":"id-`+nickName+`","nickName":"`+nickName+`","mymPoint":7,"ownedToken":null}`))
+	}
+	tokenKey, _ := stub.CreateCompositeKey(tokenPrefix, []string{"T1"})
+	stub.MockStub.PutState(tokenKey, []byte(`{"tokenNumber":"T1","owner":"alice","tokenType":"ticket"}`))
+
+	user := mustGetUser(t, c, ctx, "bob")
+	if user.SchemaVersion != userSchemaVersion || user.OwnedToken == nil || user.MymPoint != 7 {
+		t.Fatalf("legacy user was not upcast on read: %+v", user)
+	}
+
+	bookmark := ""
+	var progress *MigrationProgress
+	for i := 0; i < 10; i++ {
+		var err error
+		progress, err = c.Migrate(ctx, 1, 2, bookmark)
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if progress.Done {
+			break
+		}
+		bookmark = progress.Bookmark
+	}
+	if !progress.Done || progress.TotalMigrated != 4 {
+		t.Fatalf("unexpected migration progress %+v", progress)
+	}
+	if v := recordSchemaVersion(stub.State["carol"]); v != userSchemaVersion {
+		t.Fatalf("carol stored with schema version %d", v)
+	}
+	if v := recordSchemaVersion(stub.State[tokenKey]); v != tokenSchemaVersion {
+		t.Fatalf("token stored with schema version %d", v)
+	}
+}
+
+func TestValidationReportsFieldErrors(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, _ := newTestContext(t)
+
+	err := c.CreateUserBlock(ctx, "id-1", "", -5, []string{"T1", "T1"})
+	verr, ok := mymberr.Parse(err.Error())
+	if !ok || verr.Code != mymberr.InvalidArgument {
+		t.Fatalf("expected INVALID_ARGUMENT, got %v", err)
+	}
+	fields := map[string]string{}
+	for _, f := range verr.Fields {
+		fields[f.Field] = f.Rule
+	}
+	if fields["nickName"] != "required" || fields["mymPoint"] != "min" || fields["ownedToken[1]"] != "unique" {
+		t.Fatalf("unexpected field errors %+v", verr.Fields)
+	}
+
+	err = c.CreateUserBlock(ctx, "id-1", "alice", 0, []string{"MISSING"})
+	if verr, ok := mymberr.FromError(err); !ok || len(verr.Fields) == 0 || verr.Fields[0].Rule != "exists" {
+		t.Fatalf("expected referential check to fail, got %v", err)
+	}
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	_, err = c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", "javascript:alert(1)")
+	if verr, ok := mymberr.FromError(err); !ok || len(verr.Fields) == 0 || verr.Fields[0].Field != "imageURL" {
+		t.Fatalf("expected imageURL scheme check to fail, got %v", err)
+	}
+}
+
+func TestErrorsCarryCodes(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 100)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	_, errMint := c.MintToken(ctx, "T1", "bob", "C01", "F1", "", "ticket", "sold", "")
+	_, errFreeze := c.FreezeUser(ctx, "bob", "chargeback fraud", "ops")
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.Pause(ctx, "ledger migration", []string{}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	stub.function = "TransferToken"
+
+	for _, tc := range []struct {
+		err   error
+		code  mymberr.Code
+		param string
+		value string
+	}{
+		{c.UpdateMymPoint(ctx, "carol", 10), mymberr.UserNotFound, "nickName", "carol"},
+		{errMint, mymberr.TokenAlreadyExists, "tokenNumber", "T1"},
+		{c.TransferToken(ctx, "bob", "alice", "T1"), mymberr.NotOwner, "tokenNumber", "T1"},
+		{errFreeze, mymberr.Unauthorized, "role", roleAdmin},
+		{c.checkPaused(ctx), mymberr.Paused, "function", "TransferToken"},
+	} {
+		// 클라이언트는 피어가 감싼 메시지 문자열만 받는다
+		decoded, ok := mymberr.Parse("chaincode response 500, " + tc.err.Error())
+		if !ok || decoded.Code != tc.code || decoded.Params[tc.param] != tc.value {
+			t.Fatalf("expected %s with %s=%s, got %v", tc.code, tc.param, tc.value, tc.err)
+		}
+	}
+}
+
+func TestMetadataKeepsVersions(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	imageHash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	attributes := []MetadataAttribute{{TraitType: "seat", Value: "A-12"}}
+	if _, err := c.UpdateMetadata(ctx, "tokenType", "ticket", "Ticket", "", "https://cdn.mymb.io/ticket.png", imageHash, attributes); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected UpdateMetadata to require a role, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "editor", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleMetadataEditor}})
+	first, err := c.UpdateMetadata(ctx, "tokenType", "ticket", "Ticket", "", "https://cdn.mymb.io/ticket.png", imageHash, attributes)
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	nextTx(stub, "tx1")
+	second, err := c.UpdateMetadata(ctx, "tokenType", "ticket", "Ticket", "", "https://cdn.mymb.io/ticket-v2.png", imageHash, attributes)
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if second.Version != 2 || first.ContentHash == second.ContentHash || len(first.ContentHash) != 64 {
+		t.Fatalf("unexpected versions %+v, %+v", first, second)
+	}
+
+	history, err := c.GetMetadataHistory(ctx, "tokenType", "ticket")
+	if err != nil || len(history) != 2 || history[0].Image != "https://cdn.mymb.io/ticket.png" {
+		t.Fatalf("unexpected history %+v, %v", history, err)
+	}
+	previous, err := c.GetMetadataVersion(ctx, "tokenType", "ticket", 1)
+	if err != nil || previous.ContentHash != first.ContentHash {
+		t.Fatalf("unexpected previous version %+v, %v", previous, err)
+	}
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	tokenMetadata, err := c.GetTokenMetadata(ctx, "T1")
+	if err != nil || tokenMetadata.Version != 2 {
+		t.Fatalf("unexpected token metadata %+v, %v", tokenMetadata, err)
+	}
+
+	if _, err := c.SetMetadataURI(ctx, "https://meta.mymb.io/tokens.json"); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a template without {id} to be rejected, got %v", err)
+	}
+	if _, err := c.SetMetadataURI(ctx, "https://meta.mymb.io/{id}.json"); err != nil {
+		t.Fatalf("SetMetadataURI failed: %v", err)
+	}
+	if uri, err := c.URI(ctx, "ticket"); err != nil || uri != "https://meta.mymb.io/ticket.json" {
+		t.Fatalf("unexpected URI %s, %v", uri, err)
+	}
+}
+
+func TestRegistriesValidateMintedCodes(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, _ := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	_, err := c.MintToken(ctx, "T1", "alice", "C99", "F1", "", "poster", "sold", "")
+	verr, ok := mymberr.FromError(err)
+	if !ok || verr.Code != mymberr.InvalidArgument || len(verr.Fields) != 2 {
+		t.Fatalf("expected unknown category and token type to be rejected, got %v", err)
+	}
+
+	if _, err := c.SetCategory(ctx, "C02", "뮤지컬", "Musical", "C01"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected SetCategory to require the admin role, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetCategory(ctx, "C02", "뮤지컬", "Musical", "C01"); err != nil {
+		t.Fatalf("SetCategory failed: %v", err)
+	}
+	if _, err := c.SetCategory(ctx, "C01", "공연", "Performance", "C02"); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a category cycle to be rejected, got %v", err)
+	}
+	if _, err := c.SetCategory(ctx, "C03", "기타", "Other", "C98"); !mymberr.Is(err, mymberr.CategoryNotFound) {
+		t.Fatalf("expected an unknown parent to be rejected, got %v", err)
+	}
+	if _, err := c.SetTokenType(ctx, "poster", "포스터", "Poster", false, true, 0, 0, 0); err != nil {
+		t.Fatalf("SetTokenType failed: %v", err)
+	}
+
+	categories, err := c.GetAllCategories(ctx)
+	if err != nil || len(categories) != 2 {
+		t.Fatalf("unexpected categories %+v, %v", categories, err)
+	}
+	tokenTypes, err := c.GetAllTokenTypes(ctx)
+	if err != nil || len(tokenTypes) != 3 {
+		t.Fatalf("unexpected token types %+v, %v", tokenTypes, err)
+	}
+	if policy, err := c.GetTransferPolicy(ctx, policyScopeTokenType, "poster"); err != nil || !policy.NonTransferable {
+		t.Fatalf("expected the token type transfer policy to be stored, got %+v, %v", policy, err)
+	}
+
+	if _, err := c.MintToken(ctx, "T1", "alice", "C02", "F1", "", "poster", "sold", ""); err != nil {
+		t.Fatalf("MintToken with registered codes failed: %v", err)
+	}
+
+	// 이름만 고치는 수정은 기존 전송 정책을 지우지 않는다
+	if _, err := c.SetTokenType(ctx, "poster", "포스터", "Art poster", false, false, 0, 0, 0); err != nil {
+		t.Fatalf("SetTokenType failed: %v", err)
+	}
+	if policy, err := c.GetTransferPolicy(ctx, policyScopeTokenType, "poster"); err != nil || !policy.NonTransferable {
+		t.Fatalf("expected the token type transfer policy to be kept, got %+v, %v", policy, err)
+	}
+
+	if _, err := c.SetTokenType(ctx, "coin", "코인", "Coin", true, false, 0, 0, 0); err != nil {
+		t.Fatalf("SetTokenType failed: %v", err)
+	}
+	if _, err := c.MintToken(ctx, "T2", "alice", "C02", "F1", "", "coin", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	allocations := []FractionAllocation{{NickName: "alice", Shares: 10}}
+	if _, err := c.FractionalizeToken(ctx, "P1", "T2", "alice", allocations, 5000); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a fungible token to be rejected by FractionalizeToken, got %v", err)
+	}
+}
+
+func TestAirdropIsResumable(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	grants := []AirdropGrant{
+		{NickName: "alice", MymPoint: 100},
+		{NickName: "bob", MymPoint: 50, TokenNumber: "GIFT1", CategoryCode: "C01", TokenType: "badge"},
+		{NickName: "carol", MymPoint: 10},
+	}
+
+	if _, err := c.Airdrop(ctx, "launch", grants, true); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected Airdrop to require a role, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "marketing", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAirdrop}})
+
+	if _, err := c.Airdrop(ctx, "launch", grants, false); !mymberr.Is(err, mymberr.UserNotFound) {
+		t.Fatalf("expected the batch to abort on the unknown user, got %v", err)
+	}
+
+	// 실패한 배치는 피어에서 커밋되지 않으므로 새 상태에서 다시 시작한다
+	ctx, stub = newTestContext(t)
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	ctx.SetClientIdentity(&testIdentity{id: "marketing", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAirdrop}})
+
+	result, err := c.Airdrop(ctx, "launch", grants, true)
+	if err != nil {
+		t.Fatalf("Airdrop failed: %v", err)
+	}
+	if result.Granted != 2 || result.Failed != 1 || result.Results[2].ErrorCode != string(mymberr.UserNotFound) {
+		t.Fatalf("unexpected airdrop result %+v", result)
+	}
+
+	nextTx(stub, "tx1")
+	mustCreateUser(t, c, ctx, "carol", 0)
+	nextTx(stub, "tx2")
+	result, err = c.Airdrop(ctx, "launch", grants, true)
+	if err != nil {
+		t.Fatalf("resumed Airdrop failed: %v", err)
+	}
+	if result.Granted != 1 || result.Skipped != 2 || result.Campaign.Granted != 3 || result.Campaign.Batches != 2 {
+		t.Fatalf("unexpected resumed airdrop result %+v", result)
+	}
+
+	if alice := mustGetUser(t, c, ctx, "alice"); alice.MymPoint != 100 {
+		t.Fatalf("alice should be granted once, has %d", alice.MymPoint)
+	}
+	if bob := mustGetUser(t, c, ctx, "bob"); bob.MymPoint != 50 || len(bob.OwnedToken) != 1 {
+		t.Fatalf("unexpected bob %+v", bob)
+	}
+	if carol := mustGetUser(t, c, ctx, "carol"); carol.MymPoint != 10 {
+		t.Fatalf("carol should be granted on resume, has %d", carol.MymPoint)
+	}
+}
+
+func TestAirdropRejectsOverflowingGrants(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "whale", math.MaxInt64-10)
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	ctx.SetClientIdentity(&testIdentity{id: "marketing", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAirdrop}})
+
+	if _, err := c.Airdrop(ctx, "launch", []AirdropGrant{{NickName: "whale", MymPoint: 100}}, false); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a grant overflowing the user balance to be rejected, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	result, err := c.Airdrop(ctx, "launch", []AirdropGrant{{NickName: "alice", MymPoint: math.MaxInt64}}, false)
+	if err != nil || result.Campaign.TotalMymPoint != math.MaxInt64 {
+		t.Fatalf("unexpected airdrop result %+v, %v", result, err)
+	}
+	nextTx(stub, "tx2")
+	result, err = c.Airdrop(ctx, "launch", []AirdropGrant{{NickName: "bob", MymPoint: 1}}, true)
+	if err != nil || result.Failed != 1 || result.Results[0].ErrorCode != string(mymberr.InvalidArgument) {
+		t.Fatalf("expected a grant overflowing the campaign total to fail, got %+v, %v", result, err)
+	}
+	if bob := mustGetUser(t, c, ctx, "bob"); bob.MymPoint != 0 {
+		t.Fatalf("bob should not be granted, has %d", bob.MymPoint)
+	}
+}
+
+func TestSnapshotProofsVerifyOffline(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 70)
+	mustCreateUser(t, c, ctx, "bob", 30)
+	for _, tokenNumber := range []string{"T1", "T2", "T3"} {
+		if _, err := c.MintToken(ctx, tokenNumber, "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T2"); err != nil {
+		t.Fatalf("TransferToken failed: %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	ownership, err := c.TakeSnapshot(ctx, "S1", "ownership")
+	if err != nil || ownership.LeafCount != 3 {
+		t.Fatalf("unexpected ownership snapshot %+v, %v", ownership, err)
+	}
+	if _, err := c.TakeSnapshot(ctx, "S2", "balance"); err != nil {
+		t.Fatalf("TakeSnapshot failed: %v", err)
+	}
+	if _, err := c.TakeSnapshot(ctx, "S1", "balance"); !mymberr.Is(err, mymberr.SnapshotExists) {
+		t.Fatalf("expected a duplicate snapshot ID to be rejected, got %v", err)
+	}
+
+	// 스냅샷 이후의 전송은 증명에 영향을 주지 않는다
+	nextTx(stub, "tx1")
+	if err := c.TransferToken(ctx, "bob", "alice", "T2"); err != nil {
+		t.Fatalf("TransferToken failed: %v", err)
+	}
+
+	proof, err := c.GetOwnershipProof(ctx, "S1", "bob", "T2")
+	if err != nil {
+		t.Fatalf("GetOwnershipProof failed: %v", err)
+	}
+	if ok, err := merkle.VerifyOwnership(ownership.Root, "bob", "T2", proof.Proof); err != nil || !ok {
+		t.Fatalf("ownership proof did not verify: %v", err)
+	}
+	if _, err := c.GetOwnershipProof(ctx, "S1", "alice", "T2"); !mymberr.Is(err, mymberr.SnapshotLeafNotFound) {
+		t.Fatalf("expected no proof for a pair outside the snapshot, got %v", err)
+	}
+
+	balance, err := c.GetBalanceProof(ctx, "S2", "alice")
+	if err != nil {
+		t.Fatalf("GetBalanceProof failed: %v", err)
+	}
+	if ok, err := merkle.VerifyBalance(balance.Root, "alice", 70, balance.Proof); err != nil || !ok {
+		t.Fatalf("balance proof did not verify: %v", err)
+	}
+	if ok, _ := merkle.VerifyBalance(balance.Root, "alice", 71, balance.Proof); ok {
+		t.Fatalf("balance proof verified a wrong amount")
+	}
+}
+
+func TestReferralSettlement(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	if _, err := c.RecordReferral(ctx, "P1", "bob", "alice", 1000, true); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected RecordReferral to require a role, got %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleReferral}})
+
+	for _, r := range []struct {
+		payID    string
+		from     string
+		payback  int64
+		complete bool
+	}{
+		{"P1", "bob", 1000, true},
+		{"P2", "carol", 500, true},
+		{"P3", "dave", 300, false},
+	} {
+		if _, err := c.RecordReferral(ctx, r.payID, r.from, "alice", r.payback, r.complete); err != nil {
+			t.Fatalf("RecordReferral(%s) failed: %v", r.payID, err)
+		}
+	}
+	if _, err := c.RecordReferral(ctx, "P1", "bob", "alice", 1000, true); !mymberr.Is(err, mymberr.ReferralExists) {
+		t.Fatalf("expected a duplicate payId to be rejected, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if _, err := c.SettleReferrals(ctx, []string{"P1", "P3"}, "bank-20261019-01"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected settlement of an incomplete base payment to fail, got %v", err)
+	}
+	settled, err := c.SettleReferrals(ctx, []string{"P1"}, "bank-20261019-01")
+	if err != nil || len(settled) != 1 || settled[0].PayoutReference != "bank-20261019-01" {
+		t.Fatalf("unexpected settlement %+v, %v", settled, err)
+	}
+
+	nextTx(stub, "tx2")
+	if _, err := c.SettleReferrals(ctx, []string{"P1"}, "bank-20261019-02"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected a paid referral not to be settled twice, got %v", err)
+	}
+
+	totals, err := c.GetReferralTotals(ctx, "alice")
+	if err != nil || totals.Paid != 1000 || totals.Owed != 500 || totals.Pending != 300 || totals.Count != 3 {
+		t.Fatalf("unexpected totals %+v, %v", totals, err)
+	}
+	owed, err := c.GetOwedReferralTotals(ctx)
+	if err != nil || len(owed) != 1 || owed[0].NickName != "alice" || owed[0].Owed != 500 {
+		t.Fatalf("unexpected owed totals %+v, %v", owed, err)
+	}
+}
+
+func TestMultiLevelReferralPaybacks(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	for _, nickName := range []string{"root", "alice", "bob", "carol"} {
+		mustCreateUser(t, c, ctx, nickName, 0)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+
+	// root <- alice <- bob <- carol
+	for _, pair := range [][2]string{{"alice", "root"}, {"bob", "alice"}, {"carol", "bob"}} {
+		if _, err := c.SetInviter(ctx, pair[0], pair[1]); err != nil {
+			t.Fatalf("SetInviter(%s, %s) failed: %v", pair[0], pair[1], err)
+		}
+	}
+	if _, err := c.SetInviter(ctx, "bob", "root"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected the inviter to be immutable, got %v", err)
+	}
+	if _, err := c.SetInviter(ctx, "root", "carol"); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a cycle to be rejected, got %v", err)
+	}
+
+	if _, err := c.RecordFundingPayment(ctx, "P1", "carol", 10000, true); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected payment without a rate table to fail, got %v", err)
+	}
+	if _, err := c.SetReferralRates(ctx, []int64{math.MaxInt64, math.MaxInt64, 2}); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected rates overflowing the total to be rejected, got %v", err)
+	}
+	if _, err := c.SetReferralRates(ctx, []int64{500, 200}); err != nil {
+		t.Fatalf("SetReferralRates failed: %v", err)
+	}
+	if _, err := c.RecordFundingPayment(ctx, "P0", "carol", math.MaxInt64/100, true); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an amount overflowing the payback to be rejected, got %v", err)
+	}
+
+	payment, err := c.RecordFundingPayment(ctx, "P1", "carol", 10000, true)
+	if err != nil {
+		t.Fatalf("RecordFundingPayment failed: %v", err)
+	}
+	if len(payment.Referrals) != 2 || payment.Referrals[0].ReferralTo != "bob" || payment.Referrals[0].ReferralPayback != 500 ||
+		payment.Referrals[1].ReferralTo != "alice" || payment.Referrals[1].ReferralPayback != 200 {
+		t.Fatalf("unexpected paybacks %+v", payment.Referrals)
+	}
+	if _, err := c.RecordFundingPayment(ctx, "P1", "carol", 10000, true); !mymberr.Is(err, mymberr.PaymentExists) {
+		t.Fatalf("expected a duplicate payment to be rejected, got %v", err)
+	}
+	if _, err := c.RecordReferral(ctx, "P2-L2", "carol", "alice", 100, true); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a payId in the generated payback namespace to be rejected, got %v", err)
+	}
+	if _, err := c.RecordFundingPayment(ctx, "P2-L1", "carol", 10000, true); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a payment payId in the generated payback namespace to be rejected, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if _, err := c.SettleReferrals(ctx, []string{"P1-L1"}, "bank-01"); err != nil {
+		t.Fatalf("SettleReferrals failed: %v", err)
+	}
+	bob, err := c.GetReferralBalance(ctx, "bob")
+	if err != nil || bob.Pending != 0 || bob.Paid != 500 {
+		t.Fatalf("unexpected balance for bob %+v, %v", bob, err)
+	}
+	alice, err := c.GetReferralBalance(ctx, "alice")
+	if err != nil || alice.Pending != 200 || alice.Paid != 0 {
+		t.Fatalf("unexpected balance for alice %+v, %v", alice, err)
+	}
+	if invitees, err := c.GetInvitees(ctx, "alice"); err != nil || len(invitees) != 1 || invitees[0] != "bob" {
+		t.Fatalf("unexpected invitees %v, %v", invitees, err)
+	}
+}
+
+func TestTrustGraph(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	for _, nickName := range []string{"alice", "bob", "carol"} {
+		mustCreateUser(t, c, ctx, nickName, 0)
+	}
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	if err := c.GiftToken(ctx, "alice", "bob", "T1"); !mymberr.Is(err, mymberr.NotTrusted) {
+		t.Fatalf("expected a gift to an untrusted user to fail, got %v", err)
+	}
+
+	for _, pair := range [][2]string{{"alice", "bob"}, {"bob", "alice"}, {"alice", "carol"}} {
+		if _, err := c.TrustUser(ctx, pair[0], pair[1]); err != nil {
+			t.Fatalf("TrustUser(%s, %s) failed: %v", pair[0], pair[1], err)
+		}
+	}
+	if _, err := c.TrustUser(ctx, "alice", "bob"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected a duplicate trust to be rejected, got %v", err)
+	}
+
+	if mutual, err := c.GetMutualTrusts(ctx, "alice"); err != nil || len(mutual) != 1 || mutual[0] != "bob" {
+		t.Fatalf("unexpected mutual trusts %v, %v", mutual, err)
+	}
+	if trustedBy, err := c.GetTrustedByUsers(ctx, "carol"); err != nil || len(trustedBy) != 1 || trustedBy[0] != "alice" {
+		t.Fatalf("unexpected trusted-by users %v, %v", trustedBy, err)
+	}
+
+	if err := c.GiftToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("GiftToken failed: %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.UntrustUser(ctx, "bob", "alice"); err != nil {
+		t.Fatalf("UntrustUser failed: %v", err)
+	}
+	if mutual, err := c.IsMutualTrust(ctx, "alice", "bob"); err != nil || mutual {
+		t.Fatalf("expected trust to be one-way after UntrustUser, got %v, %v", mutual, err)
+	}
+	if err := c.GiftToken(ctx, "bob", "alice", "T1"); !mymberr.Is(err, mymberr.NotTrusted) {
+		t.Fatalf("expected the gift back to fail after UntrustUser, got %v", err)
+	}
+}
+
+func TestKYCRequirements(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	for _, nickName := range []string{"alice", "bob"} {
+		mustCreateUser(t, c, ctx, nickName, 0)
+	}
+	expiry := stub.TxTimestamp.Seconds + 3600
+
+	if _, err := c.AttestKYC(ctx, "alice", 2, "kcb", expiry); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected AttestKYC without the attestor role to fail, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetKYCRequirement(ctx, policyScopeFunding, "F1", 2); err != nil {
+		t.Fatalf("SetKYCRequirement failed: %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "kcb", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAttestor}})
+	if _, err := c.AttestKYC(ctx, "alice", 2, "kcb", stub.TxTimestamp.Seconds); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an expired attestation to be rejected, got %v", err)
+	}
+	if _, err := c.AttestKYC(ctx, "alice", 2, "kcb", expiry); err != nil {
+		t.Fatalf("AttestKYC failed: %v", err)
+	}
+	if _, err := c.AttestKYC(ctx, "bob", 1, "kcb", expiry); err != nil {
+		t.Fatalf("AttestKYC failed: %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+	_, err := c.MintToken(ctx, "T1", "bob", "C01", "F1", "", "ticket", "sold", "")
+	if e, ok := mymberr.FromError(err); !ok || e.Code != mymberr.KYCRequired || e.Params["requiredLevel"] != "2" || e.Params["level"] != "1" {
+		t.Fatalf("expected minting to an under-verified user to fail with KYC_REQUIRED, got %v", err)
+	}
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); !mymberr.Is(err, mymberr.KYCRequired) {
+		t.Fatalf("expected a transfer to an under-verified user to fail, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "kcb", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAttestor}})
+	if _, err := c.RevokeKYC(ctx, "alice", "document expired"); err != nil {
+		t.Fatalf("RevokeKYC failed: %v", err)
+	}
+	if level, err := c.GetKYCLevel(ctx, "alice"); err != nil || level != 0 {
+		t.Fatalf("expected a revoked attestation to have level 0, got %d, %v", level, err)
+	}
+	if kyc, err := c.GetKYC(ctx, "alice"); err != nil || !kyc.Revoked || kyc.Issuer != "kcb" {
+		t.Fatalf("unexpected KYC record %+v, %v", kyc, err)
+	}
+	if _, err := c.GetKYC(ctx, "carol"); !mymberr.Is(err, mymberr.KYCNotFound) {
+		t.Fatalf("expected KYC_NOT_FOUND, got %v", err)
+	}
+}
+
+func TestUserPIIStaysPrivate(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+	mustCreateUser(t, c, ctx, "alice", 0)
+
+	pii := `{"realName":"김앨리스","phoneNumber":"010-1234-5678","bankCode":"KB","bankAccount":"123-456-789012","salt":"0f1e2d3c4b5a69788796a5b4c3d2e1f0"}`
+	stub.transient = map[string][]byte{piiTransientKey: []byte(pii)}
+
+	if _, err := c.SetUserPII(ctx, "alice"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected SetUserPII without the pii role to fail, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "payout", mspID: testMSPID, attrs: map[string]string{roleAttribute: rolePII}})
+	user, err := c.SetUserPII(ctx, "alice")
+	if err != nil {
+		t.Fatalf("SetUserPII failed: %v", err)
+	}
+	if user.PIIHash == "" || strings.Contains(string(stub.State["alice"]), "김앨리스") {
+		t.Fatalf("public user block must only carry the pii hash: %s", stub.State["alice"])
+	}
+
+	if check, err := c.VerifyUserPIIHash(ctx, "alice"); err != nil || !check.Matches {
+		t.Fatalf("expected the public hash to match the collection, got %+v, %v", check, err)
+	}
+	if stored, err := c.GetUserPII(ctx, "alice"); err != nil || stored.BankAccount != "123-456-789012" {
+		t.Fatalf("unexpected pii %+v, %v", stored, err)
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.PurgeUserPII(ctx, "alice", "erasure request"); err != nil {
+		t.Fatalf("PurgeUserPII failed: %v", err)
+	}
+	if _, err := c.GetUserPII(ctx, "alice"); !mymberr.Is(err, mymberr.PIINotFound) {
+		t.Fatalf("expected purged pii to be gone, got %v", err)
+	}
+	if user := mustGetUser(t, c, ctx, "alice"); user.PIIHash != "" {
+		t.Fatalf("expected the public hash to be cleared, got %s", user.PIIHash)
+	}
+}
+
+func TestEnterpriseSpendingLimits(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "acme-pool", 1000)
+	for _, nickName := range []string{"boss", "emp1", "emp2"} {
+		mustCreateUser(t, c, ctx, nickName, 0)
+	}
+	for _, tokenNumber := range []string{"T1", "T2"} {
+		if _, err := c.MintToken(ctx, tokenNumber, "acme-pool", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.CreateEnterprise(ctx, "acme", "Acme", "acme-pool", "boss"); err != nil {
+		t.Fatalf("CreateEnterprise failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	if _, err := c.AddEnterpriseMember(ctx, "acme", "boss", "emp1", enterpriseRoleMember, 300, 1); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a client without the enterprise role to be rejected, got %v", err)
+	}
+	// 공용 풀은 배분 함수로만 나누어 줄 수 있다
+	if err := c.TransferToken(ctx, "acme-pool", "emp2", "T1"); !mymberr.Is(err, mymberr.TransferRestricted) {
+		t.Fatalf("expected a direct transfer from the pool to be rejected, got %v", err)
+	}
+	if err := c.TransferAllTokens(ctx, "acme-pool", "emp2"); !mymberr.Is(err, mymberr.TransferRestricted) {
+		t.Fatalf("expected a direct transfer of all pool tokens to be rejected, got %v", err)
+	}
+	if err := c.UpdateMymPoint(ctx, "acme-pool", -100); !mymberr.Is(err, mymberr.TransferRestricted) {
+		t.Fatalf("expected a direct debit from the pool to be rejected, got %v", err)
+	}
+	if _, err := c.CreateAuction(ctx, "A1", "T1"); !mymberr.Is(err, mymberr.TransferRestricted) {
+		t.Fatalf("expected an auction of a pool token to be rejected, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleEnterprise}})
+	if _, err := c.AddEnterpriseMember(ctx, "acme", "emp1", "emp2", enterpriseRoleMember, 100, 1); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a non-admin to be rejected, got %v", err)
+	}
+	if _, err := c.AddEnterpriseMember(ctx, "acme", "boss", "emp1", enterpriseRoleMember, 300, 1); err != nil {
+		t.Fatalf("AddEnterpriseMember failed: %v", err)
+	}
+	if _, err := c.AddEnterpriseMember(ctx, "acme", "boss", "acme-pool", enterpriseRoleMember, 0, 0); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected the pool account to be rejected as a member, got %v", err)
+	}
+
+	if _, err := c.AllocateEnterpriseToken(ctx, "acme", "boss", "emp1", "T1"); err != nil {
+		t.Fatalf("AllocateEnterpriseToken failed: %v", err)
+	}
+	nextTx(stub, "tx1")
+	if _, err := c.AllocateEnterpriseToken(ctx, "acme", "boss", "emp1", "T2"); !mymberr.Is(err, mymberr.SpendingLimitExceeded) {
+		t.Fatalf("expected the token limit to be enforced, got %v", err)
+	}
+	if _, err := c.AllocateEnterprisePoints(ctx, "acme", "boss", "emp1", 200); err != nil {
+		t.Fatalf("AllocateEnterprisePoints failed: %v", err)
+	}
+	nextTx(stub, "tx2")
+	if _, err := c.AllocateEnterprisePoints(ctx, "acme", "boss", "emp1", 200); !mymberr.Is(err, mymberr.SpendingLimitExceeded) {
+		t.Fatalf("expected the spending limit to be enforced, got %v", err)
+	}
+	if _, err := c.SetEnterpriseMemberLimits(ctx, "acme", "boss", "emp1", 100, 1); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected a limit below the spent amount to be rejected, got %v", err)
+	}
+
+	holdings, err := c.GetEnterpriseHoldings(ctx, "acme")
+	if err != nil {
+		t.Fatalf("GetEnterpriseHoldings failed: %v", err)
+	}
+	if holdings.PoolPoints != 800 || holdings.MemberPoints != 200 || holdings.TotalPoints != 1000 ||
+		len(holdings.PoolTokens) != 1 || holdings.MemberTokens != 1 || holdings.TotalTokens != 2 || len(holdings.Members) != 2 {
+		t.Fatalf("unexpected holdings %+v", holdings)
+	}
+
+	if err := c.RemoveEnterpriseMember(ctx, "acme", "boss", "boss"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected removing the last admin to fail, got %v", err)
+	}
+	if err := c.RemoveEnterpriseMember(ctx, "acme", "boss", "emp1"); err != nil {
+		t.Fatalf("RemoveEnterpriseMember failed: %v", err)
+	}
+	if enterpriseID, err := c.GetUserEnterprise(ctx, "emp1"); err != nil || enterpriseID != "" {
+		t.Fatalf("expected emp1 to leave the enterprise, got %q, %v", enterpriseID, err)
+	}
+}
+
+func TestLentTokenIsReclaimedAfterDeadline(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	for _, nickName := range []string{"venue", "bob", "carol"} {
+		mustCreateUser(t, c, ctx, nickName, 0)
+	}
+	if _, err := c.MintToken(ctx, "B1", "venue", "C01", "", "", "badge", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	until := stub.TxTimestamp.Seconds + 3600
+	if _, err := c.LendToken(ctx, "B1", "bob", until); err != nil {
+		t.Fatalf("LendToken failed: %v", err)
+	}
+	if _, err := c.LendToken(ctx, "B1", "carol", until); !mymberr.Is(err, mymberr.TokenLocked) {
+		t.Fatalf("expected a lent token to be locked, got %v", err)
+	}
+	if user, err := c.GetTokenUser(ctx, "B1"); err != nil || user != "bob" {
+		t.Fatalf("expected bob to be the token user, got %q, %v", user, err)
+	}
+	if err := c.TransferToken(ctx, "bob", "carol", "B1"); !mymberr.Is(err, mymberr.NotOwner) {
+		t.Fatalf("expected the borrower to be unable to transfer, got %v", err)
+	}
+	if err := c.TransferToken(ctx, "venue", "carol", "B1"); !mymberr.Is(err, mymberr.TokenLocked) {
+		t.Fatalf("expected the owner to be unable to transfer a lent token, got %v", err)
+	}
+	if _, err := c.ReclaimToken(ctx, "B1", "venue"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected reclaiming before the deadline to fail, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	stub.TxTimestamp.Seconds = until
+	if user, err := c.GetTokenUser(ctx, "B1"); err != nil || user != "venue" {
+		t.Fatalf("expected the owner to be the token user after the deadline, got %q, %v", user, err)
+	}
+	if _, err := c.ReclaimToken(ctx, "B1", "carol"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a third party to be unable to reclaim, got %v", err)
+	}
+	if _, err := c.ReclaimToken(ctx, "B1", "bob"); err != nil {
+		t.Fatalf("ReclaimToken failed: %v", err)
+	}
+	if loans, err := c.GetBorrowedTokens(ctx, "bob"); err != nil || len(loans) != 0 {
+		t.Fatalf("expected no borrowed tokens after reclaim, got %v, %v", loans, err)
+	}
+	if err := c.TransferToken(ctx, "venue", "carol", "B1"); err != nil {
+		t.Fatalf("TransferToken after reclaim failed: %v", err)
+	}
+}
+
+func TestFractionPoolBuyout(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	mustCreateUser(t, c, ctx, "carol", 1000)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	allocations := []FractionAllocation{{NickName: "alice", Shares: 50}, {NickName: "bob", Shares: 30}, {NickName: "carol", Shares: 20}}
+	if _, err := c.FractionalizeToken(ctx, "P1", "T1", "alice", allocations, 6000); err != nil {
+		t.Fatalf("FractionalizeToken failed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); !mymberr.Is(err, mymberr.TokenLocked) {
+		t.Fatalf("expected a pooled token to be locked, got %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.TransferShares(ctx, "P1", "bob", "carol", 10); err != nil {
+		t.Fatalf("TransferShares failed: %v", err)
+	}
+	if _, err := c.RedeemToken(ctx, "P1", "carol"); !mymberr.Is(err, mymberr.InsufficientShares) {
+		t.Fatalf("expected redeeming without every share to fail, got %v", err)
+	}
+
+	// carol 은 30 지분을 가지고 나머지 70 지분을 지분당 10 에 사들이려 한다
+	nextTx(stub, "tx2")
+	offer, err := c.OfferBuyout(ctx, "P1", "carol", 10)
+	if err != nil {
+		t.Fatalf("OfferBuyout failed: %v", err)
+	}
+	if offer.Escrow != 700 || mustGetUser(t, c, ctx, "carol").MymPoint != 300 {
+		t.Fatalf("unexpected escrow %+v", offer)
+	}
+	if err := c.TransferShares(ctx, "P1", "bob", "alice", 5); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected shares to be locked during a buyout, got %v", err)
+	}
+
+	// carol 30 + bob 20 = 50% 로는 기준 60% 에 못 미친다
+	nextTx(stub, "tx3")
+	if offer, err = c.AcceptBuyout(ctx, "P1", "bob"); err != nil || offer.Status != buyoutOfferOpen {
+		t.Fatalf("expected the offer to stay open, got %+v, %v", offer, err)
+	}
+	// 대금을 받을 bob 이 동결되어 있으면 매수를 실행하지 않는다
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	nextTx(stub, "tx4")
+	if _, err := c.FreezeUser(ctx, "bob", "chargeback fraud", "ops"); err != nil {
+		t.Fatalf("FreezeUser failed: %v", err)
+	}
+	if _, err := c.AcceptBuyout(ctx, "P1", "alice"); !mymberr.Is(err, mymberr.UserFrozen) {
+		t.Fatalf("expected the buyout not to pay a frozen holder, got %v", err)
+	}
+	nextTx(stub, "tx5")
+	if _, err := c.UnfreezeUser(ctx, "bob", "resolved", "ops"); err != nil {
+		t.Fatalf("UnfreezeUser failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	nextTx(stub, "tx6")
+	if offer, err = c.AcceptBuyout(ctx, "P1", "alice"); err != nil || offer.Status != buyoutOfferExecuted {
+		t.Fatalf("expected the offer to execute, got %+v, %v", offer, err)
+	}
+
+	if token, err := c.GetToken(ctx, "T1"); err != nil || token.Owner != "carol" {
+		t.Fatalf("expected carol to own the token, got %+v, %v", token, err)
+	}
+	alice, bob, carol := mustGetUser(t, c, ctx, "alice"), mustGetUser(t, c, ctx, "bob"), mustGetUser(t, c, ctx, "carol")
+	if alice.MymPoint != 500 || bob.MymPoint != 200 || carol.MymPoint != 300 || contains(alice.OwnedToken, "T1") || !contains(carol.OwnedToken, "T1") {
+		t.Fatalf("unexpected balances alice=%+v bob=%+v carol=%+v", alice, bob, carol)
+	}
+	if shares, err := c.GetFractionShares(ctx, "P1"); err != nil || len(shares) != 0 {
+		t.Fatalf("expected no shares after the buyout, got %v, %v", shares, err)
+	}
+	nextTx(stub, "tx7")
+	if err := c.TransferToken(ctx, "carol", "bob", "T1"); err != nil {
+		t.Fatalf("expected the token to be unlocked after the buyout, got %v", err)
+	}
+}
+
+func TestFractionPoolCancelAndRedeem(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 1000)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	// 두 지분의 합은 int64 를 넘어 음수가 되지만 한도 확인을 통과하면 안 된다
+	overflowing := []FractionAllocation{{NickName: "alice", Shares: math.MaxInt64}, {NickName: "bob", Shares: math.MaxInt64}}
+	if _, err := c.FractionalizeToken(ctx, "P1", "T1", "alice", overflowing, 6000); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected overflowing allocations to be rejected, got %v", err)
+	}
+	allocations := []FractionAllocation{{NickName: "alice", Shares: 70}, {NickName: "bob", Shares: 30}}
+	if _, err := c.FractionalizeToken(ctx, "P1", "T1", "alice", allocations, 6000); err != nil {
+		t.Fatalf("FractionalizeToken failed: %v", err)
+	}
+
+	// 70 지분 x 1.32e17 은 int64 를 넘어 음수 에스크로가 된다
+	nextTx(stub, "tx1")
+	if _, err := c.OfferBuyout(ctx, "P1", "bob", 132000000000000000); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an overflowing buyout price to be rejected, got %v", err)
+	}
+	if _, err := c.OfferBuyout(ctx, "P1", "bob", 10); err != nil {
+		t.Fatalf("OfferBuyout failed: %v", err)
+	}
+
+	nextTx(stub, "tx2")
+	if bob := mustGetUser(t, c, ctx, "bob"); bob.MymPoint != 300 {
+		t.Fatalf("expected 700 MymPoint in escrow, bob has %d", bob.MymPoint)
+	}
+	if _, err := c.CancelBuyout(ctx, "P1", "alice"); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected only the buyer to cancel, got %v", err)
+	}
+	offer, err := c.CancelBuyout(ctx, "P1", "bob")
+	if err != nil || offer.Status != buyoutOfferCancelled || offer.Escrow != 0 {
+		t.Fatalf("expected the offer to be cancelled, got %+v, %v", offer, err)
+	}
+
+	nextTx(stub, "tx3")
+	if bob := mustGetUser(t, c, ctx, "bob"); bob.MymPoint != 1000 {
+		t.Fatalf("expected the escrow to be refunded, bob has %d", bob.MymPoint)
+	}
+	if err := c.TransferShares(ctx, "P1", "bob", "alice", 30); err != nil {
+		t.Fatalf("TransferShares failed: %v", err)
+	}
+
+	nextTx(stub, "tx4")
+	pool, err := c.RedeemToken(ctx, "P1", "alice")
+	if err != nil || pool.Status != fractionPoolRedeemed {
+		t.Fatalf("expected the pool to be redeemed, got %+v, %v", pool, err)
+	}
+
+	nextTx(stub, "tx5")
+	if token, err := c.GetToken(ctx, "T1"); err != nil || token.Owner != "alice" {
+		t.Fatalf("expected alice to keep the token, got %+v, %v", token, err)
+	}
+	if shares, err := c.GetFractionShares(ctx, "P1"); err != nil || len(shares) != 0 {
+		t.Fatalf("expected no shares after redeeming, got %v, %v", shares, err)
+	}
+	if err := c.TransferToken(ctx, "alice", "bob", "T1"); err != nil {
+		t.Fatalf("expected the token to be unlocked after redeeming, got %v", err)
+	}
+}
+
+func TestLeaderboards(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	// 배포 뒤 빈 원장에서 인덱스를 만들어 두면 이후 순위는 인덱스로 조회한다
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.RebuildLeaderboards(ctx); err != nil {
+		t.Fatalf("RebuildLeaderboards failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	nextTx(stub, "tx0")
+	mustCreateUser(t, c, ctx, "alice", 100)
+	mustCreateUser(t, c, ctx, "bob", 300)
+	mustCreateUser(t, c, ctx, "carol", 200)
+	for tokenNumber, owner := range map[string]string{"T0": "alice", "T1": "alice", "T2": "bob"} {
+		if _, err := c.MintToken(ctx, tokenNumber, owner, "C01", "", "", "ticket", "sold", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.UpdateMymPoint(ctx, "alice", 250); err != nil {
+		t.Fatalf("UpdateMymPoint failed: %v", err)
+	}
+	if err := c.TransferToken(ctx, "alice", "carol", "T0"); err != nil {
+		t.Fatalf("TransferToken failed: %v", err)
+	}
+
+	nextTx(stub, "tx2")
+	top, err := c.TopUsersByPoints(ctx, 2)
+	if err != nil {
+		t.Fatalf("TopUsersByPoints failed: %v", err)
+	}
+	if len(top) != 2 || top[0].NickName != "alice" || top[0].Score != 350 || top[1].NickName != "bob" || top[1].Rank != 2 {
+		t.Fatalf("unexpected points leaderboard %+v", top)
+	}
+
+	holders, err := c.TopHoldersByCategory(ctx, "C01", 10)
+	if err != nil {
+		t.Fatalf("TopHoldersByCategory failed: %v", err)
+	}
+	if len(holders) != 3 || holders[0].NickName != "alice" || holders[0].Score != 1 || holders[1].NickName != "bob" || holders[2].NickName != "carol" {
+		t.Fatalf("unexpected category leaderboard %+v", holders)
+	}
+
+	if _, err := c.TopUsersByPoints(ctx, maxLeaderboardSize+1); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an oversized leaderboard to be rejected, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	rebuild, err := c.RebuildLeaderboards(ctx)
+	if err != nil {
+		t.Fatalf("RebuildLeaderboards failed: %v", err)
+	}
+	if rebuild.Users != 3 || rebuild.Holdings != 3 {
+		t.Fatalf("unexpected rebuild result %+v", rebuild)
+	}
+
+	nextTx(stub, "tx3")
+	rebuilt, err := c.TopHoldersByCategory(ctx, "C01", 10)
+	if err != nil {
+		t.Fatalf("TopHoldersByCategory failed: %v", err)
+	}
+	if len(rebuilt) != len(holders) {
+		t.Fatalf("rebuild changed the leaderboard: %+v, want %+v", rebuilt, holders)
+	}
+	for i := range holders {
+		if rebuilt[i] != holders[i] {
+			t.Fatalf("rebuild changed the leaderboard: %+v, want %+v", rebuilt, holders)
+		}
+	}
+}
+
+func TestAggregateCountersTrackMutations(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.RebuildCounters(ctx); err != nil {
+		t.Fatalf("RebuildCounters failed: %v", err)
+	}
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+
+	nextTx(stub, "tx0")
+	mustCreateUser(t, c, ctx, "alice", 100)
+	nextTx(stub, "tx1")
+	mustCreateUser(t, c, ctx, "bob", 50)
+	nextTx(stub, "tx2")
+	for tokenNumber, fundingID := range map[string]string{"T1": "F1", "T2": "F1", "T3": "F2"} {
+		if _, err := c.MintToken(ctx, tokenNumber, "alice", "C01", fundingID, "", "ticket", "sold", ""); err != nil {
+			t.Fatalf("MintToken failed: %v", err)
+		}
+	}
+	nextTx(stub, "tx3")
+	if err := c.UpdateSellStage(ctx, "T1", "used"); err != nil {
+		t.Fatalf("UpdateSellStage failed: %v", err)
+	}
+	nextTx(stub, "tx4")
+	if err := c.UpdateMymPoint(ctx, "bob", -20); err != nil {
+		t.Fatalf("UpdateMymPoint failed: %v", err)
+	}
+	nextTx(stub, "tx5")
+	if err := c.DeleteTokens(ctx, "alice", []string{"T3"}); err != nil {
+		t.Fatalf("DeleteTokens failed: %v", err)
+	}
+
+	nextTx(stub, "tx6")
+	want := AggregateStats{
+		TotalTokens:       2,
+		TotalUsers:        2,
+		TotalPoints:       130,
+		TokensByFunding:   map[string]int64{"F1": 2},
+		TokensByCategory:  map[string]int64{"C01": 2},
+		TokensBySellStage: map[string]int64{"sold": 1, "used": 1},
+	}
+	checkStats := func(stage string) {
+		t.Helper()
+		stats, err := c.GetAggregateStats(ctx)
+		if err != nil {
+			t.Fatalf("GetAggregateStats failed: %v", err)
+		}
+		if !reflect.DeepEqual(*stats, want) {
+			t.Fatalf("%s: got stats %+v, want %+v", stage, *stats, want)
+		}
+		if total, err := c.GetTotalTokens(ctx); err != nil || total != 2 {
+			t.Fatalf("%s: expected 2 tokens, got %d, %v", stage, total, err)
+		}
+		if count, err := c.GetTokenCountByFunding(ctx, "F2"); err != nil || count != 0 {
+			t.Fatalf("%s: expected no tokens in F2, got %d, %v", stage, count, err)
+		}
+	}
+	checkStats("deltas")
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if folded, err := c.CompactCounters(ctx); err != nil || folded == 0 {
+		t.Fatalf("CompactCounters failed: %d, %v", folded, err)
+	}
+	nextTx(stub, "tx7")
+	checkStats("compacted")
+
+	if _, err := c.RebuildCounters(ctx); err != nil {
+		t.Fatalf("RebuildCounters failed: %v", err)
+	}
+	nextTx(stub, "tx8")
+	checkStats("rebuilt")
+}
+
+func TestIndexesScanExistingLedgerUntilRebuilt(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 100)
+	mustCreateUser(t, c, ctx, "bob", 300)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	// 집계와 순위표가 생기기 전의 원장처럼 인덱스를 모두 지운다
+	for _, prefix := range []string{counterPrefix, counterDeltaPrefix, pointsRankPrefix, categoryRankPrefix, categoryHoldingPrefix} {
+		if err := deleteByPrefix(ctx, prefix); err != nil {
+			t.Fatalf("deleteByPrefix failed: %v", err)
+		}
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		if total, err := c.GetTotalUsers(ctx); err != nil || total != 2 {
+			t.Fatalf("%s: expected 2 users, got %d, %v", stage, total, err)
+		}
+		if total, err := c.GetTotalTokens(ctx); err != nil || total != 1 {
+			t.Fatalf("%s: expected 1 token, got %d, %v", stage, total, err)
+		}
+		if top, err := c.TopUsersByPoints(ctx, 10); err != nil || len(top) != 2 || top[0].NickName != "bob" || top[1].Score != 100 {
+			t.Fatalf("%s: unexpected points leaderboard %+v, %v", stage, top, err)
+		}
+		if holders, err := c.TopHoldersByCategory(ctx, "C01", 10); err != nil || len(holders) != 1 || holders[0].NickName != "alice" {
+			t.Fatalf("%s: unexpected category leaderboard %+v, %v", stage, holders, err)
+		}
+		if holding, err := c.GetCategoryHolding(ctx, "C01", "alice"); err != nil || holding.Count != 1 {
+			t.Fatalf("%s: unexpected holding %+v, %v", stage, holding, err)
+		}
+	}
+	nextTx(stub, "tx1")
+	check("scanned")
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.RebuildCounters(ctx); err != nil {
+		t.Fatalf("RebuildCounters failed: %v", err)
+	}
+	if _, err := c.RebuildLeaderboards(ctx); err != nil {
+		t.Fatalf("RebuildLeaderboards failed: %v", err)
+	}
+	nextTx(stub, "tx2")
+	check("rebuilt")
+}
+
+func TestMultisigApprovals(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	if err := c.DeleteAllUserBlocks(ctx); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a non-admin mass delete to be rejected, got %v", err)
+	}
+
+	asAdmin := func(id string) {
+		ctx.SetClientIdentity(&testIdentity{id: id, mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	}
+	asAdmin("admin1")
+	if _, err := c.SetMultisigPolicy(ctx, []string{"admin1", "admin2", "admin3"}, 2, 3600, 1000); err != nil {
+		t.Fatalf("SetMultisigPolicy failed: %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	if err := c.DeleteAllUserBlocks(ctx); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected DeleteAllUserBlocks to require approval, got %v", err)
+	}
+	if _, err := c.Pause(ctx, "incident", []string{}); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected Pause to require approval, got %v", err)
+	}
+	asAdmin("backend")
+	if err := c.UpdateMymPoint(ctx, "alice", 999); err != nil {
+		t.Fatalf("small point grants should not need approval: %v", err)
+	}
+	nextTx(stub, "tx2")
+	if err := c.UpdateMymPoint(ctx, "alice", 1000); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected a large point grant to require approval, got %v", err)
+	}
+	if err := c.CreateUserBlock(ctx, "id-whale", "whale", 1000, []string{}); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected a large opening balance to require approval, got %v", err)
+	}
+	if _, err := c.Airdrop(ctx, "CAMP1", []AirdropGrant{{NickName: "alice", MymPoint: 1000}}, false); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected a large airdrop grant to require approval, got %v", err)
+	}
+
+	if _, err := c.ProposeOperation(ctx, "P1", opUpdateMymPoint, []string{"alice", "5000"}); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a non-signer proposal to be rejected, got %v", err)
+	}
+	asAdmin("admin1")
+	if _, err := c.ProposeOperation(ctx, "P1", opUpdateMymPoint, []string{"alice"}); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected malformed arguments to be rejected, got %v", err)
+	}
+	if _, err := c.ProposeOperation(ctx, "P1", opUpdateMymPoint, []string{"alice", "5000"}); err != nil {
+		t.Fatalf("ProposeOperation failed: %v", err)
+	}
+
+	nextTx(stub, "tx3")
+	if _, err := c.ApproveOperation(ctx, "P1"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected the proposer to be unable to approve twice, got %v", err)
+	}
+	asAdmin("admin2")
+	proposal, err := c.ApproveOperation(ctx, "P1")
+	if err != nil {
+		t.Fatalf("ApproveOperation failed: %v", err)
+	}
+	if proposal.Status != proposalExecuted || len(proposal.Log) != 3 {
+		t.Fatalf("expected the proposal to execute at quorum with a full log, got %+v", proposal)
+	}
+	nextTx(stub, "tx4")
+	if user := mustGetUser(t, c, ctx, "alice"); user.MymPoint != 5999 {
+		t.Fatalf("expected alice to have 5999 MymPoint, got %d", user.MymPoint)
+	}
+	asAdmin("admin3")
+	if _, err := c.ApproveOperation(ctx, "P1"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected an executed proposal to reject approvals, got %v", err)
+	}
+
+	if _, err := c.ProposeOperation(ctx, "P2", opDeleteAllUserBlocks, []string{}); err != nil {
+		t.Fatalf("ProposeOperation failed: %v", err)
+	}
+	nextTx(stub, "tx5")
+	stub.TxTimestamp.Seconds += 3601
+	asAdmin("admin1")
+	if _, err := c.ApproveOperation(ctx, "P2"); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected an expired proposal to reject approvals, got %v", err)
+	}
+	if user := mustGetUser(t, c, ctx, "alice"); user.UserId == "" {
+		t.Fatalf("expected alice to survive an expired proposal")
+	}
+
+	// 정책이 바뀌면 빠진 서명자의 승인은 세지 않는다
+	nextTx(stub, "tx6")
+	if _, err := c.ProposeOperation(ctx, "P3", opUpdateMymPoint, []string{"alice", "10"}); err != nil {
+		t.Fatalf("ProposeOperation failed: %v", err)
+	}
+	if _, err := c.ProposeOperation(ctx, "P4", opSetMultisigPolicy, []string{`["admin2","admin3","admin4"]`, "2", "3600", "1000"}); err != nil {
+		t.Fatalf("ProposeOperation failed: %v", err)
+	}
+	asAdmin("admin2")
+	if proposal, err := c.ApproveOperation(ctx, "P4"); err != nil || proposal.Status != proposalExecuted {
+		t.Fatalf("expected the policy change to execute, got %+v, %v", proposal, err)
+	}
+	nextTx(stub, "tx7")
+	if proposal, err := c.ApproveOperation(ctx, "P3"); err != nil || proposal.Status != proposalPending {
+		t.Fatalf("expected the removed signer's approval not to count, got %+v, %v", proposal, err)
+	}
+	nextTx(stub, "tx8")
+	asAdmin("admin3")
+	if proposal, err := c.ApproveOperation(ctx, "P3"); err != nil || proposal.Status != proposalExecuted {
+		t.Fatalf("expected two current signers to execute the proposal, got %+v, %v", proposal, err)
+	}
+	nextTx(stub, "tx9")
+	if user := mustGetUser(t, c, ctx, "alice"); user.MymPoint != 6009 {
+		t.Fatalf("expected alice to have 6009 MymPoint, got %d", user.MymPoint)
+	}
+
+	// 승인 없이 받은 999 와 합쳐 LargePointGrant 에 이르므로 나누어 지급해도 막힌다
+	asAdmin("backend")
+	if err := c.UpdateMymPoint(ctx, "alice", 1); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected a split large grant to require approval, got %v", err)
+	}
+	if _, err := c.Airdrop(ctx, "CAMP2", []AirdropGrant{{NickName: "alice", MymPoint: 1}}, false); !mymberr.Is(err, mymberr.ApprovalRequired) {
+		t.Fatalf("expected a split large airdrop grant to require approval, got %v", err)
+	}
+	nextTx(stub, "tx10")
+	stub.TxTimestamp.Seconds += int64(largePointGrantWindow.Seconds())
+	if err := c.UpdateMymPoint(ctx, "alice", 999); err != nil {
+		t.Fatalf("expected the grant window to reset, got %v", err)
+	}
+	if err := c.UpdateMymPoint(ctx, "alice", -500); err != nil {
+		t.Fatalf("UpdateMymPoint failed: %v", err)
+	}
+}
+
+func TestKeyEndorsementPolicies(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	mustCreateUser(t, c, ctx, "alice", 0)
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	if _, err := c.SetTokenEndorsement(ctx, "T1", []string{"PartnerMSP"}); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a client without the endorsement role to be rejected, got %v", err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "ops", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleEndorsement}})
+	if _, err := c.SetTokenEndorsement(ctx, "T1", []string{"Partner MSP"}); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an invalid MSP ID to be rejected, got %v", err)
+	}
+	if _, err := c.SetFundingEndorsement(ctx, "F1", []string{testMSPID, "PartnerMSP"}); err != nil {
+		t.Fatalf("SetFundingEndorsement failed: %v", err)
+	}
+	if _, err := c.SetUserEndorsement(ctx, "alice", []string{"PartnerMSP"}); err != nil {
+		t.Fatalf("SetUserEndorsement failed: %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	ctx.SetClientIdentity(&testIdentity{id: "backend", mspID: testMSPID})
+	if _, err := c.MintToken(ctx, "T2", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	if _, err := c.MintToken(ctx, "T3", "alice", "C01", "F2", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	want := []string{testMSPID, "PartnerMSP"}
+	for tokenNumber, orgs := range map[string][]string{"T1": want, "T2": want, "T3": {}} {
+		endorsement, err := c.GetTokenEndorsement(ctx, tokenNumber)
+		if err != nil {
+			t.Fatalf("GetTokenEndorsement failed: %v", err)
+		}
+		if !reflect.DeepEqual(endorsement.Orgs, orgs) {
+			t.Fatalf("token %s has endorsement orgs %v, want %v", tokenNumber, endorsement.Orgs, orgs)
+		}
+	}
+	if endorsement, err := c.GetUserEndorsement(ctx, "alice"); err != nil || !reflect.DeepEqual(endorsement.Orgs, []string{"PartnerMSP"}) {
+		t.Fatalf("unexpected user endorsement %+v, %v", endorsement, err)
+	}
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetFundingEndorsement(ctx, "F1", []string{}); err != nil {
+		t.Fatalf("SetFundingEndorsement failed: %v", err)
+	}
+	nextTx(stub, "tx2")
+	if endorsement, err := c.GetTokenEndorsement(ctx, "T2"); err != nil || len(endorsement.Orgs) != 0 {
+		t.Fatalf("expected clearing the funding policy to clear token policies, got %+v, %v", endorsement, err)
+	}
+}
+
+func TestSettlePurchaseWithERC20Allowance(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	erc20 := newTestERC20("market")
+	stub.MockPeerChaincode("token_erc20", shimtest.NewMockStub("token_erc20", erc20), "")
+	erc20.balances["eDUwOTo6Ym9i"] = 100
+
+	ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	if _, err := c.SetERC20Config(ctx, "token_erc20", "other-channel"); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected an erc20 chaincode on another channel to be rejected, got %v", err)
+	}
+	if _, err := c.SetERC20Config(ctx, "token_erc20", ""); err != nil {
+		t.Fatalf("SetERC20Config failed: %v", err)
+	}
+	mustCreateUser(t, c, ctx, "alice", 0)
+	mustCreateUser(t, c, ctx, "bob", 0)
+	for nickName, accountID := range map[string]string{"alice": "eDUwOTo6YWxpY2U=", "bob": "eDUwOTo6Ym9i"} {
+		if _, err := c.SetSettlementAccount(ctx, nickName, accountID); err != nil {
+			t.Fatalf("SetSettlementAccount failed: %v", err)
+		}
+	}
+	if _, err := c.MintToken(ctx, "T1", "alice", "C01", "F1", "", "ticket", "sold", ""); err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+
+	nextTx(stub, "tx1")
+	ctx.SetClientIdentity(&testIdentity{id: "market", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleSettlement}})
+	erc20.allowances[[2]string{"eDUwOTo6Ym9i", "market"}] = 50
+	if _, err := c.SettlePurchase(ctx, "P1", "T1", "bob", 80); !mymberr.Is(err, mymberr.InsufficientAllowance) {
+		t.Fatalf("expected an insufficient allowance to be rejected, got %v", err)
+	}
+	if token, err := c.GetToken(ctx, "T1"); err != nil || token.Owner != "alice" {
+		t.Fatalf("expected the token to stay with alice, got %+v, %v", token, err)
+	}
+
+	erc20.allowances[[2]string{"eDUwOTo6Ym9i", "market"}] = 100
+	settlement, err := c.SettlePurchase(ctx, "P1", "T1", "bob", 80)
+	if err != nil {
+		t.Fatalf("SettlePurchase failed: %v", err)
+	}
+	if settlement.Seller != "alice" || settlement.Spender != "market" {
+		t.Fatalf("unexpected settlement %+v", settlement)
+	}
+	if erc20.balances["eDUwOTo6Ym9i"] != 20 || erc20.balances["eDUwOTo6YWxpY2U="] != 80 {
+		t.Fatalf("unexpected erc20 balances %v", erc20.balances)
+	}
+
+	nextTx(stub, "tx2")
+	if token, err := c.GetToken(ctx, "T1"); err != nil || token.Owner != "bob" {
+		t.Fatalf("expected the token to move to bob, got %+v, %v", token, err)
+	}
+	if _, err := c.SettlePurchase(ctx, "P1", "T1", "alice", 10); !mymberr.Is(err, mymberr.SettlementExists) {
+		t.Fatalf("expected a settled purchase ID to be rejected, got %v", err)
+	}
+	if _, err := c.SettlePurchase(ctx, "P2", "T1", "alice", 10); !mymberr.Is(err, mymberr.InsufficientAllowance) {
+		t.Fatalf("expected a purchase without allowance to be rejected, got %v", err)
+	}
+}
+
+func TestBridgeLockAndUnlockMymPoint(t *testing.T) {
+	c := new(TokenERC1155Contract)
+	ctx, stub := newTestContext(t)
+
+	const operatorAccount, aliceAccount = "eDUwOTo6YnJpZGdl", "eDUwOTo6YWxpY2U="
+	erc20 := newTestERC20(operatorAccount)
+	stub.MockPeerChaincode("token_erc20", shimtest.NewMockStub("token_erc20", erc20), "")
+	asAdmin := func() {
+		ctx.SetClientIdentity(&testIdentity{id: "admin", mspID: testMSPID, attrs: map[string]string{roleAttribute: roleAdmin}})
+	}
+	asOperator := func() {
+		ctx.SetClientIdentity(&testIdentity{id: operatorAccount, mspID: testMSPID})
+	}
+
+	asAdmin()
+	if _, err := c.SetERC20Config(ctx, "token_erc20", ""); err != nil {
+		t.Fatalf("SetERC20Config failed: %v", err)
+	}
+	if _, err := c.SetBridgeConfig(ctx, 10, operatorAccount); err != nil {
+		t.Fatalf("SetBridgeConfig failed: %v", err)
+	}
+	mustCreateUser(t, c, ctx, "alice", 100)
+	if _, err := c.SetSettlementAccount(ctx, "alice", aliceAccount); err != nil {
+		t.Fatalf("SetSettlementAccount failed: %v", err)
+	}
+
+	// 발행과 잠금은 운영 계정만 할 수 있다
+	nextTx(stub, "tx1")
+	ctx.SetClientIdentity(&testIdentity{id: aliceAccount, mspID: testMSPID})
+	if _, err := c.MintBridgeReserve(ctx, "M1", 8); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a mint from a user to be rejected, got %v", err)
+	}
+	if _, err := c.LockMymPoint(ctx, "R1", "alice", 50); !mymberr.Is(err, mymberr.Unauthorized) {
+		t.Fatalf("expected a lock from a user to be rejected, got %v", err)
+	}
+	asOperator()
+	if _, err := c.LockMymPoint(ctx, "R1", "alice", 50); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected a lock without reserve to be rejected, got %v", err)
+	}
+	if _, err := c.MintBridgeReserve(ctx, "M1", 8); err != nil {
+		t.Fatalf("MintBridgeReserve failed: %v", err)
+	}
+
+	nextTx(stub, "tx2")
+	if _, err := c.LockMymPoint(ctx, "R1", "alice", 55); !mymberr.Is(err, mymberr.InvalidArgument) {
+		t.Fatalf("expected points that are not a multiple of the rate to be rejected, got %v", err)
+	}
+	if _, err := c.LockMymPoint(ctx, "R1", "alice", 150); !mymberr.Is(err, mymberr.InsufficientPoints) {
+		t.Fatalf("expected a lock above the balance to be rejected, got %v", err)
+	}
+	request, err := c.LockMymPoint(ctx, "R1", "alice", 50)
+	if err != nil {
+		t.Fatalf("LockMymPoint failed: %v", err)
+	}
+	if request.Tokens != 5 || request.AccountID != aliceAccount || erc20.balances[aliceAccount] != 5 || erc20.balances[operatorAccount] != 3 {
+		t.Fatalf("unexpected lock %+v with balances %v", request, erc20.balances)
+	}
+
+	// 같은 requestID 의 재시도는 다시 보내지 않고 기존 기록을 돌려준다
+	nextTx(stub, "tx3")
+	if user := mustGetUser(t, c, ctx, "alice"); user.MymPoint != 50 {
+		t.Fatalf("expected 50 MymPoint after the lock, got %d", user.MymPoint)
+	}
+	if retried, err := c.LockMymPoint(ctx, "R1", "alice", 50); err != nil || retried.TxID != "tx2" {
+		t.Fatalf("expected the retried lock to return the first request, got %+v, %v", retried, err)
+	}
+	if _, err := c.UnlockMymPoint(ctx, "R1", "alice", 5); !mymberr.Is(err, mymberr.BridgeRequestConflict) {
+		t.Fatalf("expected a reused request ID to be rejected, got %v", err)
+	}
+	if erc20.balances[aliceAccount] != 5 {
+		t.Fatalf("expected the retry not to transfer again, got %v", erc20.balances)
+	}
+
+	// 나간 ERC-20 이 있는 동안에는 비율을 바꿀 수 없다
+	asAdmin()
+	if _, err := c.SetBridgeConfig(ctx, 20, operatorAccount); !mymberr.Is(err, mymberr.InvalidState) {
+		t.Fatalf("expected a rate change with outstanding tokens to be rejected, got %v", err)
+	}
+
+	asOperator()
+	if _, err := c.UnlockMymPoint(ctx, "R2", "alice", 6); !mymberr.Is(err, mymberr.InsufficientPoints) {
+		t.Fatalf("expected an unlock above the outstanding tokens to be rejected, got %v", err)
+	}
+	if _, err := c.UnlockMymPoint(ctx, "R2", "alice", 2); !mymberr.Is(err, mymberr.CrossChaincodeFailed) {
+		t.Fatalf("expected an unlock without allowance to be rejected, got %v", err)
+	}
+	erc20.allowances[[2]string{aliceAccount, operatorAccount}] = 5
+	if _, err := c.UnlockMymPoint(ctx, "R2", "alice", 2); err != nil {
+		t.Fatalf("UnlockMymPoint failed: %v", err)
+	}
+	if erc20.balances[aliceAccount] != 3 || erc20.balances[operatorAccount] != 5 {
+		t.Fatalf("expected 2 tokens to return to the reserve, got %v", erc20.balances)
+	}
+
+	nextTx(stub, "tx4")
+	if user := mustGetUser(t, c, ctx, "alice"); user.MymPoint != 70 {
+		t.Fatalf("expected 70 MymPoint after the unlock, got %d", user.MymPoint)
+	}
+	reconciliation, err := c.ReconcileBridge(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileBridge failed: %v", err)
+	}
+	if !reconciliation.Balanced || reconciliation.Escrow.EscrowPoints != 30 || reconciliation.Escrow.OutstandingTokens != 3 ||
+		reconciliation.Escrow.ReserveTokens != 5 || reconciliation.ERC20ReserveBalance != 5 {
+		t.Fatalf("unexpected reconciliation %+v", reconciliation)
+	}
+
+	// 브리지를 거치지 않고 운영 계정에 발행된 ERC-20 은 대사에서 드러난다
+	erc20.balances[operatorAccount]++
+	if reconciliation, err := c.ReconcileBridge(ctx); err != nil || reconciliation.Balanced {
+		t.Fatalf("expected an unrecorded mint to unbalance the bridge, got %+v, %v", reconciliation, err)
+	}
+	erc20.balances[operatorAccount]--
+
+	if _, err := c.UnlockMymPoint(ctx, "R3", "alice", 3); err != nil {
+		t.Fatalf("UnlockMymPoint failed: %v", err)
+	}
+	if _, err := c.BurnBridgeReserve(ctx, "B1", 8); err != nil {
+		t.Fatalf("BurnBridgeReserve failed: %v", err)
+	}
+
+	// 나간 ERC-20 이 모두 돌아오면 비율을 바꿀 수 있고, 이전 요청의 재시도는 바뀐 비율과 상관없이 기존 기록을 받는다
+	nextTx(stub, "tx5")
+	asAdmin()
+	if _, err := c.SetBridgeConfig(ctx, 20, operatorAccount); err != nil {
+		t.Fatalf("SetBridgeConfig failed: %v", err)
+	}
+	asOperator()
+	if retried, err := c.LockMymPoint(ctx, "R1", "alice", 50); err != nil || retried.PointsPerToken != 10 {
+		t.Fatalf("expected the retried lock to return the first request after a rate change, got %+v, %v", retried, err)
+	}
+	if reconciliation, err := c.ReconcileBridge(ctx); err != nil || !reconciliation.Balanced || reconciliation.Escrow.ReserveTokens != 0 {
+		t.Fatalf("unexpected reconciliation after burning the reserve %+v, %v", reconciliation, err)
+	}
+	if _, err := c.GetBridgeRequest(ctx, "R4"); !mymberr.Is(err, mymberr.BridgeRequestNotFound) {
+		t.Fatalf("expected a missing request to be reported, got %v", err)
+	}
+}
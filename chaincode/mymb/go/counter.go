@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AggregateCounter 정리된 집계 값, 아직 합쳐지지 않은 변화량은 counterDelta 에 따로 있다
+type AggregateCounter struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Kind          string `json:"kind"`
+	Key           string `json:"key"`
+	Value         int64  `json:"value"`
+}
+
+// AggregateStats 전체 집계 값
+type AggregateStats struct {
+	TotalTokens       int64            `json:"totalTokens"`
+	TotalUsers        int64            `json:"totalUsers"`
+	TotalPoints       int64            `json:"totalPoints"`
+	TokensByFunding   map[string]int64 `json:"tokensByFunding"`
+	TokensByCategory  map[string]int64 `json:"tokensByCategory"`
+	TokensBySellStage map[string]int64 `json:"tokensBySellStage"`
+}
+
+const (
+	// counter~kind~key 에 정리된 값을, counterDelta~kind~key~txID~subject 에 트랜잭션별 변화량을 저장한다
+	// 변화량은 트랜잭션마다 새 키에 쓰므로 같은 집계를 바꾸는 트랜잭션끼리 MVCC 충돌이 나지 않는다 (high-throughput 예제 방식)
+	counterPrefix      = "counter"
+	counterDeltaPrefix = "counterDelta"
+
+	// indexSeeded~index 는 Rebuild 함수가 인덱스를 처음부터 만든 뒤에 쓰는 표시, 없으면 이전 데이터가 빠져 있을 수 있어 조회가 전체를 순회한다
+	indexSeededPrefix = "indexSeeded"
+	indexCounters     = "counters"
+	indexLeaderboards = "leaderboards"
+
+	counterTokens            = "tokens"
+	counterUsers             = "users"
+	counterPoints            = "points"
+	counterTokensByFunding   = "tokensByFunding"
+	counterTokensByCategory  = "tokensByCategory"
+	counterTokensBySellStage = "tokensBySellStage"
+)
+
+// counterChange 집계 값의 변화
+type counterChange struct {
+	kind  string
+	key   string
+	delta int64
+}
+
+// GetTotalPoints 모든 유저의 MymPoint 합계를 반환하는 함수
+func (c *TokenERC1155Contract) GetTotalPoints(ctx contractapi.TransactionContextInterface) (int64, error) {
+	return c.getCounter(ctx, counterPoints, "")
+}
+
+// GetTokenCountByFunding 펀딩별 토큰 수를 반환하는 함수
+func (c *TokenERC1155Contract) GetTokenCountByFunding(ctx contractapi.TransactionContextInterface, fundingID string) (int64, error) {
+	if err := newValidator().field("fundingID", fundingID).err(); err != nil {
+		return 0, err
+	}
+	return c.getCounter(ctx, counterTokensByFunding, fundingID)
+}
+
+// GetTokenCountByCategory 카테고리별 토큰 수를 반환하는 함수
+func (c *TokenERC1155Contract) GetTokenCountByCategory(ctx contractapi.TransactionContextInterface, categoryCode string) (int64, error) {
+	if err := newValidator().field("categoryCode", categoryCode).err(); err != nil {
+		return 0, err
+	}
+	return c.getCounter(ctx, counterTokensByCategory, categoryCode)
+}
+
+// GetTokenCountBySellStage 판매 단계별 토큰 수를 반환하는 함수
+func (c *TokenERC1155Contract) GetTokenCountBySellStage(ctx contractapi.TransactionContextInterface, sellStage string) (int64, error) {
+	if err := newValidator().field("sellStage", sellStage).err(); err != nil {
+		return 0, err
+	}
+	return c.getCounter(ctx, counterTokensBySellStage, sellStage)
+}
+
+// GetAggregateStats 모든 집계 값을 한 번에 조회하는 함수
+func (c *TokenERC1155Contract) GetAggregateStats(ctx contractapi.TransactionContextInterface) (*AggregateStats, error) {
+
+	seeded, err := isIndexSeeded(ctx, indexCounters)
+	if err != nil {
+		return nil, err
+	}
+	if !seeded {
+		values, err := c.scanCounters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newAggregateStats(values), nil
+	}
+
+	values := map[[2]string]int64{}
+
+	counterIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(counterPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer counterIterator.Close()
+
+	for counterIterator.HasNext() {
+		queryResponse, err := counterIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+		var counter AggregateCounter
+		if err := unmarshalVersioned(counterPrefix, queryResponse.Value, &counter); err != nil {
+			return nil, wrapError(err, "failed to unmarshal counter")
+		}
+		values[[2]string{counter.Kind, counter.Key}] += counter.Value
+	}
+
+	deltas, _, err := getCounterDeltas(ctx, []string{})
+	if err != nil {
+		return nil, err
+	}
+	for key, delta := range deltas {
+		values[key] += delta
+	}
+
+	return newAggregateStats(values), nil
+}
+
+// CompactCounters 쌓인 변화량을 정리된 값에 합치고 지우는 함수, 조회가 느려지지 않도록 관리자가 주기적으로 호출한다
+// 변화량을 쓰는 트랜잭션과 같은 블록에 들어가면 범위 조회 검증에 걸려 이 트랜잭션만 실패하고 다시 호출하면 된다
+func (c *TokenERC1155Contract) CompactCounters(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return 0, err
+	}
+
+	deltas, deltaKeys, err := getCounterDeltas(ctx, []string{})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range sortedKeyPairs(deltas) {
+		counter, err := getCounterRecord(ctx, key[0], key[1])
+		if err != nil {
+			return 0, err
+		}
+		counter.Value += deltas[key]
+		if err := putCounterRecord(ctx, counter); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, deltaKey := range deltaKeys {
+		if err := ctx.GetStub().DelState(deltaKey); err != nil {
+			return 0, wrapError(err, "failed to delete counter delta")
+		}
+	}
+
+	return len(deltaKeys), nil
+}
+
+// RebuildCounters 모든 유저와 토큰을 읽어 집계 값을 처음부터 다시 만드는 함수
+// 집계가 생기기 전의 데이터를 반영하거나 값이 어긋났을 때 관리자가 호출한다
+// 배포나 업그레이드 뒤 한 번 호출하기 전까지 집계 조회는 정리된 값 대신 모든 유저와 토큰을 순회한다
+func (c *TokenERC1155Contract) RebuildCounters(ctx contractapi.TransactionContextInterface) (*AggregateStats, error) {
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	for _, prefix := range []string{counterPrefix, counterDeltaPrefix} {
+		if err := deleteByPrefix(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := c.scanCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 이전 값은 위에서 지웠으므로 읽지 않고 바로 쓴다
+	for _, key := range sortedKeyPairs(values) {
+		counter := AggregateCounter{SchemaVersion: counterSchemaVersion, Kind: key[0], Key: key[1], Value: values[key]}
+		if err := putCounterRecord(ctx, &counter); err != nil {
+			return nil, err
+		}
+	}
+	if err := putIndexSeeded(ctx, indexCounters); err != nil {
+		return nil, err
+	}
+
+	return newAggregateStats(values), nil
+}
+
+// 모든 유저와 토큰을 읽어 집계 값을 계산하는 도우미 함수, 아무것도 쓰지 않는다
+func (c *TokenERC1155Contract) scanCounters(ctx contractapi.TransactionContextInterface) (map[[2]string]int64, error) {
+
+	values := map[[2]string]int64{}
+
+	users, err := c.GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		for _, change := range userCounterChanges(&users[i], 1) {
+			values[[2]string{change.kind, change.key}] += change.delta
+		}
+	}
+
+	tokens, err := c.GetAllTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		for _, change := range tokenCounterChanges(&tokens[i], 1) {
+			values[[2]string{change.kind, change.key}] += change.delta
+		}
+	}
+	return values, nil
+}
+
+// 정리된 값과 변화량을 더해 집계 값을 반환하는 도우미 함수
+// 키 하나와 아직 합쳐지지 않은 변화량만 읽으므로 CompactCounters 뒤에는 데이터 양과 관계없이 일정한 시간이 걸린다
+// RebuildCounters 전에는 정리된 값에 이전 데이터가 빠져 있을 수 있으므로 전체를 순회해 계산한다
+func (c *TokenERC1155Contract) getCounter(ctx contractapi.TransactionContextInterface, kind string, key string) (int64, error) {
+	seeded, err := isIndexSeeded(ctx, indexCounters)
+	if err != nil {
+		return 0, err
+	}
+	if !seeded {
+		values, err := c.scanCounters(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return values[[2]string{kind, key}], nil
+	}
+
+	counter, err := getCounterRecord(ctx, kind, key)
+	if err != nil {
+		return 0, err
+	}
+	deltas, _, err := getCounterDeltas(ctx, []string{kind, key})
+	if err != nil {
+		return 0, err
+	}
+	return counter.Value + deltas[[2]string{kind, key}], nil
+}
+
+// 변화량을 kind, key 별로 합쳐 반환하는 도우미 함수, 두 번째 반환값은 읽은 변화량 키들
+func getCounterDeltas(ctx contractapi.TransactionContextInterface, attributes []string) (map[[2]string]int64, []string, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(counterDeltaPrefix, attributes)
+	if err != nil {
+		return nil, nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	deltas := map[[2]string]int64{}
+	keys := []string{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, wrapError(err, "failed to get next query response")
+		}
+		_, parts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, nil, wrapError(err, "failed to split composite key")
+		}
+		delta, err := strconv.ParseInt(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			return nil, nil, newError(mymberr.Internal, params("key", queryResponse.Key), "invalid counter delta %q", queryResponse.Value)
+		}
+		deltas[[2]string{parts[0], parts[1]}] += delta
+		keys = append(keys, queryResponse.Key)
+	}
+	return deltas, keys, nil
+}
+
+// 정리된 집계 값을 조회하는 도우미 함수, 없으면 0 을 반환
+func getCounterRecord(ctx contractapi.TransactionContextInterface, kind string, key string) (*AggregateCounter, error) {
+	counterKey, err := ctx.GetStub().CreateCompositeKey(counterPrefix, []string{kind, key})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	counterBytes, err := ctx.GetStub().GetState(counterKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read counter")
+	}
+	if counterBytes == nil {
+		return &AggregateCounter{SchemaVersion: counterSchemaVersion, Kind: kind, Key: key}, nil
+	}
+
+	var counter AggregateCounter
+	if err := unmarshalVersioned(counterPrefix, counterBytes, &counter); err != nil {
+		return nil, wrapError(err, "failed to unmarshal counter")
+	}
+	return &counter, nil
+}
+
+// 정리된 집계 값을 저장하는 도우미 함수, 0 이 되면 지운다
+func putCounterRecord(ctx contractapi.TransactionContextInterface, counter *AggregateCounter) error {
+	counterKey, err := ctx.GetStub().CreateCompositeKey(counterPrefix, []string{counter.Kind, counter.Key})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if counter.Value == 0 {
+		if err := ctx.GetStub().DelState(counterKey); err != nil {
+			return wrapError(err, "failed to delete counter")
+		}
+		return nil
+	}
+
+	counterBytes, err := json.Marshal(counter)
+	if err != nil {
+		return wrapError(err, "failed to marshal counter")
+	}
+	if err := ctx.GetStub().PutState(counterKey, counterBytes); err != nil {
+		return wrapError(err, "failed to put state for counter")
+	}
+	return nil
+}
+
+// 유저가 저장되거나 삭제될 때 유저 수와 MymPoint 합계의 변화량을 쓰는 도우미 함수, 새 유저는 old 가, 삭제는 user 가 nil
+func updateUserCounters(ctx contractapi.TransactionContextInterface, old *User, user *User) error {
+	changes := []counterChange{}
+	nickName := ""
+	if old != nil {
+		changes = append(changes, userCounterChanges(old, -1)...)
+		nickName = old.NickName
+	}
+	if user != nil {
+		changes = append(changes, userCounterChanges(user, 1)...)
+		nickName = user.NickName
+	}
+	return putCounterDeltas(ctx, "user:"+nickName, changes)
+}
+
+// 토큰이 저장되거나 삭제될 때 토큰 수의 변화량을 쓰는 도우미 함수, 발행은 old 가, 삭제는 token 이 nil
+func updateTokenCounters(ctx contractapi.TransactionContextInterface, old *Token1155, token *Token1155) error {
+	changes := []counterChange{}
+	tokenNumber := ""
+	if old != nil {
+		changes = append(changes, tokenCounterChanges(old, -1)...)
+		tokenNumber = old.TokenNumber
+	}
+	if token != nil {
+		changes = append(changes, tokenCounterChanges(token, 1)...)
+		tokenNumber = token.TokenNumber
+	}
+	return putCounterDeltas(ctx, "token:"+tokenNumber, changes)
+}
+
+// 유저 하나가 집계에 더하는 값을 반환하는 도우미 함수, sign 이 -1 이면 빼는 값
+func userCounterChanges(user *User, sign int64) []counterChange {
+	return []counterChange{
+		{kind: counterUsers, delta: sign},
+		{kind: counterPoints, delta: sign * user.MymPoint},
+	}
+}
+
+// 토큰 하나가 집계에 더하는 값을 반환하는 도우미 함수, sign 이 -1 이면 빼는 값
+func tokenCounterChanges(token *Token1155, sign int64) []counterChange {
+	return []counterChange{
+		{kind: counterTokens, delta: sign},
+		{kind: counterTokensByFunding, key: token.FundingID, delta: sign},
+		{kind: counterTokensByCategory, key: token.CategoryCode, delta: sign},
+		{kind: counterTokensBySellStage, key: token.SellStage, delta: sign},
+	}
+}
+
+// 변화량을 kind, key 별로 합쳐 트랜잭션과 대상마다 새 키에 쓰는 도우미 함수
+// 대상(유저, 토큰)마다 키가 하나라 같은 트랜잭션에서 같은 대상을 두 번 저장하면 앞의 변화량을 덮어쓴다
+func putCounterDeltas(ctx contractapi.TransactionContextInterface, subject string, changes []counterChange) error {
+
+	deltas := map[[2]string]int64{}
+	for _, change := range changes {
+		deltas[[2]string{change.kind, change.key}] += change.delta
+	}
+
+	for _, key := range sortedKeyPairs(deltas) {
+		if deltas[key] == 0 {
+			continue
+		}
+		deltaKey, err := ctx.GetStub().CreateCompositeKey(counterDeltaPrefix, []string{key[0], key[1], ctx.GetStub().GetTxID(), subject})
+		if err != nil {
+			return wrapError(err, "failed to create composite key")
+		}
+		if err := ctx.GetStub().PutState(deltaKey, []byte(strconv.FormatInt(deltas[key], 10))); err != nil {
+			return wrapError(err, "failed to put state for counter delta")
+		}
+	}
+	return nil
+}
+
+// kind, key 별 값을 AggregateStats 로 모으는 도우미 함수, 0 인 항목은 뺀다
+func newAggregateStats(values map[[2]string]int64) *AggregateStats {
+	stats := AggregateStats{
+		TokensByFunding:   map[string]int64{},
+		TokensByCategory:  map[string]int64{},
+		TokensBySellStage: map[string]int64{},
+	}
+	for key, value := range values {
+		switch key[0] {
+		case counterTokens:
+			stats.TotalTokens = value
+		case counterUsers:
+			stats.TotalUsers = value
+		case counterPoints:
+			stats.TotalPoints = value
+		case counterTokensByFunding:
+			if value != 0 {
+				stats.TokensByFunding[key[1]] = value
+			}
+		case counterTokensByCategory:
+			if value != 0 {
+				stats.TokensByCategory[key[1]] = value
+			}
+		case counterTokensBySellStage:
+			if value != 0 {
+				stats.TokensBySellStage[key[1]] = value
+			}
+		}
+	}
+	return &stats
+}
+
+// Rebuild 함수가 인덱스를 처음부터 만들었는지 확인하는 도우미 함수
+func isIndexSeeded(ctx contractapi.TransactionContextInterface, index string) (bool, error) {
+	seededKey, err := ctx.GetStub().CreateCompositeKey(indexSeededPrefix, []string{index})
+	if err != nil {
+		return false, wrapError(err, "failed to create composite key")
+	}
+	seededBytes, err := ctx.GetStub().GetState(seededKey)
+	if err != nil {
+		return false, wrapError(err, "failed to read index state")
+	}
+	return seededBytes != nil, nil
+}
+
+// 인덱스를 처음부터 만들었다고 표시하는 도우미 함수
+func putIndexSeeded(ctx contractapi.TransactionContextInterface, index string) error {
+	seededKey, err := ctx.GetStub().CreateCompositeKey(indexSeededPrefix, []string{index})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(seededKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for index state")
+	}
+	return nil
+}
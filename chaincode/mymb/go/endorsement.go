@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// KeyEndorsement 키에 설정된 보증 정책, Orgs 가 비어 있으면 체인코드 보증 정책을 따른다
+type KeyEndorsement struct {
+	Kind string   `json:"kind"`
+	ID   string   `json:"id"`
+	Orgs []string `json:"orgs"`
+}
+
+// FundingEndorsement 펀딩의 토큰들에 적용하는 보증 정책
+type FundingEndorsement struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	FundingID     string    `json:"fundingID"`
+	Orgs          []string  `json:"orgs"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+const (
+	fundingEndorsementPrefix = "fundingEndorsement"
+
+	endorsementToken   = "token"
+	endorsementUser    = "user"
+	endorsementFunding = "funding"
+
+	// 키별 보증 정책을 바꿀 수 있는 역할
+	roleEndorsement = "endorsement"
+)
+
+// SetTokenEndorsement 토큰 키에 orgs 의 모든 피어 보증이 필요한 정책을 설정하는 함수, orgs 가 비어 있으면 정책을 지운다
+// 키 정책은 체인코드 보증 정책을 대신하므로 플랫폼 조직도 orgs 에 넣어야 한다
+// 이미 정책이 있는 키의 정책을 바꾸는 트랜잭션도 기존 정책의 보증을 받아야 한다
+func (c *TokenERC1155Contract) SetTokenEndorsement(ctx contractapi.TransactionContextInterface, tokenNumber string, orgs []string) (*KeyEndorsement, error) {
+
+	err := newValidator().
+		field("tokenNumber", tokenNumber).
+		list("mspID", "orgs", orgs).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleEndorsement, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.GetToken(ctx, tokenNumber); err != nil {
+		return nil, err
+	}
+
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	return setKeyEndorsement(ctx, endorsementToken, tokenNumber, tokenKey, orgs)
+}
+
+// SetUserEndorsement 유저 블록 키에 orgs 의 모든 피어 보증이 필요한 정책을 설정하는 함수, orgs 가 비어 있으면 정책을 지운다
+func (c *TokenERC1155Contract) SetUserEndorsement(ctx contractapi.TransactionContextInterface, nickName string, orgs []string) (*KeyEndorsement, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		list("mspID", "orgs", orgs).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleEndorsement, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	return setKeyEndorsement(ctx, endorsementUser, nickName, nickName, orgs)
+}
+
+// SetFundingEndorsement 펀딩의 보증 정책을 기록하고 그 펀딩의 기존 토큰들에 적용하는 함수, 이후 발행되는 토큰에도 적용된다
+// orgs 가 비어 있으면 펀딩 정책과 기존 토큰들의 정책을 지운다, 토큰마다 따로 설정한 정책도 덮어쓴다
+func (c *TokenERC1155Contract) SetFundingEndorsement(ctx contractapi.TransactionContextInterface, fundingID string, orgs []string) (*FundingEndorsement, error) {
+
+	err := newValidator().
+		field("fundingID", fundingID).
+		check(fundingID != "", "fundingID", "required", "must not be empty").
+		list("mspID", "orgs", orgs).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleEndorsement, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if orgs == nil {
+		orgs = []string{}
+	}
+	endorsement := FundingEndorsement{
+		SchemaVersion: fundingEndorsementSchemaVersion,
+		FundingID:     fundingID,
+		Orgs:          orgs,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	// 펀딩 정책 기록 자체도 같은 정책으로 보호해 해당 조직 없이 바꾸지 못하게 한다
+	endorsementKey, err := ctx.GetStub().CreateCompositeKey(fundingEndorsementPrefix, []string{fundingID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	endorsementBytes, err := json.Marshal(endorsement)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal funding endorsement")
+	}
+	if len(orgs) == 0 {
+		if err := ctx.GetStub().DelState(endorsementKey); err != nil {
+			return nil, wrapError(err, "failed to delete funding endorsement")
+		}
+	} else if err := ctx.GetStub().PutState(endorsementKey, endorsementBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for funding endorsement")
+	}
+	if err := putKeyEndorsementPolicy(ctx, endorsementKey, orgs); err != nil {
+		return nil, err
+	}
+
+	tokens, err := c.GetAllTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.FundingID != fundingID {
+			continue
+		}
+		tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{token.TokenNumber})
+		if err != nil {
+			return nil, wrapError(err, "failed to create composite key")
+		}
+		if err := putKeyEndorsementPolicy(ctx, tokenKey, orgs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.GetStub().SetEvent("SetFundingEndorsement", endorsementBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+	return &endorsement, nil
+}
+
+// GetTokenEndorsement 토큰 키에 설정된 보증 정책을 조회하는 함수
+func (c *TokenERC1155Contract) GetTokenEndorsement(ctx contractapi.TransactionContextInterface, tokenNumber string) (*KeyEndorsement, error) {
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	return getKeyEndorsement(ctx, endorsementToken, tokenNumber, tokenKey)
+}
+
+// GetUserEndorsement 유저 블록 키에 설정된 보증 정책을 조회하는 함수
+func (c *TokenERC1155Contract) GetUserEndorsement(ctx contractapi.TransactionContextInterface, nickName string) (*KeyEndorsement, error) {
+	return getKeyEndorsement(ctx, endorsementUser, nickName, nickName)
+}
+
+// GetFundingEndorsement 펀딩의 보증 정책을 조회하는 함수, 설정되지 않았으면 Orgs 가 비어 있다
+func (c *TokenERC1155Contract) GetFundingEndorsement(ctx contractapi.TransactionContextInterface, fundingID string) (*KeyEndorsement, error) {
+	endorsement, err := getFundingEndorsement(ctx, fundingID)
+	if err != nil {
+		return nil, err
+	}
+	orgs := []string{}
+	if endorsement != nil {
+		orgs = endorsement.Orgs
+	}
+	return &KeyEndorsement{Kind: endorsementFunding, ID: fundingID, Orgs: orgs}, nil
+}
+
+// 새로 발행된 토큰에 펀딩의 보증 정책을 적용하는 도우미 함수
+func applyFundingEndorsement(ctx contractapi.TransactionContextInterface, tokenKey string, fundingID string) error {
+	if fundingID == "" {
+		return nil
+	}
+	endorsement, err := getFundingEndorsement(ctx, fundingID)
+	if err != nil {
+		return err
+	}
+	if endorsement == nil {
+		return nil
+	}
+	return putKeyEndorsementPolicy(ctx, tokenKey, endorsement.Orgs)
+}
+
+// 키에 보증 정책을 설정하고 이벤트를 남기는 도우미 함수
+func setKeyEndorsement(ctx contractapi.TransactionContextInterface, kind string, id string, key string, orgs []string) (*KeyEndorsement, error) {
+	if err := putKeyEndorsementPolicy(ctx, key, orgs); err != nil {
+		return nil, err
+	}
+
+	if orgs == nil {
+		orgs = []string{}
+	}
+	endorsement := KeyEndorsement{Kind: kind, ID: id, Orgs: orgs}
+	endorsementBytes, err := json.Marshal(endorsement)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal key endorsement")
+	}
+	if err := ctx.GetStub().SetEvent("SetKeyEndorsement", endorsementBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+	return &endorsement, nil
+}
+
+// orgs 의 모든 피어 보증이 필요한 정책을 키에 쓰는 도우미 함수, orgs 가 비어 있으면 정책을 지운다
+func putKeyEndorsementPolicy(ctx contractapi.TransactionContextInterface, key string, orgs []string) error {
+	if len(orgs) == 0 {
+		if err := ctx.GetStub().SetStateValidationParameter(key, nil); err != nil {
+			return wrapError(err, "failed to clear endorsement policy of %s", key)
+		}
+		return nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return wrapError(err, "failed to create endorsement policy")
+	}
+	if err := endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+		return wrapError(err, "failed to add orgs to endorsement policy")
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return wrapError(err, "failed to create endorsement policy bytes")
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(key, policy); err != nil {
+		return wrapError(err, "failed to set endorsement policy of %s", key)
+	}
+	return nil
+}
+
+// 키에 설정된 보증 정책의 조직들을 읽는 도우미 함수
+func getKeyEndorsement(ctx contractapi.TransactionContextInterface, kind string, id string, key string) (*KeyEndorsement, error) {
+	policy, err := ctx.GetStub().GetStateValidationParameter(key)
+	if err != nil {
+		return nil, wrapError(err, "failed to get endorsement policy of %s", key)
+	}
+
+	orgs := []string{}
+	if len(policy) > 0 {
+		endorsementPolicy, err := statebased.NewStateEP(policy)
+		if err != nil {
+			return nil, wrapError(err, "failed to parse endorsement policy of %s", key)
+		}
+		orgs = endorsementPolicy.ListOrgs()
+		sort.Strings(orgs)
+	}
+	return &KeyEndorsement{Kind: kind, ID: id, Orgs: orgs}, nil
+}
+
+// 펀딩의 보증 정책을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getFundingEndorsement(ctx contractapi.TransactionContextInterface, fundingID string) (*FundingEndorsement, error) {
+	endorsementKey, err := ctx.GetStub().CreateCompositeKey(fundingEndorsementPrefix, []string{fundingID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	endorsementBytes, err := ctx.GetStub().GetState(endorsementKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read funding endorsement")
+	}
+	if endorsementBytes == nil {
+		return nil, nil
+	}
+
+	var endorsement FundingEndorsement
+	if err := unmarshalVersioned(fundingEndorsementPrefix, endorsementBytes, &endorsement); err != nil {
+		return nil, wrapError(err, "failed to unmarshal funding endorsement")
+	}
+	return &endorsement, nil
+}
@@ -0,0 +1,656 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Enterprise 기업 계정, 공용 토큰과 MymPoint 는 PoolNickName 유저 블록에 모아 둔다
+type Enterprise struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	EnterpriseID  string    `json:"enterpriseID"`
+	Name          string    `json:"name"`
+	PoolNickName  string    `json:"poolNickName"`
+	CreatedTime   time.Time `json:"createdTime"`
+}
+
+// EnterpriseMember 기업 계정에 속한 유저와 공용 풀에서 받을 수 있는 한도
+// SpendingLimit, TokenLimit 은 누적 한도이며 Spent, TokensAllocated 는 지금까지 받은 양
+type EnterpriseMember struct {
+	SchemaVersion   int       `json:"schemaVersion"`
+	EnterpriseID    string    `json:"enterpriseID"`
+	NickName        string    `json:"nickName"`
+	Role            string    `json:"role"`
+	SpendingLimit   int64     `json:"spendingLimit"`
+	Spent           int64     `json:"spent"`
+	TokenLimit      int64     `json:"tokenLimit"`
+	TokensAllocated int64     `json:"tokensAllocated"`
+	JoinedTime      time.Time `json:"joinedTime"`
+}
+
+// EnterpriseMemberHolding 기업 구성원 한 명의 보유 현황
+type EnterpriseMemberHolding struct {
+	NickName        string   `json:"nickName"`
+	Role            string   `json:"role"`
+	MymPoint        int64    `json:"mymPoint"`
+	OwnedToken      []string `json:"ownedToken"`
+	Spent           int64    `json:"spent"`
+	TokensAllocated int64    `json:"tokensAllocated"`
+}
+
+// EnterpriseHoldings 공용 풀과 구성원을 합한 기업 전체 보유 현황
+type EnterpriseHoldings struct {
+	EnterpriseID string                    `json:"enterpriseID"`
+	PoolPoints   int64                     `json:"poolPoints"`
+	PoolTokens   []string                  `json:"poolTokens"`
+	MemberPoints int64                     `json:"memberPoints"`
+	MemberTokens int                       `json:"memberTokens"`
+	TotalPoints  int64                     `json:"totalPoints"`
+	TotalTokens  int                       `json:"totalTokens"`
+	Members      []EnterpriseMemberHolding `json:"members"`
+}
+
+const (
+	// enterprise~enterpriseID 에 기업을, enterpriseMember~enterpriseID~nickName 에 구성원을 저장한다
+	// enterpriseOf~nickName~enterpriseID 는 유저가 한 기업에만 속하도록 확인하는 인덱스 (공용 풀 유저 포함)
+	enterprisePrefix       = "enterprise"
+	enterpriseMemberPrefix = "enterpriseMember"
+	enterpriseOfPrefix     = "enterpriseOf"
+
+	enterpriseRoleAdmin  = "admin"
+	enterpriseRoleMember = "member"
+
+	// 기업 관리자를 대신해 기업 계정을 관리하는 백엔드의 역할, admin 인자는 클라이언트가 넘기므로 역할로 호출자를 제한한다
+	roleEnterprise = "enterprise"
+)
+
+// CreateEnterprise 기업 계정을 만드는 함수, poolNickName 유저 블록이 공용 풀이 되고 admin 이 첫 관리자가 된다
+func (c *TokenERC1155Contract) CreateEnterprise(ctx contractapi.TransactionContextInterface, enterpriseID string, name string, poolNickName string, admin string) (*Enterprise, error) {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		field("name", name).
+		fieldAs("nickName", "poolNickName", poolNickName).
+		fieldAs("nickName", "admin", admin).
+		check(poolNickName != admin, "admin", "different", "must differ from the pool account").
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	existing, err := getEnterprise(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.EnterpriseExists, params("enterpriseID", enterpriseID), "enterprise %s already exists", enterpriseID)
+	}
+
+	for _, nickName := range []string{poolNickName, admin} {
+		if err := c.checkEnterpriseCandidate(ctx, nickName); err != nil {
+			return nil, err
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enterprise := Enterprise{
+		SchemaVersion: enterpriseSchemaVersion,
+		EnterpriseID:  enterpriseID,
+		Name:          name,
+		PoolNickName:  poolNickName,
+		CreatedTime:   now,
+	}
+	enterpriseKey, err := ctx.GetStub().CreateCompositeKey(enterprisePrefix, []string{enterpriseID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	enterpriseBytes, err := json.Marshal(enterprise)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal enterprise")
+	}
+	if err := ctx.GetStub().PutState(enterpriseKey, enterpriseBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for enterprise")
+	}
+	if err := putEnterpriseOf(ctx, poolNickName, enterpriseID); err != nil {
+		return nil, err
+	}
+
+	member := EnterpriseMember{
+		SchemaVersion: enterpriseMemberSchemaVersion,
+		EnterpriseID:  enterpriseID,
+		NickName:      admin,
+		Role:          enterpriseRoleAdmin,
+		JoinedTime:    now,
+	}
+	if err := putEnterpriseMember(ctx, &member); err != nil {
+		return nil, err
+	}
+	if err := putEnterpriseOf(ctx, admin, enterpriseID); err != nil {
+		return nil, err
+	}
+
+	return &enterprise, nil
+}
+
+// AddEnterpriseMember 기업 관리자가 구성원을 추가하는 함수, 한도는 공용 풀에서 받을 수 있는 누적 MymPoint 와 토큰 수
+func (c *TokenERC1155Contract) AddEnterpriseMember(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string,
+	nickName string, role string, spendingLimit int64, tokenLimit int64) (*EnterpriseMember, error) {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		fieldAs("nickName", "admin", admin).
+		field("nickName", nickName).
+		oneOf("role", role, enterpriseRoleAdmin, enterpriseRoleMember).
+		nonNegative("spendingLimit", spendingLimit).
+		nonNegative("tokenLimit", tokenLimit).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.checkEnterpriseAdmin(ctx, enterpriseID, admin); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkEnterpriseCandidate(ctx, nickName); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	member := EnterpriseMember{
+		SchemaVersion: enterpriseMemberSchemaVersion,
+		EnterpriseID:  enterpriseID,
+		NickName:      nickName,
+		Role:          role,
+		SpendingLimit: spendingLimit,
+		TokenLimit:    tokenLimit,
+		JoinedTime:    now,
+	}
+	if err := putEnterpriseMember(ctx, &member); err != nil {
+		return nil, err
+	}
+	if err := putEnterpriseOf(ctx, nickName, enterpriseID); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// RemoveEnterpriseMember 기업 관리자가 구성원을 내보내는 함수, 구성원이 받은 토큰과 MymPoint 는 구성원에게 남는다
+func (c *TokenERC1155Contract) RemoveEnterpriseMember(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string, nickName string) error {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		fieldAs("nickName", "admin", admin).
+		field("nickName", nickName).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.checkEnterpriseAdmin(ctx, enterpriseID, admin); err != nil {
+		return err
+	}
+
+	member, err := c.GetEnterpriseMember(ctx, enterpriseID, nickName)
+	if err != nil {
+		return err
+	}
+
+	// 마지막 관리자를 내보내면 기업 계정을 관리할 수 없게 된다
+	if member.Role == enterpriseRoleAdmin {
+		members, err := c.GetEnterpriseMembers(ctx, enterpriseID)
+		if err != nil {
+			return err
+		}
+		admins := 0
+		for _, m := range members {
+			if m.Role == enterpriseRoleAdmin {
+				admins++
+			}
+		}
+		if admins == 1 {
+			return newError(mymberr.InvalidState, params("enterpriseID", enterpriseID, "nickName", nickName), "cannot remove the last admin %s of enterprise %s", nickName, enterpriseID)
+		}
+	}
+
+	memberKey, err := ctx.GetStub().CreateCompositeKey(enterpriseMemberPrefix, []string{enterpriseID, nickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(memberKey); err != nil {
+		return wrapError(err, "failed to delete enterprise member")
+	}
+	enterpriseOfKey, err := ctx.GetStub().CreateCompositeKey(enterpriseOfPrefix, []string{nickName, enterpriseID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(enterpriseOfKey); err != nil {
+		return wrapError(err, "failed to delete enterprise index")
+	}
+
+	return nil
+}
+
+// SetEnterpriseMemberLimits 기업 관리자가 구성원의 누적 한도를 바꾸는 함수, 이미 받은 양보다 작게 줄일 수는 없다
+func (c *TokenERC1155Contract) SetEnterpriseMemberLimits(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string,
+	nickName string, spendingLimit int64, tokenLimit int64) (*EnterpriseMember, error) {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		fieldAs("nickName", "admin", admin).
+		field("nickName", nickName).
+		nonNegative("spendingLimit", spendingLimit).
+		nonNegative("tokenLimit", tokenLimit).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.checkEnterpriseAdmin(ctx, enterpriseID, admin); err != nil {
+		return nil, err
+	}
+
+	member, err := c.GetEnterpriseMember(ctx, enterpriseID, nickName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = newValidator().
+		check(spendingLimit >= member.Spent, "spendingLimit", "min", "must not be below the points already spent ("+strconv.FormatInt(member.Spent, 10)+")").
+		check(tokenLimit >= member.TokensAllocated, "tokenLimit", "min", "must not be below the tokens already allocated ("+strconv.FormatInt(member.TokensAllocated, 10)+")").
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	member.SpendingLimit = spendingLimit
+	member.TokenLimit = tokenLimit
+	if err := putEnterpriseMember(ctx, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// AllocateEnterpriseToken 기업 관리자가 공용 풀의 토큰을 구성원에게 나누어 주는 함수
+func (c *TokenERC1155Contract) AllocateEnterpriseToken(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string,
+	nickName string, tokenNumber string) (*EnterpriseMember, error) {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		fieldAs("nickName", "admin", admin).
+		field("nickName", nickName).
+		field("tokenNumber", tokenNumber).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	enterprise, err := c.checkEnterpriseAdmin(ctx, enterpriseID, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := c.GetEnterpriseMember(ctx, enterpriseID, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if member.TokensAllocated+1 > member.TokenLimit {
+		return nil, newError(mymberr.SpendingLimitExceeded, params("enterpriseID", enterpriseID, "nickName", nickName, "tokenLimit", strconv.FormatInt(member.TokenLimit, 10)),
+			"member %s has reached the token limit %d of enterprise %s", nickName, member.TokenLimit, enterpriseID)
+	}
+
+	// 소유, 동결, 전송 정책, KYC 확인은 일반 전송과 같다
+	if err := c.transferToken(ctx, enterprise.PoolNickName, nickName, tokenNumber); err != nil {
+		return nil, err
+	}
+
+	member.TokensAllocated++
+	if err := putEnterpriseMember(ctx, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// AllocateEnterprisePoints 기업 관리자가 공용 풀의 MymPoint 를 구성원에게 나누어 주는 함수
+func (c *TokenERC1155Contract) AllocateEnterprisePoints(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string,
+	nickName string, amount int64) (*EnterpriseMember, error) {
+
+	err := newValidator().
+		field("enterpriseID", enterpriseID).
+		fieldAs("nickName", "admin", admin).
+		field("nickName", nickName).
+		positive("amount", amount).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	enterprise, err := c.checkEnterpriseAdmin(ctx, enterpriseID, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := c.GetEnterpriseMember(ctx, enterpriseID, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if member.Spent+amount > member.SpendingLimit {
+		return nil, newError(mymberr.SpendingLimitExceeded, params("enterpriseID", enterpriseID, "nickName", nickName, "spendingLimit", strconv.FormatInt(member.SpendingLimit, 10)),
+			"member %s would exceed the spending limit %d of enterprise %s", nickName, member.SpendingLimit, enterpriseID)
+	}
+
+	pool, err := c.GetUser(ctx, enterprise.PoolNickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get pool information")
+	}
+	if pool.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", enterprise.PoolNickName), "pool account %s does not exist", enterprise.PoolNickName)
+	}
+	if pool.MymPoint < amount {
+		return nil, newError(mymberr.InsufficientPoints, params("nickName", pool.NickName), "pool %s has insufficient MymPoint", pool.NickName)
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	if err := checkNotFrozen(ctx, pool.NickName, nickName); err != nil {
+		return nil, err
+	}
+
+	pool.MymPoint -= amount
+	user.MymPoint += amount
+	member.Spent += amount
+
+	if err := putUser(ctx, pool); err != nil {
+		return nil, err
+	}
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := putEnterpriseMember(ctx, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// GetEnterprise 기업 계정을 조회하는 함수
+func (c *TokenERC1155Contract) GetEnterprise(ctx contractapi.TransactionContextInterface, enterpriseID string) (*Enterprise, error) {
+
+	enterprise, err := getEnterprise(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+	if enterprise == nil {
+		return nil, newError(mymberr.EnterpriseNotFound, params("enterpriseID", enterpriseID), "enterprise %s does not exist", enterpriseID)
+	}
+	return enterprise, nil
+}
+
+// GetEnterpriseMember 기업 구성원 한 명을 조회하는 함수
+func (c *TokenERC1155Contract) GetEnterpriseMember(ctx contractapi.TransactionContextInterface, enterpriseID string, nickName string) (*EnterpriseMember, error) {
+
+	memberKey, err := ctx.GetStub().CreateCompositeKey(enterpriseMemberPrefix, []string{enterpriseID, nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	memberBytes, err := ctx.GetStub().GetState(memberKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read enterprise member")
+	}
+	if memberBytes == nil {
+		return nil, newError(mymberr.EnterpriseMemberNotFound, params("enterpriseID", enterpriseID, "nickName", nickName), "%s is not a member of enterprise %s", nickName, enterpriseID)
+	}
+
+	var member EnterpriseMember
+	if err := unmarshalVersioned(enterpriseMemberPrefix, memberBytes, &member); err != nil {
+		return nil, wrapError(err, "failed to unmarshal enterprise member")
+	}
+	return &member, nil
+}
+
+// GetEnterpriseMembers 기업의 모든 구성원을 조회하는 함수
+func (c *TokenERC1155Contract) GetEnterpriseMembers(ctx contractapi.TransactionContextInterface, enterpriseID string) ([]EnterpriseMember, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(enterpriseMemberPrefix, []string{enterpriseID})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	members := []EnterpriseMember{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var member EnterpriseMember
+		if err := unmarshalVersioned(enterpriseMemberPrefix, queryResponse.Value, &member); err != nil {
+			return nil, wrapError(err, "failed to unmarshal enterprise member")
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// GetUserEnterprise 유저가 구성원이나 공용 풀로 속한 기업 ID 를 조회하는 함수, 없으면 빈 문자열
+func (c *TokenERC1155Contract) GetUserEnterprise(ctx contractapi.TransactionContextInterface, nickName string) (string, error) {
+	return getEnterpriseOf(ctx, nickName)
+}
+
+// GetEnterpriseHoldings 공용 풀과 모든 구성원의 토큰, MymPoint 를 합한 기업 전체 보유 현황을 조회하는 함수
+func (c *TokenERC1155Contract) GetEnterpriseHoldings(ctx contractapi.TransactionContextInterface, enterpriseID string) (*EnterpriseHoldings, error) {
+
+	enterprise, err := c.GetEnterprise(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.GetUser(ctx, enterprise.PoolNickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get pool information")
+	}
+
+	members, err := c.GetEnterpriseMembers(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := EnterpriseHoldings{
+		EnterpriseID: enterpriseID,
+		PoolPoints:   pool.MymPoint,
+		PoolTokens:   pool.OwnedToken,
+		Members:      []EnterpriseMemberHolding{},
+	}
+	if holdings.PoolTokens == nil {
+		holdings.PoolTokens = []string{}
+	}
+
+	for _, member := range members {
+		user, err := c.GetUser(ctx, member.NickName)
+		if err != nil {
+			return nil, wrapError(err, "failed to get user information")
+		}
+		ownedToken := user.OwnedToken
+		if ownedToken == nil {
+			ownedToken = []string{}
+		}
+		holdings.Members = append(holdings.Members, EnterpriseMemberHolding{
+			NickName:        member.NickName,
+			Role:            member.Role,
+			MymPoint:        user.MymPoint,
+			OwnedToken:      ownedToken,
+			Spent:           member.Spent,
+			TokensAllocated: member.TokensAllocated,
+		})
+		holdings.MemberPoints += user.MymPoint
+		holdings.MemberTokens += len(ownedToken)
+	}
+
+	holdings.TotalPoints = holdings.PoolPoints + holdings.MemberPoints
+	holdings.TotalTokens = len(holdings.PoolTokens) + holdings.MemberTokens
+	return &holdings, nil
+}
+
+// 호출자가 기업 관리 역할을 가졌고 admin 이 기업 관리자인지 확인하고 기업 계정을 반환하는 도우미 함수
+func (c *TokenERC1155Contract) checkEnterpriseAdmin(ctx contractapi.TransactionContextInterface, enterpriseID string, admin string) (*Enterprise, error) {
+
+	if err := assertAnyRole(ctx, roleEnterprise, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	enterprise, err := c.GetEnterprise(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := c.GetEnterpriseMember(ctx, enterpriseID, admin)
+	if mymberr.Is(err, mymberr.EnterpriseMemberNotFound) || (err == nil && member.Role != enterpriseRoleAdmin) {
+		return nil, newError(mymberr.Unauthorized, params("enterpriseID", enterpriseID, "nickName", admin), "%s is not an admin of enterprise %s", admin, enterpriseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotFrozen(ctx, admin); err != nil {
+		return nil, err
+	}
+	return enterprise, nil
+}
+
+// 기업 공용 풀에서 토큰이나 MymPoint 를 직접 빼가지 못하게 하는 도우미 함수
+// 공용 풀은 AllocateEnterpriseToken, AllocateEnterprisePoints 로 구성원 한도 안에서만 나누어 줄 수 있다
+func checkNotEnterprisePool(ctx contractapi.TransactionContextInterface, nickNames ...string) error {
+	for _, nickName := range nickNames {
+		enterpriseID, err := getEnterpriseOf(ctx, nickName)
+		if err != nil {
+			return err
+		}
+		if enterpriseID == "" {
+			continue
+		}
+		enterprise, err := getEnterprise(ctx, enterpriseID)
+		if err != nil {
+			return err
+		}
+		if enterprise != nil && enterprise.PoolNickName == nickName {
+			return newError(mymberr.TransferRestricted, params("nickName", nickName, "enterpriseID", enterpriseID),
+				"%s is the pool of enterprise %s, allocate from it with AllocateEnterpriseToken or AllocateEnterprisePoints", nickName, enterpriseID)
+		}
+	}
+	return nil
+}
+
+// 기업 구성원이나 공용 풀이 될 유저가 존재하고 다른 기업에 속하지 않았는지 확인하는 도우미 함수
+func (c *TokenERC1155Contract) checkEnterpriseCandidate(ctx contractapi.TransactionContextInterface, nickName string) error {
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	enterpriseID, err := getEnterpriseOf(ctx, nickName)
+	if err != nil {
+		return err
+	}
+	if enterpriseID != "" {
+		return newError(mymberr.InvalidState, params("nickName", nickName, "enterpriseID", enterpriseID), "user %s already belongs to enterprise %s", nickName, enterpriseID)
+	}
+	return nil
+}
+
+// 기업 계정을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getEnterprise(ctx contractapi.TransactionContextInterface, enterpriseID string) (*Enterprise, error) {
+	enterpriseKey, err := ctx.GetStub().CreateCompositeKey(enterprisePrefix, []string{enterpriseID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	enterpriseBytes, err := ctx.GetStub().GetState(enterpriseKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read enterprise")
+	}
+	if enterpriseBytes == nil {
+		return nil, nil
+	}
+
+	var enterprise Enterprise
+	if err := unmarshalVersioned(enterprisePrefix, enterpriseBytes, &enterprise); err != nil {
+		return nil, wrapError(err, "failed to unmarshal enterprise")
+	}
+	return &enterprise, nil
+}
+
+func putEnterpriseMember(ctx contractapi.TransactionContextInterface, member *EnterpriseMember) error {
+	memberKey, err := ctx.GetStub().CreateCompositeKey(enterpriseMemberPrefix, []string{member.EnterpriseID, member.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	memberBytes, err := json.Marshal(member)
+	if err != nil {
+		return wrapError(err, "failed to marshal enterprise member")
+	}
+	if err := ctx.GetStub().PutState(memberKey, memberBytes); err != nil {
+		return wrapError(err, "failed to put state for enterprise member")
+	}
+	return nil
+}
+
+func putEnterpriseOf(ctx contractapi.TransactionContextInterface, nickName string, enterpriseID string) error {
+	enterpriseOfKey, err := ctx.GetStub().CreateCompositeKey(enterpriseOfPrefix, []string{nickName, enterpriseID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(enterpriseOfKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for enterprise index")
+	}
+	return nil
+}
+
+// 유저가 속한 기업 ID 를 반환하는 도우미 함수, 없으면 빈 문자열
+func getEnterpriseOf(ctx contractapi.TransactionContextInterface, nickName string) (string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(enterpriseOfPrefix, []string{nickName})
+	if err != nil {
+		return "", wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	if !resultsIterator.HasNext() {
+		return "", nil
+	}
+	queryResponse, err := resultsIterator.Next()
+	if err != nil {
+		return "", wrapError(err, "failed to get next query response")
+	}
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if err != nil {
+		return "", wrapError(err, "failed to split composite key")
+	}
+	return attributes[1], nil
+}
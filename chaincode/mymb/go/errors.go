@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+)
+
+// 코드가 지정된 구조화된 에러를 만드는 도우미 함수
+func newError(code mymberr.Code, params map[string]string, format string, args ...interface{}) error {
+	return mymberr.New(code, fmt.Sprintf(format, args...), params)
+}
+
+// 하위 호출의 에러를 감싸는 도우미 함수
+// 이미 코드가 지정된 에러는 그대로 전달하고, 그 외의 에러는 INTERNAL 로 감싼다
+func wrapError(err error, format string, args ...interface{}) error {
+	var coded *mymberr.Error
+	if errors.As(err, &coded) {
+		return coded
+	}
+	return mymberr.New(mymberr.Internal, fmt.Sprintf(format, args...)+": "+err.Error(), nil)
+}
+
+// 에러 파라미터 맵을 key, value 순서의 인자로 만드는 도우미 함수
+func params(keyValues ...string) map[string]string {
+	p := make(map[string]string, len(keyValues)/2)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		p[keyValues[i]] = keyValues[i+1]
+	}
+	return p
+}
@@ -0,0 +1,880 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FractionPool 토큰 하나를 잠그고 TotalShares 개의 지분으로 나눈 공동 소유 풀
+// 잠긴 토큰의 Owner 는 예치한 유저로 남고, 풀이 닫힐 때 회수한 유저에게 넘어간다
+type FractionPool struct {
+	SchemaVersion      int       `json:"schemaVersion"`
+	PoolID             string    `json:"poolID"`
+	TokenNumber        string    `json:"tokenNumber"`
+	Depositor          string    `json:"depositor"`
+	TotalShares        int64     `json:"totalShares"`
+	BuyoutThresholdBps int64     `json:"buyoutThresholdBps"`
+	Status             string    `json:"status"`
+	OpenOfferID        string    `json:"openOfferID"`
+	CreatedTime        time.Time `json:"createdTime"`
+	ClosedTime         time.Time `json:"closedTime"`
+	TokenRecipient     string    `json:"tokenRecipient"`
+}
+
+// FractionShare 유저 한 명이 가진 풀의 지분
+type FractionShare struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	PoolID        string `json:"poolID"`
+	NickName      string `json:"nickName"`
+	Shares        int64  `json:"shares"`
+}
+
+// FractionAllocation 풀을 만들 때 기여자에게 나누어 줄 지분
+type FractionAllocation struct {
+	NickName string `json:"nickName"`
+	Shares   int64  `json:"shares"`
+}
+
+// BuyoutOffer 풀의 나머지 지분을 지분당 PricePerShare MymPoint 에 사들이겠다는 제안
+// 제안자는 나머지 지분의 대금을 Escrow 로 미리 맡기고, 수락한 지분이 기준을 넘으면 모든 지분을 사들인다
+type BuyoutOffer struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	PoolID        string    `json:"poolID"`
+	OfferID       string    `json:"offerID"`
+	Buyer         string    `json:"buyer"`
+	PricePerShare int64     `json:"pricePerShare"`
+	Escrow        int64     `json:"escrow"`
+	Accepted      []string  `json:"accepted"`
+	Status        string    `json:"status"`
+	CreatedTime   time.Time `json:"createdTime"`
+	ClosedTime    time.Time `json:"closedTime"`
+}
+
+const (
+	// fractionPool~poolID 에 풀을, fractionShare~poolID~nickName 에 지분을 저장한다
+	// fractionHolder~nickName~poolID 는 유저별 지분 인덱스, fractionToken~tokenNumber~poolID 는 토큰 잠금 인덱스
+	fractionPoolPrefix   = "fractionPool"
+	fractionSharePrefix  = "fractionShare"
+	fractionHolderPrefix = "fractionHolder"
+	fractionTokenPrefix  = "fractionToken"
+	buyoutOfferPrefix    = "buyoutOffer"
+
+	fractionPoolOpen      = "open"
+	fractionPoolRedeemed  = "redeemed"
+	fractionPoolBoughtOut = "boughtOut"
+
+	buyoutOfferOpen      = "open"
+	buyoutOfferExecuted  = "executed"
+	buyoutOfferCancelled = "cancelled"
+
+	// 풀을 만들 때 나눌 수 있는 최대 지분 수와 한 번에 지분을 나누어 줄 수 있는 최대 기여자 수
+	maxFractionShares      = 1000000
+	maxFractionAllocations = 500
+)
+
+// FractionalizeToken 소유자가 토큰을 풀에 잠그고 기여자들에게 지분을 나누어 주는 함수
+// buyoutThresholdBps 는 매수 제안이 실행되는 데 필요한 수락 지분 비율(만분율)
+func (c *TokenERC1155Contract) FractionalizeToken(ctx contractapi.TransactionContextInterface, poolID string, tokenNumber string, owner string,
+	allocations []FractionAllocation, buyoutThresholdBps int64) (*FractionPool, error) {
+
+	v := newValidator().
+		field("poolID", poolID).
+		field("tokenNumber", tokenNumber).
+		fieldAs("nickName", "owner", owner).
+		check(len(allocations) > 0 && len(allocations) <= maxFractionAllocations, "allocations", "size",
+			"must contain between 1 and "+strconv.Itoa(maxFractionAllocations)+" entries").
+		check(buyoutThresholdBps > 0 && buyoutThresholdBps <= 10000, "buyoutThresholdBps", "range", "must be between 1 and 10000")
+	nickNames := make([]string, len(allocations))
+	var totalShares int64
+	exceeded := false
+	for i, allocation := range allocations {
+		nickNames[i] = allocation.NickName
+		v.positive("allocations["+strconv.Itoa(i)+"].shares", allocation.Shares)
+		// 더하기 전에 한도를 확인해야 큰 지분 수가 int64 범위를 넘어 한도 아래로 돌아오지 않는다
+		if allocation.Shares > maxFractionShares-totalShares {
+			exceeded = true
+		} else if allocation.Shares > 0 {
+			totalShares += allocation.Shares
+		}
+	}
+	v.list("nickName", "allocations", nickNames).
+		check(!exceeded, "allocations", "max", "total shares must not exceed "+strconv.Itoa(maxFractionShares))
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	existing, err := getFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.FractionPoolExists, params("poolID", poolID), "fraction pool %s already exists", poolID)
+	}
+
+	user, err := c.GetUser(ctx, owner)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", owner), "user %s does not exist", owner)
+	}
+	if !contains(user.OwnedToken, tokenNumber) {
+		return nil, newError(mymberr.NotOwner, params("nickName", owner, "tokenNumber", tokenNumber), "user %s does not own the specified token %s", owner, tokenNumber)
+	}
+
+	for _, nickName := range nickNames {
+		contributor, err := c.GetUser(ctx, nickName)
+		if err != nil {
+			return nil, wrapError(err, "failed to get user information")
+		}
+		if contributor.UserId == "" {
+			return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+		}
+	}
+	if err := checkNotFrozen(ctx, append([]string{owner}, nickNames...)...); err != nil {
+		return nil, err
+	}
+	if err := checkNotEnterprisePool(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 풀에 넣는 것은 나중에 다른 유저에게 넘어갈 수 있으므로 전송할 수 있는 토큰만 넣을 수 있다
+	if err := checkTokenTransferable(ctx, token, now); err != nil {
+		return nil, err
+	}
+	tokenType, err := getTokenType(ctx, token.TokenType)
+	if err != nil {
+		return nil, err
+	}
+	if tokenType != nil && tokenType.Fungible {
+		return nil, newError(mymberr.InvalidArgument, params("tokenNumber", tokenNumber, "tokenType", token.TokenType),
+			"token %s of fungible type %s cannot be fractionalized", tokenNumber, token.TokenType)
+	}
+
+	pool := FractionPool{
+		SchemaVersion:      fractionPoolSchemaVersion,
+		PoolID:             poolID,
+		TokenNumber:        tokenNumber,
+		Depositor:          owner,
+		TotalShares:        totalShares,
+		BuyoutThresholdBps: buyoutThresholdBps,
+		Status:             fractionPoolOpen,
+		CreatedTime:        now,
+	}
+	if err := putFractionPool(ctx, &pool); err != nil {
+		return nil, err
+	}
+
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(fractionTokenPrefix, []string{tokenNumber, poolID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(tokenKey, []byte{0x00}); err != nil {
+		return nil, wrapError(err, "failed to put state for fraction token lock")
+	}
+
+	for _, allocation := range allocations {
+		share := FractionShare{SchemaVersion: fractionShareSchemaVersion, PoolID: poolID, NickName: allocation.NickName, Shares: allocation.Shares}
+		if err := putFractionShare(ctx, &share); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pool, nil
+}
+
+// TransferShares 풀의 지분을 다른 유저에게 전송하는 함수, 매수 제안이 열려 있는 동안에는 전송할 수 없다
+func (c *TokenERC1155Contract) TransferShares(ctx contractapi.TransactionContextInterface, poolID string, from string, to string, shares int64) error {
+
+	err := newValidator().
+		field("poolID", poolID).
+		fieldAs("nickName", "from", from).
+		fieldAs("nickName", "to", to).
+		check(from != to, "to", "different", "sender and receiver must be different").
+		positive("shares", shares).
+		err()
+	if err != nil {
+		return err
+	}
+
+	pool, err := c.getOpenFractionPool(ctx, poolID)
+	if err != nil {
+		return err
+	}
+	if pool.OpenOfferID != "" {
+		return newError(mymberr.InvalidState, params("poolID", poolID, "offerID", pool.OpenOfferID), "shares of pool %s are locked by buyout offer %s", poolID, pool.OpenOfferID)
+	}
+
+	toUser, err := c.GetUser(ctx, to)
+	if err != nil {
+		return wrapError(err, "failed to get receiver information")
+	}
+	if toUser.UserId == "" {
+		return newError(mymberr.UserNotFound, params("nickName", to), "receiver %s does not exist", to)
+	}
+	if err := checkNotFrozen(ctx, from, to); err != nil {
+		return err
+	}
+
+	fromShare, err := getFractionShare(ctx, poolID, from)
+	if err != nil {
+		return err
+	}
+	if fromShare.Shares < shares {
+		return newError(mymberr.InsufficientShares, params("poolID", poolID, "nickName", from, "shares", strconv.FormatInt(fromShare.Shares, 10)),
+			"%s holds %d shares of pool %s, cannot transfer %d", from, fromShare.Shares, poolID, shares)
+	}
+	toShare, err := getFractionShare(ctx, poolID, to)
+	if err != nil {
+		return err
+	}
+
+	fromShare.Shares -= shares
+	toShare.Shares += shares
+	if err := putFractionShare(ctx, fromShare); err != nil {
+		return err
+	}
+	return putFractionShare(ctx, toShare)
+}
+
+// RedeemToken 풀의 모든 지분을 가진 유저가 잠긴 토큰을 찾아가는 함수
+func (c *TokenERC1155Contract) RedeemToken(ctx contractapi.TransactionContextInterface, poolID string, nickName string) (*FractionPool, error) {
+
+	err := newValidator().
+		field("poolID", poolID).
+		field("nickName", nickName).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.getOpenFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if pool.OpenOfferID != "" {
+		return nil, newError(mymberr.InvalidState, params("poolID", poolID, "offerID", pool.OpenOfferID), "pool %s has an open buyout offer %s", poolID, pool.OpenOfferID)
+	}
+
+	share, err := getFractionShare(ctx, poolID, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if share.Shares != pool.TotalShares {
+		return nil, newError(mymberr.InsufficientShares, params("poolID", poolID, "nickName", nickName, "shares", strconv.FormatInt(share.Shares, 10)),
+			"%s holds %d of %d shares of pool %s", nickName, share.Shares, pool.TotalShares, poolID)
+	}
+
+	users := map[string]*User{}
+	if err := c.closeFractionPool(ctx, pool, fractionPoolRedeemed, nickName, users); err != nil {
+		return nil, err
+	}
+	if err := putUsers(ctx, users); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// OfferBuyout 나머지 지분을 지분당 pricePerShare MymPoint 에 사들이겠다고 제안하는 함수
+// 제안자가 이미 가진 지분을 뺀 나머지 대금을 제안자의 MymPoint 에서 미리 맡긴다
+func (c *TokenERC1155Contract) OfferBuyout(ctx contractapi.TransactionContextInterface, poolID string, buyer string, pricePerShare int64) (*BuyoutOffer, error) {
+
+	err := newValidator().
+		field("poolID", poolID).
+		fieldAs("nickName", "buyer", buyer).
+		positive("pricePerShare", pricePerShare).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.getOpenFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if pool.OpenOfferID != "" {
+		return nil, newError(mymberr.InvalidState, params("poolID", poolID, "offerID", pool.OpenOfferID), "pool %s already has an open buyout offer %s", poolID, pool.OpenOfferID)
+	}
+
+	user, err := c.GetUser(ctx, buyer)
+	if err != nil {
+		return nil, wrapError(err, "failed to get buyer information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", buyer), "buyer %s does not exist", buyer)
+	}
+	if err := checkNotFrozen(ctx, buyer); err != nil {
+		return nil, err
+	}
+	if err := checkNotEnterprisePool(ctx, buyer); err != nil {
+		return nil, err
+	}
+
+	share, err := getFractionShare(ctx, poolID, buyer)
+	if err != nil {
+		return nil, err
+	}
+	remaining := pool.TotalShares - share.Shares
+	if remaining == 0 {
+		return nil, newError(mymberr.InvalidState, params("poolID", poolID, "nickName", buyer), "%s already holds every share of pool %s, use RedeemToken", buyer, poolID)
+	}
+	// 곱이 int64 범위를 넘으면 음수가 되어 맡길 금액 확인을 통과하므로 미리 막는다
+	if pricePerShare > math.MaxInt64/remaining {
+		return nil, newError(mymberr.InvalidArgument, params("field", "pricePerShare"), "price %d for %d shares exceeds the MymPoint range", pricePerShare, remaining)
+	}
+	escrow := remaining * pricePerShare
+	if user.MymPoint < escrow {
+		return nil, newError(mymberr.InsufficientPoints, params("nickName", buyer), "buyer %s has insufficient MymPoint for escrow %d", buyer, escrow)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := BuyoutOffer{
+		SchemaVersion: buyoutOfferSchemaVersion,
+		PoolID:        poolID,
+		OfferID:       ctx.GetStub().GetTxID(),
+		Buyer:         buyer,
+		PricePerShare: pricePerShare,
+		Escrow:        escrow,
+		Accepted:      []string{},
+		Status:        buyoutOfferOpen,
+		CreatedTime:   now,
+	}
+	if share.Shares > 0 {
+		offer.Accepted = append(offer.Accepted, buyer)
+	}
+
+	user.MymPoint -= escrow
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+	pool.OpenOfferID = offer.OfferID
+	if err := putFractionPool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if err := putBuyoutOffer(ctx, &offer); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// AcceptBuyout 지분 보유자가 열린 매수 제안을 수락하는 함수
+// 수락한 지분이 풀의 기준 비율 이상이 되면 모든 지분을 사들이고 토큰을 제안자에게 넘긴다
+func (c *TokenERC1155Contract) AcceptBuyout(ctx contractapi.TransactionContextInterface, poolID string, holder string) (*BuyoutOffer, error) {
+
+	err := newValidator().
+		field("poolID", poolID).
+		fieldAs("nickName", "holder", holder).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.getOpenFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	offer, err := c.GetBuyoutOffer(ctx, poolID, pool.OpenOfferID)
+	if err != nil {
+		return nil, err
+	}
+	if contains(offer.Accepted, holder) {
+		return nil, newError(mymberr.InvalidState, params("poolID", poolID, "nickName", holder), "%s has already accepted the buyout of pool %s", holder, poolID)
+	}
+
+	share, err := getFractionShare(ctx, poolID, holder)
+	if err != nil {
+		return nil, err
+	}
+	if share.Shares == 0 {
+		return nil, newError(mymberr.InsufficientShares, params("poolID", poolID, "nickName", holder, "shares", "0"), "%s holds no shares of pool %s", holder, poolID)
+	}
+	if err := checkNotFrozen(ctx, holder); err != nil {
+		return nil, err
+	}
+
+	offer.Accepted = append(offer.Accepted, holder)
+
+	// 지분은 제안이 열려 있는 동안 움직일 수 없으므로 수락한 유저들의 지금 지분을 합하면 된다
+	var acceptedShares int64
+	for _, nickName := range offer.Accepted {
+		accepted, err := getFractionShare(ctx, poolID, nickName)
+		if err != nil {
+			return nil, err
+		}
+		acceptedShares += accepted.Shares
+	}
+	if acceptedShares*10000 < pool.TotalShares*pool.BuyoutThresholdBps {
+		if err := putBuyoutOffer(ctx, offer); err != nil {
+			return nil, err
+		}
+		return offer, nil
+	}
+
+	if err := c.executeBuyout(ctx, pool, offer); err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// CancelBuyout 제안자가 실행되지 않은 매수 제안을 취소하고 맡긴 MymPoint 를 돌려받는 함수
+func (c *TokenERC1155Contract) CancelBuyout(ctx contractapi.TransactionContextInterface, poolID string, buyer string) (*BuyoutOffer, error) {
+
+	err := newValidator().
+		field("poolID", poolID).
+		fieldAs("nickName", "buyer", buyer).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.getOpenFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	offer, err := c.GetBuyoutOffer(ctx, poolID, pool.OpenOfferID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.Buyer != buyer {
+		return nil, newError(mymberr.Unauthorized, params("poolID", poolID, "nickName", buyer), "%s did not make the buyout offer of pool %s", buyer, poolID)
+	}
+
+	user, err := c.GetUser(ctx, buyer)
+	if err != nil {
+		return nil, wrapError(err, "failed to get buyer information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", buyer), "buyer %s does not exist", buyer)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user.MymPoint += offer.Escrow
+	offer.Escrow = 0
+	offer.Status = buyoutOfferCancelled
+	offer.ClosedTime = now
+	pool.OpenOfferID = ""
+
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := putFractionPool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if err := putBuyoutOffer(ctx, offer); err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// GetFractionPool 공동 소유 풀을 조회하는 함수
+func (c *TokenERC1155Contract) GetFractionPool(ctx contractapi.TransactionContextInterface, poolID string) (*FractionPool, error) {
+
+	pool, err := getFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil {
+		return nil, newError(mymberr.FractionPoolNotFound, params("poolID", poolID), "fraction pool %s does not exist", poolID)
+	}
+	return pool, nil
+}
+
+// GetFractionShares 풀의 모든 지분 보유자를 조회하는 함수
+func (c *TokenERC1155Contract) GetFractionShares(ctx contractapi.TransactionContextInterface, poolID string) ([]FractionShare, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(fractionSharePrefix, []string{poolID})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	shares := []FractionShare{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var share FractionShare
+		if err := unmarshalVersioned(fractionSharePrefix, queryResponse.Value, &share); err != nil {
+			return nil, wrapError(err, "failed to unmarshal fraction share")
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+// GetUserFractionShares 유저가 가진 모든 풀의 지분을 조회하는 함수
+func (c *TokenERC1155Contract) GetUserFractionShares(ctx contractapi.TransactionContextInterface, nickName string) ([]FractionShare, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(fractionHolderPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	shares := []FractionShare{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		share, err := getFractionShare(ctx, attributes[1], nickName)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+
+	return shares, nil
+}
+
+// GetBuyoutOffer 풀의 매수 제안을 조회하는 함수
+func (c *TokenERC1155Contract) GetBuyoutOffer(ctx contractapi.TransactionContextInterface, poolID string, offerID string) (*BuyoutOffer, error) {
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(buyoutOfferPrefix, []string{poolID, offerID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	offerBytes, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read buyout offer")
+	}
+	if offerBytes == nil {
+		return nil, newError(mymberr.BuyoutOfferNotFound, params("poolID", poolID, "offerID", offerID), "buyout offer %s of pool %s does not exist", offerID, poolID)
+	}
+
+	var offer BuyoutOffer
+	if err := unmarshalVersioned(buyoutOfferPrefix, offerBytes, &offer); err != nil {
+		return nil, wrapError(err, "failed to unmarshal buyout offer")
+	}
+	return &offer, nil
+}
+
+// 매수 제안을 실행하는 도우미 함수, 제안자 외의 보유자에게 지분 대금을 나누어 주고 토큰을 제안자에게 넘긴다
+func (c *TokenERC1155Contract) executeBuyout(ctx contractapi.TransactionContextInterface, pool *FractionPool, offer *BuyoutOffer) error {
+
+	shares, err := c.GetFractionShares(ctx, pool.PoolID)
+	if err != nil {
+		return err
+	}
+
+	// 한 트랜잭션 안에서는 쓰기 결과를 다시 읽을 수 없으므로 유저를 모아서 한 번씩만 저장한다
+	users := map[string]*User{}
+	paid := int64(0)
+	for _, share := range shares {
+		if share.NickName == offer.Buyer || share.Shares == 0 {
+			continue
+		}
+		// 동결된 유저는 MymPoint 를 받을 수 없으므로 동결이 풀릴 때까지 매수를 실행하지 않는다
+		if err := checkNotFrozen(ctx, share.NickName); err != nil {
+			return err
+		}
+		user, err := c.loadUser(ctx, users, share.NickName)
+		if err != nil {
+			return err
+		}
+		// 제안할 때 나머지 지분 전체의 대금이 int64 범위 안인지 확인했으므로 넘치지 않는다
+		amount := share.Shares * offer.PricePerShare
+		user.MymPoint += amount
+		paid += amount
+	}
+	if paid > offer.Escrow {
+		return newError(mymberr.Internal, params("poolID", pool.PoolID, "offerID", offer.OfferID), "buyout of pool %s pays %d but only %d is escrowed", pool.PoolID, paid, offer.Escrow)
+	}
+	if refund := offer.Escrow - paid; refund > 0 {
+		buyer, err := c.loadUser(ctx, users, offer.Buyer)
+		if err != nil {
+			return err
+		}
+		buyer.MymPoint += refund
+	}
+
+	if err := c.closeFractionPool(ctx, pool, fractionPoolBoughtOut, offer.Buyer, users); err != nil {
+		return err
+	}
+	if err := putUsers(ctx, users); err != nil {
+		return err
+	}
+
+	offer.Status = buyoutOfferExecuted
+	offer.Escrow = 0
+	offer.ClosedTime = pool.ClosedTime
+	return putBuyoutOffer(ctx, offer)
+}
+
+// 풀을 닫고 지분을 지운 뒤 잠긴 토큰을 recipient 에게 넘기는 도우미 함수, 바뀐 유저는 users 에 모아 호출한 쪽에서 저장한다
+func (c *TokenERC1155Contract) closeFractionPool(ctx contractapi.TransactionContextInterface, pool *FractionPool, status string, recipient string, users map[string]*User) error {
+
+	token, err := c.GetToken(ctx, pool.TokenNumber)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if token.Owner != recipient {
+		if err := checkNotFrozen(ctx, token.Owner, recipient); err != nil {
+			return err
+		}
+		if err := checkKYCRequirement(ctx, token, recipient, now); err != nil {
+			return err
+		}
+		owner, err := c.loadUser(ctx, users, token.Owner)
+		if err != nil {
+			return err
+		}
+		receiver, err := c.loadUser(ctx, users, recipient)
+		if err != nil {
+			return err
+		}
+		owner.OwnedToken = removeToken(owner.OwnedToken, token.TokenNumber)
+		receiver.OwnedToken = append(receiver.OwnedToken, token.TokenNumber)
+		if err := updateHoldings(ctx, transferHolding(token, token.Owner, recipient)); err != nil {
+			return err
+		}
+		recordTokenTransfer(token, recipient, now)
+		if err := putToken(ctx, token); err != nil {
+			return err
+		}
+	}
+
+	shares, err := c.GetFractionShares(ctx, pool.PoolID)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if err := deleteFractionShare(ctx, pool.PoolID, share.NickName); err != nil {
+			return err
+		}
+	}
+
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(fractionTokenPrefix, []string{pool.TokenNumber, pool.PoolID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(tokenKey); err != nil {
+		return wrapError(err, "failed to delete fraction token lock")
+	}
+
+	pool.Status = status
+	pool.OpenOfferID = ""
+	pool.TokenRecipient = recipient
+	pool.ClosedTime = now
+	return putFractionPool(ctx, pool)
+}
+
+// 열린 풀을 조회하는 도우미 함수
+func (c *TokenERC1155Contract) getOpenFractionPool(ctx contractapi.TransactionContextInterface, poolID string) (*FractionPool, error) {
+	pool, err := c.GetFractionPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Status != fractionPoolOpen {
+		return nil, newError(mymberr.InvalidState, params("poolID", poolID, "status", pool.Status), "fraction pool %s is %s", poolID, pool.Status)
+	}
+	return pool, nil
+}
+
+// 토큰을 잠근 풀 ID 를 반환하는 도우미 함수, 잠겨 있지 않으면 빈 문자열
+func getTokenFractionPoolID(ctx contractapi.TransactionContextInterface, tokenNumber string) (string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(fractionTokenPrefix, []string{tokenNumber})
+	if err != nil {
+		return "", wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	if !resultsIterator.HasNext() {
+		return "", nil
+	}
+	queryResponse, err := resultsIterator.Next()
+	if err != nil {
+		return "", wrapError(err, "failed to get next query response")
+	}
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if err != nil {
+		return "", wrapError(err, "failed to split composite key")
+	}
+	return attributes[1], nil
+}
+
+// 풀을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getFractionPool(ctx contractapi.TransactionContextInterface, poolID string) (*FractionPool, error) {
+	poolKey, err := ctx.GetStub().CreateCompositeKey(fractionPoolPrefix, []string{poolID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	poolBytes, err := ctx.GetStub().GetState(poolKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read fraction pool")
+	}
+	if poolBytes == nil {
+		return nil, nil
+	}
+
+	var pool FractionPool
+	if err := unmarshalVersioned(fractionPoolPrefix, poolBytes, &pool); err != nil {
+		return nil, wrapError(err, "failed to unmarshal fraction pool")
+	}
+	return &pool, nil
+}
+
+func putFractionPool(ctx contractapi.TransactionContextInterface, pool *FractionPool) error {
+	poolKey, err := ctx.GetStub().CreateCompositeKey(fractionPoolPrefix, []string{pool.PoolID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	poolBytes, err := json.Marshal(pool)
+	if err != nil {
+		return wrapError(err, "failed to marshal fraction pool")
+	}
+	if err := ctx.GetStub().PutState(poolKey, poolBytes); err != nil {
+		return wrapError(err, "failed to put state for fraction pool")
+	}
+	return nil
+}
+
+// 지분을 조회하는 도우미 함수, 없으면 0 지분을 반환
+func getFractionShare(ctx contractapi.TransactionContextInterface, poolID string, nickName string) (*FractionShare, error) {
+	shareKey, err := ctx.GetStub().CreateCompositeKey(fractionSharePrefix, []string{poolID, nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	shareBytes, err := ctx.GetStub().GetState(shareKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read fraction share")
+	}
+	if shareBytes == nil {
+		return &FractionShare{SchemaVersion: fractionShareSchemaVersion, PoolID: poolID, NickName: nickName}, nil
+	}
+
+	var share FractionShare
+	if err := unmarshalVersioned(fractionSharePrefix, shareBytes, &share); err != nil {
+		return nil, wrapError(err, "failed to unmarshal fraction share")
+	}
+	return &share, nil
+}
+
+// 지분을 저장하는 도우미 함수, 0 지분이 되면 지분과 인덱스를 지운다
+func putFractionShare(ctx contractapi.TransactionContextInterface, share *FractionShare) error {
+	if share.Shares == 0 {
+		return deleteFractionShare(ctx, share.PoolID, share.NickName)
+	}
+
+	shareKey, err := ctx.GetStub().CreateCompositeKey(fractionSharePrefix, []string{share.PoolID, share.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	shareBytes, err := json.Marshal(share)
+	if err != nil {
+		return wrapError(err, "failed to marshal fraction share")
+	}
+	if err := ctx.GetStub().PutState(shareKey, shareBytes); err != nil {
+		return wrapError(err, "failed to put state for fraction share")
+	}
+
+	holderKey, err := ctx.GetStub().CreateCompositeKey(fractionHolderPrefix, []string{share.NickName, share.PoolID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(holderKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for fraction holder index")
+	}
+	return nil
+}
+
+func deleteFractionShare(ctx contractapi.TransactionContextInterface, poolID string, nickName string) error {
+	shareKey, err := ctx.GetStub().CreateCompositeKey(fractionSharePrefix, []string{poolID, nickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(shareKey); err != nil {
+		return wrapError(err, "failed to delete fraction share")
+	}
+	holderKey, err := ctx.GetStub().CreateCompositeKey(fractionHolderPrefix, []string{nickName, poolID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(holderKey); err != nil {
+		return wrapError(err, "failed to delete fraction holder index")
+	}
+	return nil
+}
+
+func putBuyoutOffer(ctx contractapi.TransactionContextInterface, offer *BuyoutOffer) error {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(buyoutOfferPrefix, []string{offer.PoolID, offer.OfferID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	offerBytes, err := json.Marshal(offer)
+	if err != nil {
+		return wrapError(err, "failed to marshal buyout offer")
+	}
+	if err := ctx.GetStub().PutState(offerKey, offerBytes); err != nil {
+		return wrapError(err, "failed to put state for buyout offer")
+	}
+	return nil
+}
+
+// 유저를 한 번만 읽어 users 에 모아 두는 도우미 함수
+func (c *TokenERC1155Contract) loadUser(ctx contractapi.TransactionContextInterface, users map[string]*User, nickName string) (*User, error) {
+	if user, ok := users[nickName]; ok {
+		return user, nil
+	}
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+	users[nickName] = user
+	return user, nil
+}
+
+// users 에 모은 유저들을 닉네임 순서로 저장하는 도우미 함수
+func putUsers(ctx contractapi.TransactionContextInterface, users map[string]*User) error {
+	nickNames := make([]string, 0, len(users))
+	for nickName := range users {
+		nickNames = append(nickNames, nickName)
+	}
+	sort.Strings(nickNames)
+	for _, nickName := range nickNames {
+		if err := putUser(ctx, users[nickName]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AccountStatus 유저 계정의 동결 상태와 마지막 변경 기록
+type AccountStatus struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	Frozen        bool      `json:"frozen"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+const accountStatusPrefix = "accountStatus"
+
+// FreezeUser 유저 계정을 동결하는 함수, 동결된 유저가 관련된 토큰, 포인트 변경은 모두 거부된다
+func (c *TokenERC1155Contract) FreezeUser(ctx contractapi.TransactionContextInterface, nickName string, reason string, actor string) (*AccountStatus, error) {
+	return c.setAccountFrozen(ctx, nickName, true, reason, actor)
+}
+
+// UnfreezeUser 유저 계정 동결을 해제하는 함수
+func (c *TokenERC1155Contract) UnfreezeUser(ctx contractapi.TransactionContextInterface, nickName string, reason string, actor string) (*AccountStatus, error) {
+	return c.setAccountFrozen(ctx, nickName, false, reason, actor)
+}
+
+// GetAccountStatus 유저 계정의 동결 상태를 조회하는 함수
+func (c *TokenERC1155Contract) GetAccountStatus(ctx contractapi.TransactionContextInterface, nickName string) (*AccountStatus, error) {
+
+	status, err := getAccountStatus(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return &AccountStatus{NickName: nickName}, nil
+	}
+	return status, nil
+}
+
+// GetFrozenUsers 동결된 유저 계정들을 조회하는 함수
+func (c *TokenERC1155Contract) GetFrozenUsers(ctx contractapi.TransactionContextInterface) ([]AccountStatus, error) {
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accountStatusPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	frozen := []AccountStatus{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var status AccountStatus
+		if err := unmarshalVersioned(accountStatusPrefix, queryResponse.Value, &status); err != nil {
+			return nil, wrapError(err, "failed to unmarshal account status")
+		}
+		if status.Frozen {
+			frozen = append(frozen, status)
+		}
+	}
+
+	fmt.Printf("total: %d frozen users\n", len(frozen))
+	return frozen, nil
+}
+
+func (c *TokenERC1155Contract) setAccountFrozen(ctx contractapi.TransactionContextInterface, nickName string, frozen bool, reason string, actor string) (*AccountStatus, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		field("reason", reason).
+		field("actor", actor).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	current, err := getAccountStatus(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if (current != nil && current.Frozen == frozen) || (current == nil && !frozen) {
+		return nil, newError(mymberr.InvalidState, params("nickName", nickName), "user %s is already %s", nickName, frozenLabel(frozen))
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := AccountStatus{
+		SchemaVersion: accountStatusSchemaVersion,
+		NickName:      nickName,
+		Frozen:        frozen,
+		Reason:        reason,
+		Actor:         actor,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	statusKey, err := ctx.GetStub().CreateCompositeKey(accountStatusPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	statusBytes, err := json.Marshal(status)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal account status")
+	}
+	if err := ctx.GetStub().PutState(statusKey, statusBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for account status")
+	}
+
+	eventName := "FreezeUser"
+	if !frozen {
+		eventName = "UnfreezeUser"
+	}
+	if err := ctx.GetStub().SetEvent(eventName, statusBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &status, nil
+}
+
+// 계정 상태를 조회하는 도우미 함수, 기록이 없으면 nil 을 반환
+func getAccountStatus(ctx contractapi.TransactionContextInterface, nickName string) (*AccountStatus, error) {
+	statusKey, err := ctx.GetStub().CreateCompositeKey(accountStatusPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	statusBytes, err := ctx.GetStub().GetState(statusKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read account status")
+	}
+	if statusBytes == nil {
+		return nil, nil
+	}
+
+	var status AccountStatus
+	if err := unmarshalVersioned(accountStatusPrefix, statusBytes, &status); err != nil {
+		return nil, wrapError(err, "failed to unmarshal account status")
+	}
+	return &status, nil
+}
+
+// 유저 계정이 동결되어 있는지 확인하는 도우미 함수
+func isFrozen(ctx contractapi.TransactionContextInterface, nickName string) (bool, error) {
+	status, err := getAccountStatus(ctx, nickName)
+	if err != nil {
+		return false, err
+	}
+	return status != nil && status.Frozen, nil
+}
+
+// 관련된 유저 중 동결된 계정이 있으면 에러를 반환하는 도우미 함수
+func checkNotFrozen(ctx contractapi.TransactionContextInterface, nickNames ...string) error {
+	for _, nickName := range nickNames {
+		status, err := getAccountStatus(ctx, nickName)
+		if err != nil {
+			return err
+		}
+		if status != nil && status.Frozen {
+			return newError(mymberr.UserFrozen, params("nickName", nickName, "reason", status.Reason), "user %s is frozen: %s", nickName, status.Reason)
+		}
+	}
+	return nil
+}
+
+func frozenLabel(frozen bool) string {
+	if frozen {
+		return "frozen"
+	}
+	return "not frozen"
+}
@@ -3,6 +3,8 @@ module github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go
 go 1.14
 
 require (
-    go.mongodb.org/mongo-driver v1.4.6
-    github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
+	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200424173316-dd554ba3746e
+	go.mongodb.org/mongo-driver v1.4.6
 )
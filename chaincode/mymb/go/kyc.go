@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// KYCAttestation 인증 기관(attestor)이 기록한 유저의 신원 확인 단계
+// 신원 정보 자체는 저장하지 않고 단계, 발급자, 만료 시각만 저장한다
+type KYCAttestation struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	Level         int       `json:"level"`
+	Issuer        string    `json:"issuer"`
+	ClientID      string    `json:"clientID"`
+	IssuedTime    time.Time `json:"issuedTime"`
+	ExpiryTime    time.Time `json:"expiryTime"`
+	Revoked       bool      `json:"revoked"`
+	RevokeReason  string    `json:"revokeReason"`
+}
+
+// KYCRequirement tokenType 또는 funding 토큰을 받거나 발행받는 데 필요한 최소 KYC 단계
+type KYCRequirement struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Scope         string `json:"scope"`
+	ScopeID       string `json:"scopeID"`
+	MinLevel      int    `json:"minLevel"`
+}
+
+const (
+	kycPrefix            = "kyc"
+	kycRequirementPrefix = "kycRequirement"
+
+	// KYC 단계, 0 은 확인되지 않은 유저
+	kycLevelNone      = 0
+	kycLevelIdentity  = 1
+	kycLevelCertified = 2
+	kycLevelEnhanced  = 3
+
+	// KYC 를 기록할 수 있는 역할
+	roleAttestor = "attestor"
+)
+
+// AttestKYC 유저의 KYC 단계를 기록하는 함수, expiryTime 은 인증이 만료되는 유닉스 시각(초)
+func (c *TokenERC1155Contract) AttestKYC(ctx contractapi.TransactionContextInterface, nickName string, level int, issuer string, expiryTime int64) (*KYCAttestation, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		check(level >= kycLevelIdentity && level <= kycLevelEnhanced, "level", "range",
+			"must be between "+strconv.Itoa(kycLevelIdentity)+" and "+strconv.Itoa(kycLevelEnhanced)).
+		field("issuer", issuer).
+		positive("expiryTime", expiryTime).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleAttestor, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expiry := time.Unix(expiryTime, 0).UTC()
+	if !expiry.After(now) {
+		return nil, newError(mymberr.InvalidArgument, params("field", "expiryTime"), "expiry time %s is not in the future", expiry.Format(time.RFC3339))
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attestation := KYCAttestation{
+		SchemaVersion: kycSchemaVersion,
+		NickName:      nickName,
+		Level:         level,
+		Issuer:        issuer,
+		ClientID:      clientID,
+		IssuedTime:    now,
+		ExpiryTime:    expiry,
+	}
+	if err := putKYCAttestation(ctx, &attestation); err != nil {
+		return nil, err
+	}
+	return &attestation, nil
+}
+
+// RevokeKYC 유저의 KYC 인증을 철회하는 함수
+func (c *TokenERC1155Contract) RevokeKYC(ctx contractapi.TransactionContextInterface, nickName string, reason string) (*KYCAttestation, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		field("reason", reason).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleAttestor, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	attestation, err := c.GetKYC(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if attestation.Revoked {
+		return nil, newError(mymberr.InvalidState, params("nickName", nickName), "KYC of %s is already revoked", nickName)
+	}
+
+	attestation.Revoked = true
+	attestation.RevokeReason = reason
+	if err := putKYCAttestation(ctx, attestation); err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
+
+// GetKYC 유저의 KYC 기록을 조회하는 함수
+func (c *TokenERC1155Contract) GetKYC(ctx contractapi.TransactionContextInterface, nickName string) (*KYCAttestation, error) {
+
+	attestation, err := getKYCAttestation(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if attestation == nil {
+		return nil, newError(mymberr.KYCNotFound, params("nickName", nickName), "KYC of %s is not recorded", nickName)
+	}
+	return attestation, nil
+}
+
+// GetKYCLevel 현재 유효한 KYC 단계를 조회하는 함수, 기록이 없거나 만료, 철회되었으면 0
+func (c *TokenERC1155Contract) GetKYCLevel(ctx contractapi.TransactionContextInterface, nickName string) (int, error) {
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return kycLevel(ctx, nickName, now)
+}
+
+// SetKYCRequirement tokenType 또는 funding 토큰에 필요한 최소 KYC 단계를 지정하는 함수, 0 이면 요구 사항을 삭제한다
+func (c *TokenERC1155Contract) SetKYCRequirement(ctx contractapi.TransactionContextInterface, scope string, scopeID string, minLevel int) (*KYCRequirement, error) {
+
+	err := newValidator().
+		oneOf("scope", scope, policyScopeTokenType, policyScopeFunding).
+		field("scopeID", scopeID).
+		check(minLevel >= kycLevelNone && minLevel <= kycLevelEnhanced, "minLevel", "range",
+			"must be between "+strconv.Itoa(kycLevelNone)+" and "+strconv.Itoa(kycLevelEnhanced)).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	requirement := KYCRequirement{
+		SchemaVersion: kycRequirementSchemaVersion,
+		Scope:         scope,
+		ScopeID:       scopeID,
+		MinLevel:      minLevel,
+	}
+
+	requirementKey, err := ctx.GetStub().CreateCompositeKey(kycRequirementPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if minLevel == kycLevelNone {
+		if err := ctx.GetStub().DelState(requirementKey); err != nil {
+			return nil, wrapError(err, "failed to delete KYC requirement")
+		}
+		return &requirement, nil
+	}
+
+	requirementBytes, err := json.Marshal(requirement)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal KYC requirement")
+	}
+	if err := ctx.GetStub().PutState(requirementKey, requirementBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for KYC requirement")
+	}
+	return &requirement, nil
+}
+
+// GetKYCRequirement tokenType 또는 funding 에 필요한 최소 KYC 단계를 조회하는 함수, 지정되지 않았으면 0
+func (c *TokenERC1155Contract) GetKYCRequirement(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (*KYCRequirement, error) {
+
+	minLevel, err := getKYCRequirement(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	return &KYCRequirement{SchemaVersion: kycRequirementSchemaVersion, Scope: scope, ScopeID: scopeID, MinLevel: minLevel}, nil
+}
+
+// KYC 기록을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getKYCAttestation(ctx contractapi.TransactionContextInterface, nickName string) (*KYCAttestation, error) {
+	kycKey, err := ctx.GetStub().CreateCompositeKey(kycPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	attestationBytes, err := ctx.GetStub().GetState(kycKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read KYC attestation")
+	}
+	if attestationBytes == nil {
+		return nil, nil
+	}
+
+	var attestation KYCAttestation
+	if err := unmarshalVersioned(kycPrefix, attestationBytes, &attestation); err != nil {
+		return nil, wrapError(err, "failed to unmarshal KYC attestation")
+	}
+	return &attestation, nil
+}
+
+func putKYCAttestation(ctx contractapi.TransactionContextInterface, attestation *KYCAttestation) error {
+	kycKey, err := ctx.GetStub().CreateCompositeKey(kycPrefix, []string{attestation.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	attestationBytes, err := json.Marshal(attestation)
+	if err != nil {
+		return wrapError(err, "failed to marshal KYC attestation")
+	}
+	if err := ctx.GetStub().PutState(kycKey, attestationBytes); err != nil {
+		return wrapError(err, "failed to put state for KYC attestation")
+	}
+	return nil
+}
+
+// now 시점에 유효한 KYC 단계를 반환하는 도우미 함수
+func kycLevel(ctx contractapi.TransactionContextInterface, nickName string, now time.Time) (int, error) {
+	attestation, err := getKYCAttestation(ctx, nickName)
+	if err != nil {
+		return 0, err
+	}
+	if attestation == nil || attestation.Revoked || !now.Before(attestation.ExpiryTime) {
+		return kycLevelNone, nil
+	}
+	return attestation.Level, nil
+}
+
+// 최소 KYC 단계를 조회하는 도우미 함수, 지정되지 않았으면 0
+func getKYCRequirement(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (int, error) {
+	requirementKey, err := ctx.GetStub().CreateCompositeKey(kycRequirementPrefix, []string{scope, scopeID})
+	if err != nil {
+		return 0, wrapError(err, "failed to create composite key")
+	}
+	requirementBytes, err := ctx.GetStub().GetState(requirementKey)
+	if err != nil {
+		return 0, wrapError(err, "failed to read KYC requirement")
+	}
+	if requirementBytes == nil {
+		return kycLevelNone, nil
+	}
+
+	var requirement KYCRequirement
+	if err := unmarshalVersioned(kycRequirementPrefix, requirementBytes, &requirement); err != nil {
+		return 0, wrapError(err, "failed to unmarshal KYC requirement")
+	}
+	return requirement.MinLevel, nil
+}
+
+// 토큰을 받는 유저가 tokenType, funding 에 지정된 최소 KYC 단계를 만족하는지 확인하는 도우미 함수
+// 발행, 전송, 경매 낙찰, 에어드랍에서 토큰을 받는 유저를 확인할 때 쓴다
+func checkKYCRequirement(ctx contractapi.TransactionContextInterface, token *Token1155, nickName string, now time.Time) error {
+
+	required := kycLevelNone
+	for _, scope := range [][2]string{{policyScopeTokenType, token.TokenType}, {policyScopeFunding, token.FundingID}} {
+		if scope[1] == "" {
+			continue
+		}
+		minLevel, err := getKYCRequirement(ctx, scope[0], scope[1])
+		if err != nil {
+			return err
+		}
+		if minLevel > required {
+			required = minLevel
+		}
+	}
+	if required == kycLevelNone {
+		return nil
+	}
+
+	level, err := kycLevel(ctx, nickName, now)
+	if err != nil {
+		return err
+	}
+	if level < required {
+		return newError(mymberr.KYCRequired, params("nickName", nickName, "tokenNumber", token.TokenNumber,
+			"requiredLevel", strconv.Itoa(required), "level", strconv.Itoa(level)),
+			"user %s needs KYC level %d to receive token %s, has %d", nickName, required, token.TokenNumber, level)
+	}
+	return nil
+}
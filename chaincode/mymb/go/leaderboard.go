@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// LeaderboardEntry 순위표의 한 줄
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	NickName string `json:"nickName"`
+	Score    int64  `json:"score"`
+}
+
+// CategoryHolding 유저가 가진 카테고리별 토큰 수
+type CategoryHolding struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	CategoryCode  string `json:"categoryCode"`
+	NickName      string `json:"nickName"`
+	Count         int64  `json:"count"`
+}
+
+// LeaderboardRebuild RebuildLeaderboards 가 다시 만든 항목 수
+type LeaderboardRebuild struct {
+	Users    int `json:"users"`
+	Holdings int `json:"holdings"`
+}
+
+const (
+	// pointsRank~점수~nickName, categoryRank~categoryCode~점수~nickName 순위 인덱스는
+	// 키 순서가 곧 순위가 되도록 점수를 뒤집어 0 으로 채운 문자열을 쓴다
+	pointsRankPrefix      = "pointsRank"
+	categoryRankPrefix    = "categoryRank"
+	categoryHoldingPrefix = "categoryHolding"
+
+	maxLeaderboardSize = 100
+)
+
+// holdingChange 카테고리별 토큰 보유 수의 변화
+type holdingChange struct {
+	categoryCode string
+	nickName     string
+	delta        int64
+}
+
+// TopUsersByPoints MymPoint 가 많은 순서로 n 명을 조회하는 함수, 같은 점수면 닉네임 순서
+func (c *TokenERC1155Contract) TopUsersByPoints(ctx contractapi.TransactionContextInterface, n int) ([]LeaderboardEntry, error) {
+
+	err := newValidator().
+		check(n > 0 && n <= maxLeaderboardSize, "n", "range", "must be between 1 and "+strconv.Itoa(maxLeaderboardSize)).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	seeded, err := isIndexSeeded(ctx, indexLeaderboards)
+	if err != nil {
+		return nil, err
+	}
+	if !seeded {
+		users, err := c.GetAllUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		scores := map[string]int64{}
+		for _, user := range users {
+			scores[user.NickName] = user.MymPoint
+		}
+		return rankScores(scores, n), nil
+	}
+
+	return getLeaderboard(ctx, pointsRankPrefix, []string{}, n)
+}
+
+// TopHoldersByCategory 카테고리의 토큰을 많이 가진 순서로 n 명을 조회하는 함수, 같은 수면 닉네임 순서
+func (c *TokenERC1155Contract) TopHoldersByCategory(ctx contractapi.TransactionContextInterface, categoryCode string, n int) ([]LeaderboardEntry, error) {
+
+	err := newValidator().
+		field("categoryCode", categoryCode).
+		check(n > 0 && n <= maxLeaderboardSize, "n", "range", "must be between 1 and "+strconv.Itoa(maxLeaderboardSize)).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	seeded, err := isIndexSeeded(ctx, indexLeaderboards)
+	if err != nil {
+		return nil, err
+	}
+	if !seeded {
+		counts, err := c.scanHoldings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		scores := map[string]int64{}
+		for key, count := range counts {
+			if key[0] == categoryCode {
+				scores[key[1]] = count
+			}
+		}
+		return rankScores(scores, n), nil
+	}
+
+	return getLeaderboard(ctx, categoryRankPrefix, []string{categoryCode}, n)
+}
+
+// GetCategoryHolding 유저가 가진 카테고리별 토큰 수를 조회하는 함수
+func (c *TokenERC1155Contract) GetCategoryHolding(ctx contractapi.TransactionContextInterface, categoryCode string, nickName string) (*CategoryHolding, error) {
+
+	seeded, err := isIndexSeeded(ctx, indexLeaderboards)
+	if err != nil {
+		return nil, err
+	}
+	if !seeded {
+		counts, err := c.scanHoldings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &CategoryHolding{SchemaVersion: categoryHoldingSchemaVersion, CategoryCode: categoryCode, NickName: nickName,
+			Count: counts[[2]string{categoryCode, nickName}]}, nil
+	}
+
+	return getCategoryHolding(ctx, categoryCode, nickName)
+}
+
+// RebuildLeaderboards 모든 유저와 토큰을 읽어 순위 인덱스를 처음부터 다시 만드는 함수
+// 순위표가 생기기 전의 데이터를 반영하거나 인덱스가 어긋났을 때 관리자가 호출한다
+// 배포나 업그레이드 뒤 한 번 호출하기 전까지 순위 조회는 인덱스 대신 모든 유저와 토큰을 순회한다
+func (c *TokenERC1155Contract) RebuildLeaderboards(ctx contractapi.TransactionContextInterface) (*LeaderboardRebuild, error) {
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	for _, prefix := range []string{pointsRankPrefix, categoryRankPrefix, categoryHoldingPrefix} {
+		if err := deleteByPrefix(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	users, err := c.GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if err := putPointsRank(ctx, user.NickName, user.MymPoint); err != nil {
+			return nil, err
+		}
+	}
+
+	counts, err := c.scanHoldings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 이전 인덱스는 위에서 지웠으므로 이전 값을 읽지 않고 바로 쓴다
+	keys := sortedKeyPairs(counts)
+	for _, key := range keys {
+		holding := CategoryHolding{SchemaVersion: categoryHoldingSchemaVersion, CategoryCode: key[0], NickName: key[1], Count: counts[key]}
+		if err := putCategoryHolding(ctx, &holding); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := putIndexSeeded(ctx, indexLeaderboards); err != nil {
+		return nil, err
+	}
+
+	return &LeaderboardRebuild{Users: len(users), Holdings: len(keys)}, nil
+}
+
+// 모든 토큰을 읽어 카테고리, 유저별 보유 수를 계산하는 도우미 함수, 아무것도 쓰지 않는다
+func (c *TokenERC1155Contract) scanHoldings(ctx contractapi.TransactionContextInterface) (map[[2]string]int64, error) {
+	tokens, err := c.GetAllTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[[2]string]int64{}
+	for _, token := range tokens {
+		counts[[2]string{token.CategoryCode, token.Owner}]++
+	}
+	return counts, nil
+}
+
+// 순위 인덱스와 같은 순서(점수가 높은 순, 같으면 닉네임 순)로 앞의 n 명을 반환하는 도우미 함수
+func rankScores(scores map[string]int64, n int) []LeaderboardEntry {
+	nickNames := make([]string, 0, len(scores))
+	for nickName := range scores {
+		nickNames = append(nickNames, nickName)
+	}
+	sort.Slice(nickNames, func(i, j int) bool {
+		if scores[nickNames[i]] != scores[nickNames[j]] {
+			return scores[nickNames[i]] > scores[nickNames[j]]
+		}
+		return nickNames[i] < nickNames[j]
+	})
+
+	entries := []LeaderboardEntry{}
+	for _, nickName := range nickNames {
+		if len(entries) == n {
+			break
+		}
+		entries = append(entries, LeaderboardEntry{Rank: len(entries) + 1, NickName: nickName, Score: scores[nickName]})
+	}
+	return entries
+}
+
+// 순위 인덱스를 앞에서부터 n 개 읽는 도우미 함수, 마지막 두 속성이 점수와 닉네임
+func getLeaderboard(ctx contractapi.TransactionContextInterface, prefix string, attributes []string, n int) ([]LeaderboardEntry, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, attributes)
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	entries := []LeaderboardEntry{}
+
+	for resultsIterator.HasNext() && len(entries) < n {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		score, err := parseRankScore(keyAttributes[len(keyAttributes)-2])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, LeaderboardEntry{Rank: len(entries) + 1, NickName: keyAttributes[len(keyAttributes)-1], Score: score})
+	}
+
+	return entries, nil
+}
+
+// 유저가 저장될 때 MymPoint 순위 인덱스를 옮기는 도우미 함수
+// 같은 트랜잭션에서 쓴 값은 다시 읽을 수 없으므로 유저는 트랜잭션마다 한 번만 저장해야 한다
+func updatePointsRank(ctx contractapi.TransactionContextInterface, oldUser *User, user *User) error {
+	if oldUser != nil {
+		if oldUser.MymPoint == user.MymPoint {
+			return nil
+		}
+		if err := deletePointsRank(ctx, oldUser.NickName, oldUser.MymPoint); err != nil {
+			return err
+		}
+	}
+	return putPointsRank(ctx, user.NickName, user.MymPoint)
+}
+
+// MymPoint 순위 인덱스를 쓰는 도우미 함수
+func putPointsRank(ctx contractapi.TransactionContextInterface, nickName string, points int64) error {
+	rankKey, err := ctx.GetStub().CreateCompositeKey(pointsRankPrefix, []string{rankScore(points), nickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(rankKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for points rank")
+	}
+	return nil
+}
+
+// MymPoint 순위 인덱스를 지우는 도우미 함수
+func deletePointsRank(ctx contractapi.TransactionContextInterface, nickName string, points int64) error {
+	rankKey, err := ctx.GetStub().CreateCompositeKey(pointsRankPrefix, []string{rankScore(points), nickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(rankKey); err != nil {
+		return wrapError(err, "failed to delete points rank")
+	}
+	return nil
+}
+
+// 토큰이 from 에서 to 로 옮겨질 때의 보유 수 변화를 반환하는 도우미 함수, 발행은 from 이, 삭제는 to 가 빈 문자열
+func transferHolding(token *Token1155, from string, to string) []holdingChange {
+	changes := []holdingChange{}
+	if from != "" {
+		changes = append(changes, holdingChange{categoryCode: token.CategoryCode, nickName: from, delta: -1})
+	}
+	if to != "" {
+		changes = append(changes, holdingChange{categoryCode: token.CategoryCode, nickName: to, delta: 1})
+	}
+	return changes
+}
+
+// 보유 수 변화를 카테고리, 유저별로 합쳐 한 번씩 반영하는 도우미 함수
+// 여러 토큰을 한 번에 옮기는 트랜잭션에서도 같은 키를 두 번 읽고 쓰지 않도록 반드시 한 번에 모아서 호출한다
+func updateHoldings(ctx contractapi.TransactionContextInterface, changes []holdingChange) error {
+
+	deltas := map[[2]string]int64{}
+	for _, change := range changes {
+		deltas[[2]string{change.categoryCode, change.nickName}] += change.delta
+	}
+
+	for _, key := range sortedKeyPairs(deltas) {
+		if deltas[key] == 0 {
+			continue
+		}
+		holding, err := getCategoryHolding(ctx, key[0], key[1])
+		if err != nil {
+			return err
+		}
+		if holding.Count > 0 {
+			rankKey, err := ctx.GetStub().CreateCompositeKey(categoryRankPrefix, []string{key[0], rankScore(holding.Count), key[1]})
+			if err != nil {
+				return wrapError(err, "failed to create composite key")
+			}
+			if err := ctx.GetStub().DelState(rankKey); err != nil {
+				return wrapError(err, "failed to delete category rank")
+			}
+		}
+
+		holding.Count += deltas[key]
+		if holding.Count < 0 {
+			// 순위표가 생기기 전의 토큰이 옮겨지면 음수가 될 수 있다, RebuildLeaderboards 로 바로잡는다
+			holding.Count = 0
+		}
+		if err := putCategoryHolding(ctx, holding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 카테고리별 보유 수를 조회하는 도우미 함수, 없으면 0 을 반환
+func getCategoryHolding(ctx contractapi.TransactionContextInterface, categoryCode string, nickName string) (*CategoryHolding, error) {
+	holdingKey, err := ctx.GetStub().CreateCompositeKey(categoryHoldingPrefix, []string{categoryCode, nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	holdingBytes, err := ctx.GetStub().GetState(holdingKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read category holding")
+	}
+	if holdingBytes == nil {
+		return &CategoryHolding{SchemaVersion: categoryHoldingSchemaVersion, CategoryCode: categoryCode, NickName: nickName}, nil
+	}
+
+	var holding CategoryHolding
+	if err := unmarshalVersioned(categoryHoldingPrefix, holdingBytes, &holding); err != nil {
+		return nil, wrapError(err, "failed to unmarshal category holding")
+	}
+	return &holding, nil
+}
+
+// 보유 수와 순위 인덱스를 저장하는 도우미 함수, 0 이 되면 둘 다 지운다
+func putCategoryHolding(ctx contractapi.TransactionContextInterface, holding *CategoryHolding) error {
+	holdingKey, err := ctx.GetStub().CreateCompositeKey(categoryHoldingPrefix, []string{holding.CategoryCode, holding.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if holding.Count == 0 {
+		if err := ctx.GetStub().DelState(holdingKey); err != nil {
+			return wrapError(err, "failed to delete category holding")
+		}
+		return nil
+	}
+
+	holdingBytes, err := json.Marshal(holding)
+	if err != nil {
+		return wrapError(err, "failed to marshal category holding")
+	}
+	if err := ctx.GetStub().PutState(holdingKey, holdingBytes); err != nil {
+		return wrapError(err, "failed to put state for category holding")
+	}
+
+	rankKey, err := ctx.GetStub().CreateCompositeKey(categoryRankPrefix, []string{holding.CategoryCode, rankScore(holding.Count), holding.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(rankKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for category rank")
+	}
+	return nil
+}
+
+// 점수가 클수록 앞에 오도록 뒤집어 0 으로 채운 문자열을 반환하는 도우미 함수
+func rankScore(score int64) string {
+	return fmt.Sprintf("%019d", math.MaxInt64-score)
+}
+
+// rankScore 로 만든 문자열을 점수로 되돌리는 도우미 함수
+func parseRankScore(value string) (int64, error) {
+	inverted, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, newError(mymberr.Internal, params("score", value), "invalid rank score %s", value)
+	}
+	return math.MaxInt64 - inverted, nil
+}
+
+// 두 문자열 키를 앞에서부터 정렬해 반환하는 도우미 함수, 쓰기 순서를 피어마다 같게 한다
+func sortedKeyPairs(counts map[[2]string]int64) [][2]string {
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// 접두사로 시작하는 모든 복합키를 지우는 도우미 함수
+func deleteByPrefix(ctx contractapi.TransactionContextInterface, prefix string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, []string{})
+	if err != nil {
+		return wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	// 조회 중인 범위를 지우지 않도록 키를 먼저 모은다
+	keys := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return wrapError(err, "failed to get next query response")
+		}
+		keys = append(keys, queryResponse.Key)
+	}
+
+	for _, key := range keys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return wrapError(err, "failed to delete %s", key)
+		}
+	}
+	return nil
+}
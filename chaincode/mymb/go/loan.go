@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TokenLoan 토큰 대여 기록, 소유권은 Owner 에게 남고 Until 까지 Borrower 가 토큰을 사용한다
+type TokenLoan struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	TokenNumber   string    `json:"tokenNumber"`
+	Owner         string    `json:"owner"`
+	Borrower      string    `json:"borrower"`
+	LentTime      time.Time `json:"lentTime"`
+	Until         time.Time `json:"until"`
+	TxID          string    `json:"txID"`
+}
+
+const (
+	// loan~tokenNumber 에 대여 기록을, loanBorrower~borrower~tokenNumber 에 빌린 유저 인덱스를 저장한다
+	loanPrefix         = "loan"
+	loanBorrowerPrefix = "loanBorrower"
+)
+
+// LendToken 소유자가 토큰을 until(유닉스 시각, 초)까지 borrower 에게 빌려주는 함수
+// 대여 중인 토큰은 소유자도 전송하거나 경매에 올릴 수 없다
+func (c *TokenERC1155Contract) LendToken(ctx contractapi.TransactionContextInterface, tokenNumber string, borrower string, until int64) (*TokenLoan, error) {
+
+	err := newValidator().
+		field("tokenNumber", tokenNumber).
+		fieldAs("nickName", "borrower", borrower).
+		positive("until", until).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if token.Owner == borrower {
+		return nil, newError(mymberr.InvalidArgument, params("field", "borrower"), "owner %s cannot borrow own token %s", borrower, tokenNumber)
+	}
+
+	user, err := c.GetUser(ctx, borrower)
+	if err != nil {
+		return nil, wrapError(err, "failed to get borrower information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", borrower), "borrower %s does not exist", borrower)
+	}
+
+	if err := checkNotFrozen(ctx, token.Owner, borrower); err != nil {
+		return nil, err
+	}
+	if err := checkNotEnterprisePool(ctx, token.Owner); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	untilTime := time.Unix(until, 0).UTC()
+	if !untilTime.After(now) {
+		return nil, newError(mymberr.InvalidArgument, params("field", "until"), "loan end %s is not in the future", untilTime.Format(time.RFC3339))
+	}
+
+	// 대여는 전송이 아니므로 전송 정책 대신 경매, 공동 소유 풀, 대여 잠금만 확인한다
+	auctionID, err := getTokenAuctionID(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if auctionID != "" {
+		return nil, newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "auctionID", auctionID), "token %s is locked by auction %s", tokenNumber, auctionID)
+	}
+	poolID, err := getTokenFractionPoolID(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if poolID != "" {
+		return nil, newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "poolID", poolID), "token %s is locked in fraction pool %s", tokenNumber, poolID)
+	}
+	existing, err := getTokenLoan(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "borrower", existing.Borrower), "token %s is already lent to %s", tokenNumber, existing.Borrower)
+	}
+
+	if err := checkKYCRequirement(ctx, token, borrower, now); err != nil {
+		return nil, err
+	}
+
+	loan := TokenLoan{
+		SchemaVersion: tokenLoanSchemaVersion,
+		TokenNumber:   tokenNumber,
+		Owner:         token.Owner,
+		Borrower:      borrower,
+		LentTime:      now,
+		Until:         untilTime,
+		TxID:          ctx.GetStub().GetTxID(),
+	}
+
+	loanKey, err := ctx.GetStub().CreateCompositeKey(loanPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	loanBytes, err := json.Marshal(loan)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal token loan")
+	}
+	if err := ctx.GetStub().PutState(loanKey, loanBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for token loan")
+	}
+
+	borrowerKey, err := ctx.GetStub().CreateCompositeKey(loanBorrowerPrefix, []string{borrower, tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(borrowerKey, []byte{0x00}); err != nil {
+		return nil, wrapError(err, "failed to put state for loan index")
+	}
+
+	if err := ctx.GetStub().SetEvent("LendToken", loanBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &loan, nil
+}
+
+// ReclaimToken 대여 기간이 끝난 토큰을 소유자에게 돌려주는 함수, 소유자나 빌린 유저 누구나 호출할 수 있다
+func (c *TokenERC1155Contract) ReclaimToken(ctx contractapi.TransactionContextInterface, tokenNumber string, nickName string) (*TokenLoan, error) {
+
+	err := newValidator().
+		field("tokenNumber", tokenNumber).
+		field("nickName", nickName).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	loan, err := c.GetTokenLoan(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if nickName != loan.Owner && nickName != loan.Borrower {
+		return nil, newError(mymberr.Unauthorized, params("nickName", nickName, "tokenNumber", tokenNumber), "%s is neither the owner nor the borrower of token %s", nickName, tokenNumber)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(loan.Until) {
+		return nil, newError(mymberr.InvalidState, params("tokenNumber", tokenNumber, "until", loan.Until.Format(time.RFC3339)),
+			"token %s is lent until %s", tokenNumber, loan.Until.Format(time.RFC3339))
+	}
+
+	loanKey, err := ctx.GetStub().CreateCompositeKey(loanPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(loanKey); err != nil {
+		return nil, wrapError(err, "failed to delete token loan")
+	}
+	borrowerKey, err := ctx.GetStub().CreateCompositeKey(loanBorrowerPrefix, []string{loan.Borrower, tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(borrowerKey); err != nil {
+		return nil, wrapError(err, "failed to delete loan index")
+	}
+
+	loanBytes, err := json.Marshal(loan)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal token loan")
+	}
+	if err := ctx.GetStub().SetEvent("ReclaimToken", loanBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return loan, nil
+}
+
+// GetTokenLoan 토큰의 대여 기록을 조회하는 함수
+func (c *TokenERC1155Contract) GetTokenLoan(ctx contractapi.TransactionContextInterface, tokenNumber string) (*TokenLoan, error) {
+
+	loan, err := getTokenLoan(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	if loan == nil {
+		return nil, newError(mymberr.LoanNotFound, params("tokenNumber", tokenNumber), "token %s is not lent", tokenNumber)
+	}
+	return loan, nil
+}
+
+// GetTokenUser 지금 토큰을 사용할 수 있는 유저를 조회하는 함수, 대여 기간 중이면 빌린 유저, 아니면 소유자
+func (c *TokenERC1155Contract) GetTokenUser(ctx contractapi.TransactionContextInterface, tokenNumber string) (string, error) {
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return "", err
+	}
+
+	loan, err := getTokenLoan(ctx, tokenNumber)
+	if err != nil {
+		return "", err
+	}
+	if loan == nil {
+		return token.Owner, nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if now.Before(loan.Until) {
+		return loan.Borrower, nil
+	}
+	return token.Owner, nil
+}
+
+// GetBorrowedTokens 유저가 빌린 토큰들의 대여 기록을 조회하는 함수, 기간이 끝났지만 회수되지 않은 대여도 포함한다
+func (c *TokenERC1155Contract) GetBorrowedTokens(ctx contractapi.TransactionContextInterface, nickName string) ([]TokenLoan, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(loanBorrowerPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	loans := []TokenLoan{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		loan, err := c.GetTokenLoan(ctx, attributes[1])
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, *loan)
+	}
+
+	return loans, nil
+}
+
+// 토큰의 대여 기록을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getTokenLoan(ctx contractapi.TransactionContextInterface, tokenNumber string) (*TokenLoan, error) {
+	loanKey, err := ctx.GetStub().CreateCompositeKey(loanPrefix, []string{tokenNumber})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	loanBytes, err := ctx.GetStub().GetState(loanKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read token loan")
+	}
+	if loanBytes == nil {
+		return nil, nil
+	}
+
+	var loan TokenLoan
+	if err := unmarshalVersioned(loanPrefix, loanBytes, &loan); err != nil {
+		return nil, wrapError(err, "failed to unmarshal token loan")
+	}
+	return &loan, nil
+}
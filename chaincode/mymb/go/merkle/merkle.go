@@ -0,0 +1,146 @@
+// Package merkle mymb 체인코드의 소유권, 포인트 스냅샷에 쓰이는 머클 트리와 증명 검증 함수
+//
+// 체인에 접근하지 않고도 GetOwnershipProof, GetBalanceProof 로 받은 증명을
+// 스냅샷 루트와 비교해 검증할 수 있다. Fabric 패키지에 의존하지 않는다.
+//
+// 리프 해시는 sha256(0x00 || leaf), 내부 노드는 sha256(0x01 || left || right) 이며
+// 짝이 없는 마지막 노드는 그대로 다음 단계로 올라간다.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// ProofStep 증명의 한 단계, Left 가 true 이면 Hash 가 왼쪽 형제 노드
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// Tree 리프 목록으로 만든 머클 트리
+type Tree struct {
+	levels [][][]byte
+}
+
+// OwnershipLeaf 소유권 스냅샷의 (owner, tokenNumber) 리프를 인코딩한다
+func OwnershipLeaf(owner string, tokenNumber string) []byte {
+	return encodeLeaf("ownership", owner, tokenNumber)
+}
+
+// BalanceLeaf 포인트 스냅샷의 (nickName, mymPoint) 리프를 인코딩한다
+func BalanceLeaf(nickName string, mymPoint int64) []byte {
+	return encodeLeaf("balance", nickName, strconv.FormatInt(mymPoint, 10))
+}
+
+// 각 필드 앞에 길이를 붙여 필드 경계가 모호하지 않게 한다
+func encodeLeaf(fields ...string) []byte {
+	var buf bytes.Buffer
+	for _, field := range fields {
+		buf.WriteString(strconv.Itoa(len(field)))
+		buf.WriteByte(':')
+		buf.WriteString(field)
+	}
+	return buf.Bytes()
+}
+
+// HashLeaf 리프 데이터의 해시
+func HashLeaf(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func hashNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// New 리프 목록으로 트리를 만든다, 리프 순서가 같아야 같은 루트가 나온다
+func New(leaves [][]byte) *Tree {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = HashLeaf(leaf)
+	}
+
+	t := &Tree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashNode(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Root 루트 해시를 hex 로 반환한다, 리프가 없으면 빈 입력의 sha256
+func (t *Tree) Root() string {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+	return hex.EncodeToString(top[0])
+}
+
+// Proof index 번째 리프의 증명을 반환한다
+func (t *Tree) Proof(index int) ([]ProofStep, error) {
+	if index < 0 || index >= len(t.levels[0]) {
+		return nil, fmt.Errorf("leaf index %d out of range", index)
+	}
+
+	proof := []ProofStep{}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, ProofStep{Hash: hex.EncodeToString(level[sibling]), Left: sibling < index})
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// Verify 리프와 증명으로 계산한 루트가 root(hex) 와 같은지 확인한다
+func Verify(root string, leaf []byte, proof []ProofStep) (bool, error) {
+	hash := HashLeaf(leaf)
+	for i, step := range proof {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil || len(sibling) != sha256.Size {
+			return false, fmt.Errorf("proof step %d has an invalid hash %q", i, step.Hash)
+		}
+		if step.Left {
+			hash = hashNode(sibling, hash)
+		} else {
+			hash = hashNode(hash, sibling)
+		}
+	}
+	return hex.EncodeToString(hash) == root, nil
+}
+
+// VerifyOwnership owner 가 스냅샷 시점에 tokenNumber 를 가지고 있었는지 확인한다
+func VerifyOwnership(root string, owner string, tokenNumber string, proof []ProofStep) (bool, error) {
+	return Verify(root, OwnershipLeaf(owner, tokenNumber), proof)
+}
+
+// VerifyBalance nickName 의 스냅샷 시점 MymPoint 가 mymPoint 였는지 확인한다
+func VerifyBalance(root string, nickName string, mymPoint int64, proof []ProofStep) (bool, error) {
+	return Verify(root, BalanceLeaf(nickName, mymPoint), proof)
+}
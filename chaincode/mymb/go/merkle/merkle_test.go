@@ -0,0 +1,26 @@
+package merkle
+
+import "testing"
+
+func TestProofsVerifyForEveryLeaf(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = OwnershipLeaf("owner", string(rune('A'+i)))
+		}
+		tree := New(leaves)
+
+		for i := range leaves {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				t.Fatalf("Proof(%d) failed: %v", i, err)
+			}
+			if ok, err := VerifyOwnership(tree.Root(), "owner", string(rune('A'+i)), proof); err != nil || !ok {
+				t.Fatalf("size %d: proof for leaf %d did not verify (%v)", size, i, err)
+			}
+			if ok, _ := VerifyOwnership(tree.Root(), "other", string(rune('A'+i)), proof); ok {
+				t.Fatalf("size %d: proof for leaf %d verified a different owner", size, i)
+			}
+		}
+	}
+}
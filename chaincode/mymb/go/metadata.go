@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MetadataAttribute 토큰 메타데이터의 속성 하나
+type MetadataAttribute struct {
+	TraitType string `json:"traitType"`
+	Value     string `json:"value"`
+}
+
+// TokenMetadata tokenType 또는 funding 단위로 저장되는 토큰 메타데이터의 한 버전
+// ContentHash 는 name, description, image, imageHash, attributes 를 JSON 으로 직렬화한 값의 SHA-256 이다
+type TokenMetadata struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	Scope         string              `json:"scope"`
+	ScopeID       string              `json:"scopeID"`
+	Version       int                 `json:"version"`
+	Name          string              `json:"name"`
+	Description   string              `json:"description"`
+	Image         string              `json:"image"`
+	ImageHash     string              `json:"imageHash"`
+	Attributes    []MetadataAttribute `json:"attributes"`
+	ContentHash   string              `json:"contentHash"`
+	ClientID      string              `json:"clientID"`
+	UpdatedTime   time.Time           `json:"updatedTime"`
+}
+
+// MetadataURI tokenType 메타데이터 JSON 의 위치를 나타내는 ERC-1155 방식의 URI 템플릿
+type MetadataURI struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Template      string    `json:"template"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+const (
+	metadataPrefix        = "metadata"
+	metadataVersionPrefix = "metadataVersion"
+	metadataURIPrefix     = "metadataURI"
+
+	// URI 템플릿에서 tokenType 으로 바뀌는 자리
+	metadataURIIDPlaceholder = "{id}"
+
+	// 메타데이터를 수정할 수 있는 역할, 관리자도 수정할 수 있다
+	roleMetadataEditor = "metadataEditor"
+)
+
+// UpdateMetadata tokenType 또는 funding 의 메타데이터를 새 버전으로 저장하는 함수
+// imageHash 는 이미지 파일의 SHA-256 (hex) 이며, 이전 버전은 GetMetadataVersion 으로 계속 조회할 수 있다
+func (c *TokenERC1155Contract) UpdateMetadata(ctx contractapi.TransactionContextInterface, scope string, scopeID string,
+	name string, description string, image string, imageHash string, attributes []MetadataAttribute) (*TokenMetadata, error) {
+
+	v := newValidator().
+		oneOf("scope", scope, policyScopeTokenType, policyScopeFunding).
+		field("scopeID", scopeID).
+		field("name", name).
+		field("description", description).
+		fieldAs("imageURL", "image", image).
+		field("imageHash", imageHash)
+	for i, attribute := range attributes {
+		v.fieldAs("traitType", fmt.Sprintf("attributes[%d].traitType", i), attribute.TraitType).
+			fieldAs("traitValue", fmt.Sprintf("attributes[%d].value", i), attribute.Value)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleMetadataEditor, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	current, err := getMetadata(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if attributes == nil {
+		attributes = []MetadataAttribute{}
+	}
+
+	metadata := TokenMetadata{
+		SchemaVersion: metadataSchemaVersion,
+		Scope:         scope,
+		ScopeID:       scopeID,
+		Version:       1,
+		Name:          name,
+		Description:   description,
+		Image:         image,
+		ImageHash:     imageHash,
+		Attributes:    attributes,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+	if current != nil {
+		metadata.Version = current.Version + 1
+	}
+	metadata.ContentHash, err = metadataContentHash(&metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal token metadata")
+	}
+
+	metadataKey, err := ctx.GetStub().CreateCompositeKey(metadataPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	versionKey, err := ctx.GetStub().CreateCompositeKey(metadataVersionPrefix, []string{scope, scopeID, metadataVersionKey(metadata.Version)})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(metadataKey, metadataBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for token metadata")
+	}
+	if err := ctx.GetStub().PutState(versionKey, metadataBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for token metadata version")
+	}
+
+	if err := ctx.GetStub().SetEvent("UpdateMetadata", metadataBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &metadata, nil
+}
+
+// GetMetadata tokenType 또는 funding 의 최신 메타데이터를 조회하는 함수
+func (c *TokenERC1155Contract) GetMetadata(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (*TokenMetadata, error) {
+
+	metadata, err := getMetadata(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		return nil, newError(mymberr.MetadataNotFound, params("scope", scope, "scopeID", scopeID), "metadata for %s %s does not exist", scope, scopeID)
+	}
+	return metadata, nil
+}
+
+// GetMetadataVersion tokenType 또는 funding 메타데이터의 특정 버전을 조회하는 함수
+func (c *TokenERC1155Contract) GetMetadataVersion(ctx contractapi.TransactionContextInterface, scope string, scopeID string, version int) (*TokenMetadata, error) {
+
+	versionKey, err := ctx.GetStub().CreateCompositeKey(metadataVersionPrefix, []string{scope, scopeID, metadataVersionKey(version)})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	metadataBytes, err := ctx.GetStub().GetState(versionKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read token metadata version")
+	}
+	if metadataBytes == nil {
+		return nil, newError(mymberr.MetadataNotFound, params("scope", scope, "scopeID", scopeID, "version", strconv.Itoa(version)),
+			"metadata version %d for %s %s does not exist", version, scope, scopeID)
+	}
+
+	var metadata TokenMetadata
+	if err := unmarshalVersioned(metadataPrefix, metadataBytes, &metadata); err != nil {
+		return nil, wrapError(err, "failed to unmarshal token metadata")
+	}
+	return &metadata, nil
+}
+
+// GetMetadataHistory tokenType 또는 funding 메타데이터의 모든 버전을 오래된 순서로 조회하는 함수
+func (c *TokenERC1155Contract) GetMetadataHistory(ctx contractapi.TransactionContextInterface, scope string, scopeID string) ([]TokenMetadata, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(metadataVersionPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	history := []TokenMetadata{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var metadata TokenMetadata
+		if err := unmarshalVersioned(metadataPrefix, queryResponse.Value, &metadata); err != nil {
+			return nil, wrapError(err, "failed to unmarshal token metadata")
+		}
+		history = append(history, metadata)
+	}
+
+	return history, nil
+}
+
+// GetTokenMetadata 토큰에 적용되는 메타데이터를 조회하는 함수
+// funding 메타데이터가 있으면 그것을, 없으면 tokenType 메타데이터를 반환한다
+func (c *TokenERC1155Contract) GetTokenMetadata(ctx contractapi.TransactionContextInterface, tokenNumber string) (*TokenMetadata, error) {
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.FundingID != "" {
+		metadata, err := getMetadata(ctx, policyScopeFunding, token.FundingID)
+		if err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			return metadata, nil
+		}
+	}
+
+	return c.GetMetadata(ctx, policyScopeTokenType, token.TokenType)
+}
+
+// SetMetadataURI tokenType 메타데이터 JSON 의 URI 템플릿을 지정하는 함수, 템플릿에는 {id} 가 들어 있어야 한다
+func (c *TokenERC1155Contract) SetMetadataURI(ctx contractapi.TransactionContextInterface, template string) (*MetadataURI, error) {
+
+	err := newValidator().
+		field("uriTemplate", strings.Replace(template, metadataURIIDPlaceholder, "id", -1)).
+		check(strings.Contains(template, metadataURIIDPlaceholder), "uriTemplate", "placeholder", "must contain "+metadataURIIDPlaceholder).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleMetadataEditor, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := MetadataURI{
+		SchemaVersion: metadataURISchemaVersion,
+		Template:      template,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	uriKey, err := ctx.GetStub().CreateCompositeKey(metadataURIPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	uriBytes, err := json.Marshal(uri)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal metadata URI")
+	}
+	if err := ctx.GetStub().PutState(uriKey, uriBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for metadata URI")
+	}
+
+	return &uri, nil
+}
+
+// GetMetadataURI 지정된 URI 템플릿을 조회하는 함수
+func (c *TokenERC1155Contract) GetMetadataURI(ctx contractapi.TransactionContextInterface) (*MetadataURI, error) {
+
+	uriKey, err := ctx.GetStub().CreateCompositeKey(metadataURIPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	uriBytes, err := ctx.GetStub().GetState(uriKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read metadata URI")
+	}
+	if uriBytes == nil {
+		return nil, newError(mymberr.MetadataNotFound, nil, "metadata URI template is not set")
+	}
+
+	var uri MetadataURI
+	if err := unmarshalVersioned(metadataURIPrefix, uriBytes, &uri); err != nil {
+		return nil, wrapError(err, "failed to unmarshal metadata URI")
+	}
+	return &uri, nil
+}
+
+// URI tokenType 의 메타데이터 URI 를 반환하는 함수, 템플릿의 {id} 를 tokenType 으로 바꾼다
+func (c *TokenERC1155Contract) URI(ctx contractapi.TransactionContextInterface, tokenType string) (string, error) {
+
+	if err := newValidator().field("tokenType", tokenType).err(); err != nil {
+		return "", err
+	}
+
+	uri, err := c.GetMetadataURI(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(uri.Template, metadataURIIDPlaceholder, url.PathEscape(tokenType), -1), nil
+}
+
+// 최신 메타데이터를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getMetadata(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (*TokenMetadata, error) {
+	metadataKey, err := ctx.GetStub().CreateCompositeKey(metadataPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	metadataBytes, err := ctx.GetStub().GetState(metadataKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read token metadata")
+	}
+	if metadataBytes == nil {
+		return nil, nil
+	}
+
+	var metadata TokenMetadata
+	if err := unmarshalVersioned(metadataPrefix, metadataBytes, &metadata); err != nil {
+		return nil, wrapError(err, "failed to unmarshal token metadata")
+	}
+	return &metadata, nil
+}
+
+// 메타데이터 내용의 SHA-256 을 계산하는 도우미 함수
+// 버전, 수정자, 시각은 제외하므로 같은 내용이면 같은 해시가 나온다
+func metadataContentHash(metadata *TokenMetadata) (string, error) {
+	content := struct {
+		Name        string              `json:"name"`
+		Description string              `json:"description"`
+		Image       string              `json:"image"`
+		ImageHash   string              `json:"imageHash"`
+		Attributes  []MetadataAttribute `json:"attributes"`
+	}{metadata.Name, metadata.Description, metadata.Image, metadata.ImageHash, metadata.Attributes}
+
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		return "", wrapError(err, "failed to marshal metadata content")
+	}
+	hash := sha256.Sum256(contentBytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// 버전 키가 숫자 순서대로 정렬되도록 자리수를 맞추는 도우미 함수
+func metadataVersionKey(version int) string {
+	return fmt.Sprintf("%010d", version)
+}
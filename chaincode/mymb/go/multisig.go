@@ -0,0 +1,570 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MultisigPolicy 위험한 관리자 작업에 필요한 M-of-N 승인 정책
+// Signers 는 승인할 수 있는 관리자 클라이언트 ID, 유저가 하루 동안 승인 없이 받는 MymPoint 합이 LargePointGrant 에 이르는 적립도 승인이 필요하다 (0 이면 제한 없음)
+type MultisigPolicy struct {
+	SchemaVersion         int       `json:"schemaVersion"`
+	Signers               []string  `json:"signers"`
+	Threshold             int       `json:"threshold"`
+	ApprovalWindowSeconds int64     `json:"approvalWindowSeconds"`
+	LargePointGrant       int64     `json:"largePointGrant"`
+	UpdatedTime           time.Time `json:"updatedTime"`
+}
+
+// AdminProposal 승인을 기다리는 관리자 작업, Args 의 목록 인자는 JSON 배열 문자열
+// Threshold 는 마지막으로 승인할 때의 정책 값이며, 실행 여부는 그때의 정책으로 다시 센다
+type AdminProposal struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	ProposalID    string             `json:"proposalID"`
+	Operation     string             `json:"operation"`
+	Args          []string           `json:"args"`
+	Proposer      string             `json:"proposer"`
+	Approvals     []string           `json:"approvals"`
+	Threshold     int                `json:"threshold"`
+	CreatedTime   time.Time          `json:"createdTime"`
+	ExpiryTime    time.Time          `json:"expiryTime"`
+	Status        string             `json:"status"`
+	Log           []ProposalLogEntry `json:"log"`
+}
+
+// PointGrantWindow 유저 한 명이 승인 없이 받은 MymPoint 의 누적, LargePointGrant 를 여러 번으로 나누어 받는 것을 막는다
+type PointGrantWindow struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	WindowStart   time.Time `json:"windowStart"`
+	Granted       int64     `json:"granted"`
+}
+
+// ProposalLogEntry 제안, 승인, 실행, 취소 기록
+type ProposalLogEntry struct {
+	Action   string    `json:"action"`
+	ClientID string    `json:"clientID"`
+	Time     time.Time `json:"time"`
+	TxID     string    `json:"txID"`
+}
+
+const (
+	multisigPolicyPrefix   = "multisigPolicy"
+	proposalPrefix         = "adminProposal"
+	pointGrantWindowPrefix = "pointGrantWindow"
+
+	// 승인 없이 받은 MymPoint 를 합산하는 기간, 이 기간에 받은 합이 LargePointGrant 에 이르면 승인이 필요하다
+	largePointGrantWindow = 24 * time.Hour
+
+	proposalPending   = "pending"
+	proposalExecuted  = "executed"
+	proposalCancelled = "cancelled"
+
+	// 승인이 필요한 작업, Args 순서
+	opDeleteAllUserBlocks = "DeleteAllUserBlocks" // 없음
+	opDeleteAllTokens     = "DeleteAllTokens"     // nickName
+	opPause               = "Pause"               // reason, allowedFunctions
+	opUpdateMymPoint      = "UpdateMymPoint"      // nickName, delta
+	opSetMultisigPolicy   = "SetMultisigPolicy"   // signers, threshold, approvalWindowSeconds, largePointGrant
+)
+
+var multisigOperations = []string{opDeleteAllUserBlocks, opDeleteAllTokens, opPause, opUpdateMymPoint, opSetMultisigPolicy}
+
+// SetMultisigPolicy 승인 정책을 처음 설정하는 함수, 정책이 생긴 뒤에는 이 함수도 ProposeOperation 으로만 바꿀 수 있다
+func (c *TokenERC1155Contract) SetMultisigPolicy(ctx contractapi.TransactionContextInterface, signers []string, threshold int, approvalWindowSeconds int64, largePointGrant int64) (*MultisigPolicy, error) {
+
+	if err := c.requireMultisig(ctx, opSetMultisigPolicy); err != nil {
+		return nil, err
+	}
+	return setMultisigPolicy(ctx, signers, threshold, approvalWindowSeconds, largePointGrant)
+}
+
+// GetMultisigPolicy 승인 정책을 조회하는 함수, 설정되지 않았으면 Threshold 가 0 인 정책을 반환
+func (c *TokenERC1155Contract) GetMultisigPolicy(ctx contractapi.TransactionContextInterface) (*MultisigPolicy, error) {
+	policy, err := getMultisigPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return &MultisigPolicy{SchemaVersion: multisigPolicySchemaVersion, Signers: []string{}}, nil
+	}
+	return policy, nil
+}
+
+// ProposeOperation 승인이 필요한 작업을 제안하는 함수, 제안한 관리자의 승인은 바로 기록된다
+func (c *TokenERC1155Contract) ProposeOperation(ctx contractapi.TransactionContextInterface, proposalID string, operation string, args []string) (*AdminProposal, error) {
+
+	err := newValidator().
+		field("proposalID", proposalID).
+		oneOf("operation", operation, multisigOperations...).
+		err()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.prepareOperation(operation, args); err != nil {
+		return nil, err
+	}
+
+	policy, clientID, err := assertSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.ProposalExists, params("proposalID", proposalID), "proposal %s already exists", proposalID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if args == nil {
+		args = []string{}
+	}
+	proposal := AdminProposal{
+		SchemaVersion: adminProposalSchemaVersion,
+		ProposalID:    proposalID,
+		Operation:     operation,
+		Args:          args,
+		Proposer:      clientID,
+		Approvals:     []string{clientID},
+		Threshold:     policy.Threshold,
+		CreatedTime:   now,
+		ExpiryTime:    now.Add(time.Duration(policy.ApprovalWindowSeconds) * time.Second),
+		Status:        proposalPending,
+		Log:           []ProposalLogEntry{},
+	}
+	proposal.Log = append(proposal.Log, newProposalLogEntry(ctx, "propose", clientID, now))
+
+	if err := putProposal(ctx, &proposal, "ProposeOperation"); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// ApproveOperation 제안된 작업을 승인하는 함수, 승인 수가 정책의 Threshold 에 이르면 같은 트랜잭션에서 작업을 실행한다
+// 제안 뒤 정책이 바뀌었을 수 있으므로 지금 정책의 Signers 에 남은 관리자의 승인만 세어 지금의 Threshold 와 비교한다
+// 실행이 실패하면 승인도 기록되지 않으므로 원인을 해결한 뒤 다시 승인하면 된다
+func (c *TokenERC1155Contract) ApproveOperation(ctx contractapi.TransactionContextInterface, proposalID string) (*AdminProposal, error) {
+
+	if err := newValidator().field("proposalID", proposalID).err(); err != nil {
+		return nil, err
+	}
+
+	policy, clientID, err := assertSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := c.GetProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != proposalPending {
+		return nil, newError(mymberr.InvalidState, params("proposalID", proposalID, "status", proposal.Status), "proposal %s is %s", proposalID, proposal.Status)
+	}
+	if contains(proposal.Approvals, clientID) {
+		return nil, newError(mymberr.InvalidState, params("proposalID", proposalID, "clientID", clientID), "proposal %s is already approved by %s", proposalID, clientID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.After(proposal.ExpiryTime) {
+		return nil, newError(mymberr.InvalidState, params("proposalID", proposalID, "expiryTime", proposal.ExpiryTime.Format(time.RFC3339)),
+			"approval window of proposal %s closed at %s", proposalID, proposal.ExpiryTime.Format(time.RFC3339))
+	}
+
+	proposal.Approvals = append(proposal.Approvals, clientID)
+	proposal.Log = append(proposal.Log, newProposalLogEntry(ctx, "approve", clientID, now))
+
+	approvals := 0
+	for _, approver := range proposal.Approvals {
+		if contains(policy.Signers, approver) {
+			approvals++
+		}
+	}
+	proposal.Threshold = policy.Threshold
+
+	eventName := "ApproveOperation"
+	if approvals >= policy.Threshold {
+		execute, err := c.prepareOperation(proposal.Operation, proposal.Args)
+		if err != nil {
+			return nil, err
+		}
+		if err := execute(ctx); err != nil {
+			return nil, wrapError(err, "failed to execute proposal %s", proposalID)
+		}
+		proposal.Status = proposalExecuted
+		proposal.Log = append(proposal.Log, newProposalLogEntry(ctx, "execute", clientID, now))
+		eventName = "ExecuteOperation"
+	}
+
+	if err := putProposal(ctx, proposal, eventName); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// CancelProposal 제안한 관리자가 실행 전의 제안을 취소하는 함수
+func (c *TokenERC1155Contract) CancelProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*AdminProposal, error) {
+
+	if err := newValidator().field("proposalID", proposalID).err(); err != nil {
+		return nil, err
+	}
+
+	_, clientID, err := assertSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := c.GetProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Proposer != clientID {
+		return nil, newError(mymberr.Unauthorized, params("proposalID", proposalID, "clientID", clientID), "only the proposer can cancel proposal %s", proposalID)
+	}
+	if proposal.Status != proposalPending {
+		return nil, newError(mymberr.InvalidState, params("proposalID", proposalID, "status", proposal.Status), "proposal %s is %s", proposalID, proposal.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proposal.Status = proposalCancelled
+	proposal.Log = append(proposal.Log, newProposalLogEntry(ctx, "cancel", clientID, now))
+
+	if err := putProposal(ctx, proposal, "CancelProposal"); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// GetProposal 관리자 작업 제안을 조회하는 함수
+func (c *TokenERC1155Contract) GetProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*AdminProposal, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal == nil {
+		return nil, newError(mymberr.ProposalNotFound, params("proposalID", proposalID), "proposal %s does not exist", proposalID)
+	}
+	return proposal, nil
+}
+
+// 위험한 작업을 직접 호출할 수 있는지 확인하는 도우미 함수
+// 승인 정책이 없으면 관리자만 호출할 수 있고, 정책이 생기면 ProposeOperation 으로만 실행할 수 있다
+func (c *TokenERC1155Contract) requireMultisig(ctx contractapi.TransactionContextInterface, operation string) error {
+	policy, err := getMultisigPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return assertRole(ctx, roleAdmin)
+	}
+	return newError(mymberr.ApprovalRequired, params("operation", operation, "threshold", strconv.Itoa(policy.Threshold)),
+		"%s requires %d of %d admin approvals, submit it with ProposeOperation", operation, policy.Threshold, len(policy.Signers))
+}
+
+// 유저가 largePointGrantWindow 안에 승인 없이 받는 MymPoint 합이 LargePointGrant 에 이르는 지급을 막는 도우미 함수
+// 아무것도 쓰지 않으며, 지급한 뒤 putPointGrantWindow 로 저장할 누적 값을 반환한다 (제한이 없으면 nil)
+func checkLargePointGrant(ctx contractapi.TransactionContextInterface, nickName string, delta int64) (*PointGrantWindow, error) {
+	policy, err := getMultisigPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil || policy.LargePointGrant == 0 || delta <= 0 {
+		return nil, nil
+	}
+	if delta >= policy.LargePointGrant {
+		return nil, newError(mymberr.ApprovalRequired, params("operation", opUpdateMymPoint, "largePointGrant", strconv.FormatInt(policy.LargePointGrant, 10)),
+			"granting %d MymPoint requires %d of %d admin approvals, submit it with ProposeOperation", delta, policy.Threshold, len(policy.Signers))
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	window, err := getPointGrantWindow(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if window == nil || !now.Before(window.WindowStart.Add(largePointGrantWindow)) {
+		window = &PointGrantWindow{SchemaVersion: pointGrantWindowSchemaVersion, NickName: nickName, WindowStart: now}
+	}
+	// delta 는 LargePointGrant 보다 작고 Granted 도 그보다 작게 유지되므로 더해도 넘치지 않는다
+	if window.Granted+delta >= policy.LargePointGrant {
+		return nil, newError(mymberr.ApprovalRequired, params("operation", opUpdateMymPoint, "nickName", nickName, "largePointGrant", strconv.FormatInt(policy.LargePointGrant, 10)),
+			"%s already received %d MymPoint since %s, granting %d more requires %d of %d admin approvals, submit it with ProposeOperation",
+			nickName, window.Granted, window.WindowStart.Format(time.RFC3339), delta, policy.Threshold, len(policy.Signers))
+	}
+	window.Granted += delta
+	return window, nil
+}
+
+// 제안의 인자를 검사하고 실행할 함수를 만드는 도우미 함수, 제안할 때 미리 검사해 실행 시점의 인자 오류를 막는다
+func (c *TokenERC1155Contract) prepareOperation(operation string, args []string) (func(contractapi.TransactionContextInterface) error, error) {
+
+	argCounts := map[string]int{opDeleteAllUserBlocks: 0, opDeleteAllTokens: 1, opPause: 2, opUpdateMymPoint: 2, opSetMultisigPolicy: 4}
+	if len(args) != argCounts[operation] {
+		return nil, newError(mymberr.InvalidArgument, params("field", "args"), "%s takes %d arguments, got %d", operation, argCounts[operation], len(args))
+	}
+
+	switch operation {
+	case opDeleteAllUserBlocks:
+		return c.deleteAllUserBlocks, nil
+
+	case opDeleteAllTokens:
+		if err := newValidator().fieldAs("nickName", "args[0]", args[0]).err(); err != nil {
+			return nil, err
+		}
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return c.deleteAllTokens(ctx, args[0])
+		}, nil
+
+	case opPause:
+		var allowedFunctions []string
+		if err := json.Unmarshal([]byte(args[1]), &allowedFunctions); err != nil {
+			return nil, newError(mymberr.InvalidArgument, params("field", "args[1]"), "allowedFunctions must be a JSON array: %v", err)
+		}
+		err := newValidator().
+			fieldAs("reason", "args[0]", args[0]).
+			list("function", "args[1]", allowedFunctions).
+			err()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.pause(ctx, args[0], allowedFunctions)
+			return err
+		}, nil
+
+	case opUpdateMymPoint:
+		delta, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, newError(mymberr.InvalidArgument, params("field", "args[1]"), "delta must be an integer")
+		}
+		if err := newValidator().fieldAs("nickName", "args[0]", args[0]).err(); err != nil {
+			return nil, err
+		}
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return c.updateMymPoint(ctx, args[0], delta)
+		}, nil
+
+	case opSetMultisigPolicy:
+		var signers []string
+		if err := json.Unmarshal([]byte(args[0]), &signers); err != nil {
+			return nil, newError(mymberr.InvalidArgument, params("field", "args[0]"), "signers must be a JSON array: %v", err)
+		}
+		numbers := make([]int64, 3)
+		for i := range numbers {
+			value, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, newError(mymberr.InvalidArgument, params("field", "args["+strconv.Itoa(i+1)+"]"), "must be an integer")
+			}
+			numbers[i] = value
+		}
+		if err := validateMultisigPolicy(signers, int(numbers[0]), numbers[1], numbers[2]); err != nil {
+			return nil, err
+		}
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := setMultisigPolicy(ctx, signers, int(numbers[0]), numbers[1], numbers[2])
+			return err
+		}, nil
+	}
+
+	return nil, newError(mymberr.InvalidArgument, params("field", "operation"), "unsupported operation %s", operation)
+}
+
+// 요청한 클라이언트가 정책의 서명자인 관리자인지 확인하는 도우미 함수
+func assertSigner(ctx contractapi.TransactionContextInterface) (*MultisigPolicy, string, error) {
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, "", err
+	}
+	policy, err := getMultisigPolicy(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if policy == nil {
+		return nil, "", newError(mymberr.InvalidState, nil, "multisig policy is not set")
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if !contains(policy.Signers, clientID) {
+		return nil, "", newError(mymberr.Unauthorized, params("clientID", clientID), "%s is not a multisig signer", clientID)
+	}
+	return policy, clientID, nil
+}
+
+// 승인 정책 값을 검사하는 도우미 함수, 서명자는 서로 달라야 하고 한 명의 승인만으로 실행되지 않도록 Threshold 는 2 이상
+func validateMultisigPolicy(signers []string, threshold int, approvalWindowSeconds int64, largePointGrant int64) error {
+	distinct := map[string]bool{}
+	for _, signer := range signers {
+		distinct[signer] = true
+	}
+	return newValidator().
+		check(len(signers) > 0 && len(distinct) == len(signers), "signers", "distinct", "must be a non-empty list of distinct client IDs").
+		check(threshold >= 2 && threshold <= len(signers), "threshold", "range", "must be between 2 and the number of signers").
+		positive("approvalWindowSeconds", approvalWindowSeconds).
+		nonNegative("largePointGrant", largePointGrant).
+		err()
+}
+
+// 승인 정책을 저장하는 도우미 함수
+func setMultisigPolicy(ctx contractapi.TransactionContextInterface, signers []string, threshold int, approvalWindowSeconds int64, largePointGrant int64) (*MultisigPolicy, error) {
+
+	if err := validateMultisigPolicy(signers, threshold, approvalWindowSeconds, largePointGrant); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := MultisigPolicy{
+		SchemaVersion:         multisigPolicySchemaVersion,
+		Signers:               signers,
+		Threshold:             threshold,
+		ApprovalWindowSeconds: approvalWindowSeconds,
+		LargePointGrant:       largePointGrant,
+		UpdatedTime:           now,
+	}
+
+	policyKey, err := ctx.GetStub().CreateCompositeKey(multisigPolicyPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	policyBytes, err := json.Marshal(policy)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal multisig policy")
+	}
+	if err := ctx.GetStub().PutState(policyKey, policyBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for multisig policy")
+	}
+	if err := ctx.GetStub().SetEvent("SetMultisigPolicy", policyBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+	return &policy, nil
+}
+
+// 승인 정책을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getMultisigPolicy(ctx contractapi.TransactionContextInterface) (*MultisigPolicy, error) {
+	policyKey, err := ctx.GetStub().CreateCompositeKey(multisigPolicyPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	policyBytes, err := ctx.GetStub().GetState(policyKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read multisig policy")
+	}
+	if policyBytes == nil {
+		return nil, nil
+	}
+
+	var policy MultisigPolicy
+	if err := unmarshalVersioned(multisigPolicyPrefix, policyBytes, &policy); err != nil {
+		return nil, wrapError(err, "failed to unmarshal multisig policy")
+	}
+	return &policy, nil
+}
+
+// 관리자 작업 제안을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*AdminProposal, error) {
+	proposalKey, err := ctx.GetStub().CreateCompositeKey(proposalPrefix, []string{proposalID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	proposalBytes, err := ctx.GetStub().GetState(proposalKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read proposal")
+	}
+	if proposalBytes == nil {
+		return nil, nil
+	}
+
+	var proposal AdminProposal
+	if err := unmarshalVersioned(proposalPrefix, proposalBytes, &proposal); err != nil {
+		return nil, wrapError(err, "failed to unmarshal proposal")
+	}
+	return &proposal, nil
+}
+
+// 관리자 작업 제안을 저장하고 이벤트를 남기는 도우미 함수
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *AdminProposal, eventName string) error {
+	proposalKey, err := ctx.GetStub().CreateCompositeKey(proposalPrefix, []string{proposal.ProposalID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	proposalBytes, err := json.Marshal(proposal)
+	if err != nil {
+		return wrapError(err, "failed to marshal proposal")
+	}
+	if err := ctx.GetStub().PutState(proposalKey, proposalBytes); err != nil {
+		return wrapError(err, "failed to put state for proposal")
+	}
+	// 실행된 작업이 남긴 이벤트보다 제안 기록이 우선한다
+	if err := ctx.GetStub().SetEvent(eventName, proposalBytes); err != nil {
+		return wrapError(err, "failed to set event")
+	}
+	return nil
+}
+
+// 제안 기록 한 줄을 만드는 도우미 함수
+func newProposalLogEntry(ctx contractapi.TransactionContextInterface, action string, clientID string, now time.Time) ProposalLogEntry {
+	return ProposalLogEntry{Action: action, ClientID: clientID, Time: now, TxID: ctx.GetStub().GetTxID()}
+}
+
+// 승인 없이 받은 MymPoint 누적을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getPointGrantWindow(ctx contractapi.TransactionContextInterface, nickName string) (*PointGrantWindow, error) {
+	windowKey, err := ctx.GetStub().CreateCompositeKey(pointGrantWindowPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	windowBytes, err := ctx.GetStub().GetState(windowKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read point grant window")
+	}
+	if windowBytes == nil {
+		return nil, nil
+	}
+
+	var window PointGrantWindow
+	if err := unmarshalVersioned(pointGrantWindowPrefix, windowBytes, &window); err != nil {
+		return nil, wrapError(err, "failed to unmarshal point grant window")
+	}
+	return &window, nil
+}
+
+// checkLargePointGrant 가 반환한 누적을 저장하는 도우미 함수, nil 이면 아무것도 하지 않는다
+func putPointGrantWindow(ctx contractapi.TransactionContextInterface, window *PointGrantWindow) error {
+	if window == nil {
+		return nil
+	}
+	windowKey, err := ctx.GetStub().CreateCompositeKey(pointGrantWindowPrefix, []string{window.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	windowBytes, err := json.Marshal(window)
+	if err != nil {
+		return wrapError(err, "failed to marshal point grant window")
+	}
+	if err := ctx.GetStub().PutState(windowKey, windowBytes); err != nil {
+		return wrapError(err, "failed to put state for point grant window")
+	}
+	return nil
+}
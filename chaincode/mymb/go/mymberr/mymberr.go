@@ -0,0 +1,142 @@
+// Package mymberr mymb 체인코드가 반환하는 구조화된 에러를 정의하고,
+// 클라이언트(백엔드)에서 에러 메시지를 다시 디코딩할 때 사용한다.
+//
+// 체인코드의 에러 메시지는 다음과 같은 JSON 으로 직렬화된다.
+//
+//	{"code":"USER_NOT_FOUND","message":"user alice does not exist","params":{"nickName":"alice"}}
+package mymberr
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Code 에러 종류를 나타내는 기계 판독용 코드
+type Code string
+
+const (
+	InvalidArgument           Code = "INVALID_ARGUMENT"
+	Unauthorized              Code = "UNAUTHORIZED"
+	Paused                    Code = "PAUSED"
+	UserNotFound              Code = "USER_NOT_FOUND"
+	UserAlreadyExists         Code = "USER_ALREADY_EXISTS"
+	UserFrozen                Code = "USER_FROZEN"
+	TokenNotFound             Code = "TOKEN_NOT_FOUND"
+	TokenAlreadyExists        Code = "TOKEN_ALREADY_EXISTS"
+	TokenLocked               Code = "TOKEN_LOCKED"
+	TransferRestricted        Code = "TRANSFER_RESTRICTED"
+	NotOwner                  Code = "NOT_OWNER"
+	InsufficientPoints        Code = "INSUFFICIENT_POINTS"
+	AuctionNotFound           Code = "AUCTION_NOT_FOUND"
+	AuctionExists             Code = "AUCTION_ALREADY_EXISTS"
+	BidNotFound               Code = "BID_NOT_FOUND"
+	BidMismatch               Code = "BID_MISMATCH"
+	PolicyNotFound            Code = "POLICY_NOT_FOUND"
+	MetadataNotFound          Code = "METADATA_NOT_FOUND"
+	CategoryNotFound          Code = "CATEGORY_NOT_FOUND"
+	TokenTypeNotFound         Code = "TOKEN_TYPE_NOT_FOUND"
+	CampaignNotFound          Code = "CAMPAIGN_NOT_FOUND"
+	SnapshotNotFound          Code = "SNAPSHOT_NOT_FOUND"
+	SnapshotExists            Code = "SNAPSHOT_ALREADY_EXISTS"
+	SnapshotLeafNotFound      Code = "SNAPSHOT_LEAF_NOT_FOUND"
+	ReferralNotFound          Code = "REFERRAL_NOT_FOUND"
+	ReferralExists            Code = "REFERRAL_ALREADY_EXISTS"
+	InviterNotFound           Code = "INVITER_NOT_FOUND"
+	PaymentExists             Code = "PAYMENT_ALREADY_EXISTS"
+	NotTrusted                Code = "NOT_TRUSTED"
+	KYCNotFound               Code = "KYC_NOT_FOUND"
+	KYCRequired               Code = "KYC_REQUIRED"
+	PIINotFound               Code = "PII_NOT_FOUND"
+	EnterpriseNotFound        Code = "ENTERPRISE_NOT_FOUND"
+	EnterpriseExists          Code = "ENTERPRISE_ALREADY_EXISTS"
+	EnterpriseMemberNotFound  Code = "ENTERPRISE_MEMBER_NOT_FOUND"
+	SpendingLimitExceeded     Code = "SPENDING_LIMIT_EXCEEDED"
+	LoanNotFound              Code = "LOAN_NOT_FOUND"
+	FractionPoolNotFound      Code = "FRACTION_POOL_NOT_FOUND"
+	FractionPoolExists        Code = "FRACTION_POOL_ALREADY_EXISTS"
+	InsufficientShares        Code = "INSUFFICIENT_SHARES"
+	BuyoutOfferNotFound       Code = "BUYOUT_OFFER_NOT_FOUND"
+	ProposalNotFound          Code = "PROPOSAL_NOT_FOUND"
+	ProposalExists            Code = "PROPOSAL_ALREADY_EXISTS"
+	ApprovalRequired          Code = "APPROVAL_REQUIRED"
+	SettlementNotFound        Code = "SETTLEMENT_NOT_FOUND"
+	SettlementExists          Code = "SETTLEMENT_ALREADY_EXISTS"
+	SettlementAccountNotFound Code = "SETTLEMENT_ACCOUNT_NOT_FOUND"
+	InsufficientAllowance     Code = "INSUFFICIENT_ALLOWANCE"
+	CrossChaincodeFailed      Code = "CROSS_CHAINCODE_FAILED"
+	BridgeRequestNotFound     Code = "BRIDGE_REQUEST_NOT_FOUND"
+	BridgeRequestConflict     Code = "BRIDGE_REQUEST_CONFLICT"
+	InvalidState              Code = "INVALID_STATE"
+	UnsupportedSchema         Code = "UNSUPPORTED_SCHEMA"
+	Internal                  Code = "INTERNAL"
+)
+
+// FieldError 인자 하나에 대한 검증 오류 (INVALID_ARGUMENT 에 포함)
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error 체인코드가 반환하는 구조화된 에러
+type Error struct {
+	Code    Code              `json:"code"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"params,omitempty"`
+	Fields  []FieldError      `json:"fields,omitempty"`
+}
+
+// New 코드와 메시지, 파라미터로 에러를 만든다
+func New(code Code, message string, params map[string]string) *Error {
+	return &Error{Code: code, Message: message, Params: params}
+}
+
+// Error 에러를 JSON 문자열로 직렬화한다
+func (e *Error) Error() string {
+	errJSON, err := json.Marshal(e)
+	if err != nil {
+		return string(e.Code) + ": " + e.Message
+	}
+	return string(errJSON)
+}
+
+// Retryable 같은 요청을 다시 보내면 성공할 수 있는 에러인지 반환한다
+func (e *Error) Retryable() bool {
+	return e.Code == Internal || e.Code == Paused
+}
+
+// Parse 에러 메시지에서 구조화된 에러를 찾아 디코딩한다.
+// 피어나 게이트웨이가 메시지를 감싸거나 따옴표를 이스케이프한 경우에도 찾는다.
+func Parse(message string) (*Error, bool) {
+	for _, candidate := range []string{message, strings.ReplaceAll(message, `\"`, `"`)} {
+		idx := strings.Index(candidate, `{"code":`)
+		if idx < 0 {
+			continue
+		}
+		var e Error
+		if err := json.NewDecoder(strings.NewReader(candidate[idx:])).Decode(&e); err != nil || e.Code == "" {
+			continue
+		}
+		return &e, true
+	}
+	return nil, false
+}
+
+// FromError err 가 구조화된 에러이거나 그 메시지를 담고 있으면 디코딩해서 반환한다
+func FromError(err error) (*Error, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return Parse(err.Error())
+}
+
+// Is err 가 주어진 코드의 구조화된 에러인지 확인한다
+func Is(err error, code Code) bool {
+	e, ok := FromError(err)
+	return ok && e.Code == code
+}
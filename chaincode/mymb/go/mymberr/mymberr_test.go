@@ -0,0 +1,30 @@
+package mymberr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseWrappedMessage(t *testing.T) {
+	original := New(UserNotFound, "user alice does not exist", map[string]string{"nickName": "alice"})
+
+	escaped := strings.ReplaceAll(original.Error(), `"`, `\"`)
+	for _, message := range []string{
+		original.Error(),
+		"endorsement failure during invoke. response: status:500 message:" + original.Error(),
+		`rpc error: code = Aborted desc = "message:\"` + escaped + `\""`,
+	} {
+		decoded, ok := Parse(message)
+		if !ok || decoded.Code != UserNotFound || decoded.Params["nickName"] != "alice" {
+			t.Fatalf("Parse(%q) = %+v, %v", message, decoded, ok)
+		}
+	}
+
+	if _, ok := Parse("some unrelated failure"); ok {
+		t.Fatalf("expected plain messages not to decode")
+	}
+	if !Is(errors.New("wrapped: "+New(Paused, "paused", nil).Error()), Paused) {
+		t.Fatalf("expected Is to match the code in a wrapped error")
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PauseState 컨트랙트 일시 정지 상태
+type PauseState struct {
+	SchemaVersion    int       `json:"schemaVersion"`
+	Paused           bool      `json:"paused"`
+	Reason           string    `json:"reason"`
+	AllowedFunctions []string  `json:"allowedFunctions"`
+	ClientID         string    `json:"clientID"`
+	UpdatedTime      time.Time `json:"updatedTime"`
+}
+
+const pauseStatePrefix = "pauseState"
+
+// 조회 전용 함수 목록, 일시 정지 중에도 항상 호출할 수 있다
+var evaluateTransactions = []string{
+	"GetToken",
+	"GetAllTokens",
+	"GetTotalTokens",
+	"GetUserOwnedTokens",
+	"GetUser",
+	"GetAllUsers",
+	"GetTotalUsers",
+	"QueryAuction",
+	"QueryBid",
+	"GetTransferPolicy",
+	"GetAllTransferPolicies",
+	"GetAccountStatus",
+	"GetFrozenUsers",
+	"GetPauseState",
+	"GetMigrationProgress",
+	"GetMetadata",
+	"GetMetadataVersion",
+	"GetMetadataHistory",
+	"GetTokenMetadata",
+	"GetMetadataURI",
+	"URI",
+	"GetCategory",
+	"GetAllCategories",
+	"GetTokenType",
+	"GetAllTokenTypes",
+	"GetAirdropCampaign",
+	"GetAirdropRecipients",
+	"GetSnapshot",
+	"GetAllSnapshots",
+	"GetOwnershipProof",
+	"GetBalanceProof",
+	"GetReferral",
+	"GetReferralsByUser",
+	"GetReferralTotals",
+	"GetOwedReferralTotals",
+	"GetInviter",
+	"GetInvitees",
+	"GetReferralRates",
+	"GetReferralBalance",
+	"GetTrustedUsers",
+	"GetTrustedByUsers",
+	"GetMutualTrusts",
+	"IsTrusted",
+	"IsMutualTrust",
+	"GetKYC",
+	"GetKYCLevel",
+	"GetKYCRequirement",
+	"GetUserPII",
+	"VerifyUserPIIHash",
+	"GetEnterprise",
+	"GetEnterpriseMember",
+	"GetEnterpriseMembers",
+	"GetUserEnterprise",
+	"GetEnterpriseHoldings",
+	"GetTokenLoan",
+	"GetTokenUser",
+	"GetBorrowedTokens",
+	"GetFractionPool",
+	"GetFractionShares",
+	"GetUserFractionShares",
+	"GetBuyoutOffer",
+	"TopUsersByPoints",
+	"TopHoldersByCategory",
+	"GetCategoryHolding",
+	"GetTotalPoints",
+	"GetTokenCountByFunding",
+	"GetTokenCountByCategory",
+	"GetTokenCountBySellStage",
+	"GetAggregateStats",
+	"GetMultisigPolicy",
+	"GetProposal",
+	"GetTokenEndorsement",
+	"GetUserEndorsement",
+	"GetFundingEndorsement",
+	"GetERC20Config",
+	"GetSettlementAccount",
+	"GetSettlement",
+	"GetBridgeConfig",
+	"GetBridgeRequest",
+	"GetBridgeEscrow",
+	"ReconcileBridge",
+}
+
+// 일시 정지 중에도 관리자가 호출할 수 있어야 하는 함수 목록
+var pauseControlTransactions = []string{
+	"Pause",
+	"Unpause",
+	"SetPauseAllowList",
+}
+
+// GetEvaluateTransactions 조회 전용 함수들을 evaluate 트랜잭션으로 표시한다
+func (c *TokenERC1155Contract) GetEvaluateTransactions() []string {
+	return evaluateTransactions
+}
+
+// Pause 컨트랙트의 모든 쓰기 함수를 정지하는 함수, allowedFunctions 에 있는 함수는 계속 호출할 수 있다
+// 승인 정책이 있으면 ProposeOperation 으로만 실행할 수 있다
+func (c *TokenERC1155Contract) Pause(ctx contractapi.TransactionContextInterface, reason string, allowedFunctions []string) (*PauseState, error) {
+	if err := c.requireMultisig(ctx, opPause); err != nil {
+		return nil, err
+	}
+	return c.pause(ctx, reason, allowedFunctions)
+}
+
+// 컨트랙트를 정지하는 도우미 함수, 승인된 제안도 이 함수로 실행한다
+func (c *TokenERC1155Contract) pause(ctx contractapi.TransactionContextInterface, reason string, allowedFunctions []string) (*PauseState, error) {
+
+	err := newValidator().
+		field("reason", reason).
+		list("function", "allowedFunctions", allowedFunctions).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := getPauseState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state.Paused {
+		return nil, newError(mymberr.InvalidState, nil, "contract is already paused")
+	}
+
+	return updatePauseState(ctx, true, reason, allowedFunctions, "Pause")
+}
+
+// Unpause 컨트랙트 일시 정지를 해제하는 함수
+func (c *TokenERC1155Contract) Unpause(ctx contractapi.TransactionContextInterface, reason string) (*PauseState, error) {
+
+	if err := newValidator().field("reason", reason).err(); err != nil {
+		return nil, err
+	}
+
+	state, err := getPauseState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !state.Paused {
+		return nil, newError(mymberr.InvalidState, nil, "contract is not paused")
+	}
+
+	return updatePauseState(ctx, false, reason, []string{}, "Unpause")
+}
+
+// SetPauseAllowList 일시 정지 중에 호출할 수 있는 함수 목록을 바꾸는 함수
+func (c *TokenERC1155Contract) SetPauseAllowList(ctx contractapi.TransactionContextInterface, allowedFunctions []string) (*PauseState, error) {
+
+	if err := newValidator().list("function", "allowedFunctions", allowedFunctions).err(); err != nil {
+		return nil, err
+	}
+
+	state, err := getPauseState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !state.Paused {
+		return nil, newError(mymberr.InvalidState, nil, "contract is not paused")
+	}
+
+	return updatePauseState(ctx, true, state.Reason, allowedFunctions, "PauseAllowListUpdated")
+}
+
+// GetPauseState 컨트랙트 일시 정지 상태를 조회하는 함수
+func (c *TokenERC1155Contract) GetPauseState(ctx contractapi.TransactionContextInterface) (*PauseState, error) {
+	return getPauseState(ctx)
+}
+
+// 모든 트랜잭션 전에 호출되는 BeforeTransaction 훅
+// 일시 정지 중이면 조회 함수, 정지 제어 함수, 허용 목록에 있는 함수 외의 호출을 거부한다
+func (c *TokenERC1155Contract) checkPaused(ctx contractapi.TransactionContextInterface) error {
+
+	fcn, _ := ctx.GetStub().GetFunctionAndParameters()
+	if idx := strings.LastIndex(fcn, ":"); idx >= 0 {
+		fcn = fcn[idx+1:]
+	}
+
+	if contains(evaluateTransactions, fcn) || contains(pauseControlTransactions, fcn) {
+		return nil
+	}
+
+	state, err := getPauseState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.Paused && !contains(state.AllowedFunctions, fcn) {
+		return newError(mymberr.Paused, params("function", fcn, "reason", state.Reason), "contract is paused, %s is not allowed: %s", fcn, state.Reason)
+	}
+	return nil
+}
+
+// 일시 정지 상태를 조회하는 도우미 함수, 기록이 없으면 정지되지 않은 상태를 반환
+func getPauseState(ctx contractapi.TransactionContextInterface) (*PauseState, error) {
+	stateKey, err := ctx.GetStub().CreateCompositeKey(pauseStatePrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	stateBytes, err := ctx.GetStub().GetState(stateKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read pause state")
+	}
+	if stateBytes == nil {
+		return &PauseState{SchemaVersion: pauseStateSchemaVersion, AllowedFunctions: []string{}}, nil
+	}
+
+	var state PauseState
+	if err := unmarshalVersioned(pauseStatePrefix, stateBytes, &state); err != nil {
+		return nil, wrapError(err, "failed to unmarshal pause state")
+	}
+	if state.AllowedFunctions == nil {
+		state.AllowedFunctions = []string{}
+	}
+	return &state, nil
+}
+
+// 일시 정지 상태를 저장하고 이벤트를 남기는 도우미 함수
+func updatePauseState(ctx contractapi.TransactionContextInterface, paused bool, reason string, allowedFunctions []string, eventName string) (*PauseState, error) {
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowedFunctions == nil {
+		allowedFunctions = []string{}
+	}
+
+	state := PauseState{
+		SchemaVersion:    pauseStateSchemaVersion,
+		Paused:           paused,
+		Reason:           reason,
+		AllowedFunctions: allowedFunctions,
+		ClientID:         clientID,
+		UpdatedTime:      now,
+	}
+
+	stateKey, err := ctx.GetStub().CreateCompositeKey(pauseStatePrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal pause state")
+	}
+	if err := ctx.GetStub().PutState(stateKey, stateBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for pause state")
+	}
+
+	if err := ctx.GetStub().SetEvent(eventName, stateBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &state, nil
+}
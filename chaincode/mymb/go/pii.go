@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// UserPII 정산에 필요한 유저의 개인 정보, 공개 상태가 아닌 piiCollection 에만 저장한다
+// salt 는 공개되는 해시로 개인 정보를 추측하지 못하게 클라이언트가 만들어 넣는 임의 값
+type UserPII struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	RealName      string    `json:"realName"`
+	PhoneNumber   string    `json:"phoneNumber"`
+	BankCode      string    `json:"bankCode"`
+	BankAccount   string    `json:"bankAccount"`
+	Salt          string    `json:"salt"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// UserPIIHashCheck 공개 유저 블록의 해시와 컬렉션에 저장된 개인 정보 해시를 비교한 결과
+type UserPIIHashCheck struct {
+	NickName    string `json:"nickName"`
+	PublicHash  string `json:"publicHash"`
+	PrivateHash string `json:"privateHash"`
+	Matches     bool   `json:"matches"`
+}
+
+const (
+	// collections_config.json 에 정의된 개인 정보 컬렉션, blockToLive 가 100000 이라 기록된 값과 그 이력은
+	// 100000 블록 뒤에 모든 피어에서 만료되어 지워진다
+	piiCollection = "mymbUserPII"
+	piiPrefix     = "pii"
+
+	// transient 로 개인 정보를 넘길 때 쓰는 키
+	piiTransientKey = "pii"
+
+	// 개인 정보를 기록, 조회, 삭제할 수 있는 역할
+	rolePII = "pii"
+)
+
+// SetUserPII 유저의 개인 정보를 transient 의 "pii" 로 받아 컬렉션에 저장하고 공개 유저 블록에 해시를 기록하는 함수
+// 컬렉션의 값은 blockToLive 블록 뒤에 만료되므로 계속 필요한 개인 정보는 만료 전에 다시 기록해야 한다
+func (c *TokenERC1155Contract) SetUserPII(ctx contractapi.TransactionContextInterface, nickName string) (*User, error) {
+
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, rolePII, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, wrapError(err, "error getting transient")
+	}
+	piiJSON, ok := transientMap[piiTransientKey]
+	if !ok {
+		return nil, newError(mymberr.InvalidArgument, params("field", piiTransientKey), "pii key not found in the transient map")
+	}
+
+	var pii UserPII
+	if err := json.Unmarshal(piiJSON, &pii); err != nil {
+		return nil, newError(mymberr.InvalidArgument, params("field", piiTransientKey), "failed to unmarshal pii: %v", err)
+	}
+
+	err = newValidator().
+		field("realName", pii.RealName).
+		field("phoneNumber", pii.PhoneNumber).
+		field("bankCode", pii.BankCode).
+		field("bankAccount", pii.BankAccount).
+		field("salt", pii.Salt).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	// 개인 정보는 컬렉션 멤버 조직의 피어에만 저장할 수 있다
+	if err := verifyClientOrgMatchesPeerOrg(ctx); err != nil {
+		return nil, wrapError(err, "cannot store pii on this peer")
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pii.SchemaVersion = userPIISchemaVersion
+	pii.NickName = nickName
+	pii.UpdatedTime = now
+
+	piiBytes, err := json.Marshal(pii)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal pii")
+	}
+
+	piiKey, err := ctx.GetStub().CreateCompositeKey(piiPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutPrivateData(piiCollection, piiKey, piiBytes); err != nil {
+		return nil, wrapError(err, "failed to put pii into collection")
+	}
+
+	// 같은 트랜잭션에서는 GetPrivateDataHash 로 방금 쓴 값을 읽을 수 없으므로 직접 계산한다
+	// 피어가 저장하는 해시와 같은 값이라 GetPrivateDataHash 로 누구나 대조할 수 있다
+	user.PIIHash = fmt.Sprintf("%x", sha256.Sum256(piiBytes))
+	if err := putUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserPII 컬렉션에 저장된 유저의 개인 정보를 조회하는 함수
+func (c *TokenERC1155Contract) GetUserPII(ctx contractapi.TransactionContextInterface, nickName string) (*UserPII, error) {
+
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, rolePII, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := verifyClientOrgMatchesPeerOrg(ctx); err != nil {
+		return nil, wrapError(err, "cannot read pii on this peer")
+	}
+
+	piiKey, err := ctx.GetStub().CreateCompositeKey(piiPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	piiBytes, err := ctx.GetStub().GetPrivateData(piiCollection, piiKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read pii from collection")
+	}
+	if piiBytes == nil {
+		return nil, newError(mymberr.PIINotFound, params("nickName", nickName), "pii of %s does not exist", nickName)
+	}
+
+	var pii UserPII
+	if err := unmarshalVersioned(piiPrefix, piiBytes, &pii); err != nil {
+		return nil, wrapError(err, "failed to unmarshal pii")
+	}
+	return &pii, nil
+}
+
+// VerifyUserPIIHash 공개 유저 블록의 해시가 컬렉션에 저장된 개인 정보와 일치하는지 확인하는 함수
+// 개인 정보 해시는 채널의 모든 피어가 가지고 있으므로 컬렉션 멤버가 아니어도 호출할 수 있다
+func (c *TokenERC1155Contract) VerifyUserPIIHash(ctx contractapi.TransactionContextInterface, nickName string) (*UserPIIHashCheck, error) {
+
+	if err := newValidator().field("nickName", nickName).err(); err != nil {
+		return nil, err
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	piiKey, err := ctx.GetStub().CreateCompositeKey(piiPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	privateHash, err := ctx.GetStub().GetPrivateDataHash(piiCollection, piiKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read pii hash from collection")
+	}
+
+	check := UserPIIHashCheck{NickName: nickName, PublicHash: user.PIIHash}
+	if privateHash != nil {
+		check.PrivateHash = fmt.Sprintf("%x", privateHash)
+	}
+	check.Matches = check.PublicHash == check.PrivateHash
+	return &check, nil
+}
+
+// PurgeUserPII 개인 정보 삭제 요청(GDPR 등)에 따라 컬렉션의 개인 정보와 공개 해시를 지우는 함수
+// 유저 블록이 이미 삭제되었어도 컬렉션의 개인 정보는 지울 수 있다
+// 현재 값은 바로 지워지지만 과거 값은 컬렉션 멤버 피어의 사설 데이터 이력에 남았다가 기록된 지 blockToLive(100000) 블록 뒤에 만료된다
+func (c *TokenERC1155Contract) PurgeUserPII(ctx contractapi.TransactionContextInterface, nickName string, reason string) error {
+
+	err := newValidator().
+		field("nickName", nickName).
+		field("reason", reason).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if err := assertAnyRole(ctx, rolePII, roleAdmin); err != nil {
+		return err
+	}
+
+	if err := verifyClientOrgMatchesPeerOrg(ctx); err != nil {
+		return wrapError(err, "cannot purge pii on this peer")
+	}
+
+	piiKey, err := ctx.GetStub().CreateCompositeKey(piiPrefix, []string{nickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	privateHash, err := ctx.GetStub().GetPrivateDataHash(piiCollection, piiKey)
+	if err != nil {
+		return wrapError(err, "failed to read pii hash from collection")
+	}
+	if privateHash == nil {
+		return newError(mymberr.PIINotFound, params("nickName", nickName), "pii of %s does not exist", nickName)
+	}
+
+	if err := ctx.GetStub().DelPrivateData(piiCollection, piiKey); err != nil {
+		return wrapError(err, "failed to delete pii from collection")
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return wrapError(err, "failed to get user information")
+	}
+	if user.UserId != "" {
+		user.PIIHash = ""
+		if err := putUser(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	// 이벤트에는 개인 정보 없이 삭제 사실만 남긴다
+	eventBytes, err := json.Marshal(map[string]string{"nickName": nickName, "reason": reason, "txID": ctx.GetStub().GetTxID()})
+	if err != nil {
+		return wrapError(err, "failed to marshal purge event")
+	}
+	if err := ctx.GetStub().SetEvent("PurgeUserPII", eventBytes); err != nil {
+		return wrapError(err, "failed to set event")
+	}
+
+	return nil
+}
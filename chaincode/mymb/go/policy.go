@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransferPolicy tokenType 또는 fundingID 단위로 적용되는 토큰 전송 정책
+type TransferPolicy struct {
+	SchemaVersion       int    `json:"schemaVersion"`
+	Scope               string `json:"scope"`
+	ScopeID             string `json:"scopeID"`
+	NonTransferable     bool   `json:"nonTransferable"`
+	LockedUntil         int64  `json:"lockedUntil"`
+	LockDurationSeconds int64  `json:"lockDurationSeconds"`
+	MaxTransfers        int    `json:"maxTransfers"`
+}
+
+const (
+	transferPolicyPrefix = "transferPolicy"
+
+	policyScopeTokenType = "tokenType"
+	policyScopeFunding   = "funding"
+)
+
+// SetTransferPolicy tokenType 또는 funding 에 전송 정책을 지정하는 함수
+// lockedUntil 은 전송이 풀리는 유닉스 시각(초), lockDurationSeconds 는 토큰을 받은 뒤 전송할 수 없는 기간(초),
+// maxTransfers 는 토큰 하나가 전송될 수 있는 최대 횟수이며 각각 0 이면 제한하지 않는다
+func (c *TokenERC1155Contract) SetTransferPolicy(ctx contractapi.TransactionContextInterface, scope string, scopeID string,
+	nonTransferable bool, lockedUntil int64, lockDurationSeconds int64, maxTransfers int) (*TransferPolicy, error) {
+
+	err := newValidator().
+		oneOf("scope", scope, policyScopeTokenType, policyScopeFunding).
+		field("scopeID", scopeID).
+		nonNegative("lockedUntil", lockedUntil).
+		nonNegative("lockDurationSeconds", lockDurationSeconds).
+		nonNegative("maxTransfers", int64(maxTransfers)).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	policy := TransferPolicy{
+		SchemaVersion:       transferPolicySchemaVersion,
+		Scope:               scope,
+		ScopeID:             scopeID,
+		NonTransferable:     nonTransferable,
+		LockedUntil:         lockedUntil,
+		LockDurationSeconds: lockDurationSeconds,
+		MaxTransfers:        maxTransfers,
+	}
+
+	policyKey, err := ctx.GetStub().CreateCompositeKey(transferPolicyPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	policyBytes, err := json.Marshal(policy)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal transfer policy")
+	}
+	if err := ctx.GetStub().PutState(policyKey, policyBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for transfer policy")
+	}
+
+	return &policy, nil
+}
+
+// GetTransferPolicy tokenType 또는 funding 에 지정된 전송 정책을 조회하는 함수
+func (c *TokenERC1155Contract) GetTransferPolicy(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (*TransferPolicy, error) {
+
+	policy, err := getTransferPolicy(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, newError(mymberr.PolicyNotFound, params("scope", scope, "scopeID", scopeID), "transfer policy for %s %s does not exist", scope, scopeID)
+	}
+	return policy, nil
+}
+
+// GetAllTransferPolicies 모든 전송 정책을 조회하는 함수
+func (c *TokenERC1155Contract) GetAllTransferPolicies(ctx contractapi.TransactionContextInterface) ([]TransferPolicy, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transferPolicyPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	policies := []TransferPolicy{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var policy TransferPolicy
+		if err := unmarshalVersioned(transferPolicyPrefix, queryResponse.Value, &policy); err != nil {
+			return nil, wrapError(err, "failed to unmarshal transfer policy")
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// DeleteTransferPolicy tokenType 또는 funding 의 전송 정책을 삭제하는 함수
+func (c *TokenERC1155Contract) DeleteTransferPolicy(ctx contractapi.TransactionContextInterface, scope string, scopeID string) error {
+
+	err := newValidator().
+		oneOf("scope", scope, policyScopeTokenType, policyScopeFunding).
+		field("scopeID", scopeID).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return err
+	}
+
+	policyKey, err := ctx.GetStub().CreateCompositeKey(transferPolicyPrefix, []string{scope, scopeID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	policyBytes, err := ctx.GetStub().GetState(policyKey)
+	if err != nil {
+		return wrapError(err, "failed to read transfer policy")
+	}
+	if policyBytes == nil {
+		return newError(mymberr.PolicyNotFound, params("scope", scope, "scopeID", scopeID), "transfer policy for %s %s does not exist", scope, scopeID)
+	}
+	if err := ctx.GetStub().DelState(policyKey); err != nil {
+		return wrapError(err, "failed to delete transfer policy")
+	}
+	return nil
+}
+
+// 전송 정책을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getTransferPolicy(ctx contractapi.TransactionContextInterface, scope string, scopeID string) (*TransferPolicy, error) {
+	policyKey, err := ctx.GetStub().CreateCompositeKey(transferPolicyPrefix, []string{scope, scopeID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	policyBytes, err := ctx.GetStub().GetState(policyKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read transfer policy")
+	}
+	if policyBytes == nil {
+		return nil, nil
+	}
+
+	var policy TransferPolicy
+	if err := unmarshalVersioned(transferPolicyPrefix, policyBytes, &policy); err != nil {
+		return nil, wrapError(err, "failed to unmarshal transfer policy")
+	}
+	return &policy, nil
+}
+
+// 토큰을 지금 전송할 수 있는지 확인하는 도우미 함수
+// 경매, 대여, 공동 소유 풀에 잠겨 있지 않고 tokenType, funding 정책을 모두 만족해야 한다
+func checkTokenTransferable(ctx contractapi.TransactionContextInterface, token *Token1155, now time.Time) error {
+	if err := checkTokenUnlocked(ctx, token.TokenNumber); err != nil {
+		return err
+	}
+	return checkTransferPolicies(ctx, token, now)
+}
+
+// 토큰이 경매, 대여, 공동 소유 풀에 잠겨 있지 않은지 확인하는 도우미 함수
+func checkTokenUnlocked(ctx contractapi.TransactionContextInterface, tokenNumber string) error {
+
+	auctionID, err := getTokenAuctionID(ctx, tokenNumber)
+	if err != nil {
+		return err
+	}
+	if auctionID != "" {
+		return newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "auctionID", auctionID), "token %s is locked by auction %s", tokenNumber, auctionID)
+	}
+
+	// 대여 기간이 끝나도 ReclaimToken 으로 회수하기 전까지는 잠겨 있다
+	loan, err := getTokenLoan(ctx, tokenNumber)
+	if err != nil {
+		return err
+	}
+	if loan != nil {
+		return newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "borrower", loan.Borrower), "token %s is lent to %s", tokenNumber, loan.Borrower)
+	}
+
+	poolID, err := getTokenFractionPoolID(ctx, tokenNumber)
+	if err != nil {
+		return err
+	}
+	if poolID != "" {
+		return newError(mymberr.TokenLocked, params("tokenNumber", tokenNumber, "poolID", poolID), "token %s is locked in fraction pool %s", tokenNumber, poolID)
+	}
+	return nil
+}
+
+// 토큰에 적용되는 tokenType, funding 전송 정책을 확인하는 도우미 함수
+func checkTransferPolicies(ctx contractapi.TransactionContextInterface, token *Token1155, now time.Time) error {
+
+	scopes := [][2]string{
+		{policyScopeTokenType, token.TokenType},
+		{policyScopeFunding, token.FundingID},
+	}
+	for _, scope := range scopes {
+		if scope[1] == "" {
+			continue
+		}
+		policy, err := getTransferPolicy(ctx, scope[0], scope[1])
+		if err != nil {
+			return err
+		}
+		if policy == nil {
+			continue
+		}
+
+		if policy.NonTransferable {
+			return newError(mymberr.TransferRestricted, policyParams(token, policy, "nonTransferable"), "token %s is non-transferable (%s %s)", token.TokenNumber, policy.Scope, policy.ScopeID)
+		}
+		if policy.LockedUntil > 0 && now.Unix() < policy.LockedUntil {
+			return newError(mymberr.TransferRestricted, policyParams(token, policy, "lockedUntil"), "token %s is locked until %s (%s %s)", token.TokenNumber,
+				time.Unix(policy.LockedUntil, 0).UTC().Format(time.RFC3339), policy.Scope, policy.ScopeID)
+		}
+		if policy.LockDurationSeconds > 0 {
+			unlockTime := tokenAcquiredTime(token).Add(time.Duration(policy.LockDurationSeconds) * time.Second)
+			if now.Before(unlockTime) {
+				return newError(mymberr.TransferRestricted, policyParams(token, policy, "lockDuration"), "token %s cannot be transferred until %s (%s %s)", token.TokenNumber,
+					unlockTime.UTC().Format(time.RFC3339), policy.Scope, policy.ScopeID)
+			}
+		}
+		if policy.MaxTransfers > 0 && token.TransferCount >= policy.MaxTransfers {
+			return newError(mymberr.TransferRestricted, policyParams(token, policy, "maxTransfers"), "token %s reached the maximum of %d transfers (%s %s)", token.TokenNumber,
+				policy.MaxTransfers, policy.Scope, policy.ScopeID)
+		}
+	}
+
+	return nil
+}
+
+// 현재 소유자가 토큰을 받은 시각 (전송된 적이 없으면 발행 시각)
+func tokenAcquiredTime(token *Token1155) time.Time {
+	if !token.LastTransferTime.IsZero() {
+		return token.LastTransferTime
+	}
+	return token.TokenCreatedTime
+}
+
+// 토큰의 소유자를 바꾸고 전송 횟수와 시각을 기록하는 도우미 함수
+func recordTokenTransfer(token *Token1155, to string, now time.Time) {
+	token.Owner = to
+	token.TransferCount++
+	token.LastTransferTime = now
+}
+
+// 전송 제한 에러에 담을 파라미터를 만드는 도우미 함수
+func policyParams(token *Token1155, policy *TransferPolicy, rule string) map[string]string {
+	return params("tokenNumber", token.TokenNumber, "scope", policy.Scope, "scopeID", policy.ScopeID, "rule", rule)
+}
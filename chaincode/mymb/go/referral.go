@@ -0,0 +1,801 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FundingReferral 펀딩 결제 한 건에 대한 추천 페이백 기록 (web/server.go 의 FundingReferral 과 같은 필드)
+// Level 은 RecordFundingPayment 가 계산한 추천 단계(1 이 직접 초대한 유저)이며, RecordReferral 로 직접 기록하면 0 이다
+type FundingReferral struct {
+	SchemaVersion          int       `json:"schemaVersion"`
+	PayID                  string    `json:"payId"`
+	ReferralFrom           string    `json:"referralFrom"`
+	ReferralTo             string    `json:"referralTo"`
+	ReferralPayback        int64     `json:"referralPayback"`
+	Level                  int       `json:"level"`
+	IsBasePaymentCompleted bool      `json:"isBasePaymentCompleted"`
+	IsPaybacked            bool      `json:"isPaybacked"`
+	PayoutReference        string    `json:"payoutReference"`
+	RecordedTxID           string    `json:"recordedTxID"`
+	RecordedTime           time.Time `json:"recordedTime"`
+	PaidTxID               string    `json:"paidTxID"`
+	PaidTime               time.Time `json:"paidTime"`
+}
+
+// ReferralTotals 유저 한 명이 받을 추천 페이백 합계
+// Owed 는 기본 결제가 끝나 지급해야 하는 금액, Pending 은 기본 결제가 끝나지 않은 금액, Paid 는 지급된 금액
+type ReferralTotals struct {
+	NickName string `json:"nickName"`
+	Owed     int64  `json:"owed"`
+	Pending  int64  `json:"pending"`
+	Paid     int64  `json:"paid"`
+	Count    int    `json:"count"`
+}
+
+const (
+	referralPrefix   = "referral"
+	referralToPrefix = "referralTo"
+
+	// 한 번에 정산할 수 있는 최대 추천 기록 수
+	maxReferralSettleBatch = 500
+
+	// 추천 기록을 쓰고 정산할 수 있는 역할, 관리자도 할 수 있다
+	roleReferral = "referral"
+)
+
+// RecordReferral 펀딩 결제의 추천 페이백을 기록하는 함수, payId 마다 한 번만 기록할 수 있다
+// "-L<단계>" 로 끝나는 payId 는 RecordFundingPayment 가 만드는 페이백에 쓰이므로 받지 않는다
+func (c *TokenERC1155Contract) RecordReferral(ctx contractapi.TransactionContextInterface, payID string, referralFrom string, referralTo string,
+	referralPayback int64, isBasePaymentCompleted bool) (*FundingReferral, error) {
+
+	err := newValidator().
+		field("payID", payID).
+		check(!levelPayIDPattern.MatchString(payID), "payID", "reserved", "must not end with -L<level>, reserved for generated paybacks").
+		fieldAs("nickName", "referralFrom", referralFrom).
+		fieldAs("nickName", "referralTo", referralTo).
+		check(referralFrom != referralTo, "referralTo", "distinct", "must differ from referralFrom").
+		positive("referralPayback", referralPayback).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleReferral, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	referral := FundingReferral{
+		SchemaVersion:          fundingReferralSchemaVersion,
+		PayID:                  payID,
+		ReferralFrom:           referralFrom,
+		ReferralTo:             referralTo,
+		ReferralPayback:        referralPayback,
+		IsBasePaymentCompleted: isBasePaymentCompleted,
+		RecordedTxID:           ctx.GetStub().GetTxID(),
+		RecordedTime:           now,
+	}
+	if err := insertReferral(ctx, &referral); err != nil {
+		return nil, err
+	}
+
+	return &referral, nil
+}
+
+// SetBasePaymentCompleted 추천 기록의 기본 결제 완료 여부를 바꾸는 함수, 지급된 기록은 바꿀 수 없다
+func (c *TokenERC1155Contract) SetBasePaymentCompleted(ctx contractapi.TransactionContextInterface, payID string, completed bool) (*FundingReferral, error) {
+
+	if err := newValidator().field("payID", payID).err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleReferral, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	referral, err := c.GetReferral(ctx, payID)
+	if err != nil {
+		return nil, err
+	}
+	if referral.IsPaybacked {
+		return nil, newError(mymberr.InvalidState, params("payId", payID), "referral %s is already paid", payID)
+	}
+
+	referral.IsBasePaymentCompleted = completed
+	if err := putReferral(ctx, referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// SettleReferrals 추천 기록들을 지급 완료로 표시하는 함수, payoutReference 는 송금 내역 등 외부 지급 참조값
+// 하나라도 지급할 수 없는 기록이 있으면 전체를 취소한다
+func (c *TokenERC1155Contract) SettleReferrals(ctx contractapi.TransactionContextInterface, payIDs []string, payoutReference string) ([]FundingReferral, error) {
+
+	err := newValidator().
+		check(len(payIDs) > 0, "payIDs", "required", "must not be empty").
+		check(len(payIDs) <= maxReferralSettleBatch, "payIDs", "maxItems", fmt.Sprintf("must contain at most %d referrals", maxReferralSettleBatch)).
+		list("payID", "payIDs", payIDs).
+		field("payoutReference", payoutReference).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleReferral, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settled := make([]FundingReferral, 0, len(payIDs))
+	for _, payID := range payIDs {
+		referral, err := c.GetReferral(ctx, payID)
+		if err != nil {
+			return nil, err
+		}
+		if referral.IsPaybacked {
+			return nil, newError(mymberr.InvalidState, params("payId", payID), "referral %s is already paid", payID)
+		}
+		if !referral.IsBasePaymentCompleted {
+			return nil, newError(mymberr.InvalidState, params("payId", payID), "base payment of referral %s is not completed", payID)
+		}
+		settled = append(settled, *referral)
+	}
+
+	// 자동 계산된 페이백은 대기 잔액에서 지급 잔액으로 옮긴다, 같은 유저의 잔액은 한 번만 읽고 쓴다
+	paidByUser := map[string]int64{}
+	for i := range settled {
+		settled[i].IsPaybacked = true
+		settled[i].PayoutReference = payoutReference
+		settled[i].PaidTxID = ctx.GetStub().GetTxID()
+		settled[i].PaidTime = now
+		if err := putReferral(ctx, &settled[i]); err != nil {
+			return nil, err
+		}
+		if settled[i].Level > 0 {
+			paidByUser[settled[i].ReferralTo] += settled[i].ReferralPayback
+		}
+	}
+	for nickName, paid := range paidByUser {
+		balance, err := getReferralBalance(ctx, nickName)
+		if err != nil {
+			return nil, err
+		}
+		balance.Pending -= paid
+		balance.Paid += paid
+		balance.UpdatedTime = now
+		if err := putReferralBalance(ctx, balance); err != nil {
+			return nil, err
+		}
+	}
+
+	settledBytes, err := json.Marshal(settled)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal settled referrals")
+	}
+	if err := ctx.GetStub().SetEvent("SettleReferrals", settledBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return settled, nil
+}
+
+// GetReferral 추천 기록을 조회하는 함수
+func (c *TokenERC1155Contract) GetReferral(ctx contractapi.TransactionContextInterface, payID string) (*FundingReferral, error) {
+
+	referralKey, err := ctx.GetStub().CreateCompositeKey(referralPrefix, []string{payID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	referralBytes, err := ctx.GetStub().GetState(referralKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read referral")
+	}
+	if referralBytes == nil {
+		return nil, newError(mymberr.ReferralNotFound, params("payId", payID), "referral %s does not exist", payID)
+	}
+
+	var referral FundingReferral
+	if err := unmarshalVersioned(referralPrefix, referralBytes, &referral); err != nil {
+		return nil, wrapError(err, "failed to unmarshal referral")
+	}
+	return &referral, nil
+}
+
+// GetReferralsByUser 유저가 받을 추천 기록들을 조회하는 함수
+func (c *TokenERC1155Contract) GetReferralsByUser(ctx contractapi.TransactionContextInterface, nickName string) ([]FundingReferral, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referralToPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	referrals := []FundingReferral{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		referral, err := c.GetReferral(ctx, attributes[1])
+		if err != nil {
+			return nil, err
+		}
+		referrals = append(referrals, *referral)
+	}
+
+	return referrals, nil
+}
+
+// GetReferralTotals 유저가 받을 추천 페이백 합계를 조회하는 함수
+func (c *TokenERC1155Contract) GetReferralTotals(ctx contractapi.TransactionContextInterface, nickName string) (*ReferralTotals, error) {
+
+	referrals, err := c.GetReferralsByUser(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := ReferralTotals{NickName: nickName}
+	for _, referral := range referrals {
+		addReferralTotals(&totals, &referral)
+	}
+	return &totals, nil
+}
+
+// GetOwedReferralTotals 지급해야 할 추천 페이백이 있는 유저별 합계를 조회하는 함수
+func (c *TokenERC1155Contract) GetOwedReferralTotals(ctx contractapi.TransactionContextInterface) ([]ReferralTotals, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referralPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	byUser := map[string]*ReferralTotals{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var referral FundingReferral
+		if err := unmarshalVersioned(referralPrefix, queryResponse.Value, &referral); err != nil {
+			return nil, wrapError(err, "failed to unmarshal referral")
+		}
+		totals, ok := byUser[referral.ReferralTo]
+		if !ok {
+			totals = &ReferralTotals{NickName: referral.ReferralTo}
+			byUser[referral.ReferralTo] = totals
+		}
+		addReferralTotals(totals, &referral)
+	}
+
+	owed := []ReferralTotals{}
+	for _, totals := range byUser {
+		if totals.Owed > 0 {
+			owed = append(owed, *totals)
+		}
+	}
+	sort.Slice(owed, func(i, j int) bool { return owed[i].NickName < owed[j].NickName })
+
+	return owed, nil
+}
+
+// 추천 기록 하나를 합계에 더하는 도우미 함수
+func addReferralTotals(totals *ReferralTotals, referral *FundingReferral) {
+	totals.Count++
+	switch {
+	case referral.IsPaybacked:
+		totals.Paid += referral.ReferralPayback
+	case referral.IsBasePaymentCompleted:
+		totals.Owed += referral.ReferralPayback
+	default:
+		totals.Pending += referral.ReferralPayback
+	}
+}
+
+// 새 추천 기록과 받는 유저 인덱스를 저장하는 도우미 함수, 같은 payId 가 있으면 에러를 반환
+func insertReferral(ctx contractapi.TransactionContextInterface, referral *FundingReferral) error {
+	referralKey, err := ctx.GetStub().CreateCompositeKey(referralPrefix, []string{referral.PayID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	existing, err := ctx.GetStub().GetState(referralKey)
+	if err != nil {
+		return wrapError(err, "failed to read referral")
+	}
+	if existing != nil {
+		return newError(mymberr.ReferralExists, params("payId", referral.PayID), "referral %s already exists", referral.PayID)
+	}
+
+	if err := putReferral(ctx, referral); err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(referralToPrefix, []string{referral.ReferralTo, referral.PayID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return wrapError(err, "failed to put state for referral index")
+	}
+	return nil
+}
+
+func putReferral(ctx contractapi.TransactionContextInterface, referral *FundingReferral) error {
+	referralKey, err := ctx.GetStub().CreateCompositeKey(referralPrefix, []string{referral.PayID})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	referralBytes, err := json.Marshal(referral)
+	if err != nil {
+		return wrapError(err, "failed to marshal referral")
+	}
+	if err := ctx.GetStub().PutState(referralKey, referralBytes); err != nil {
+		return wrapError(err, "failed to put state for referral")
+	}
+	return nil
+}
+
+// Inviter 유저를 초대한 유저, 한 번 지정하면 바꿀 수 없다
+type Inviter struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	Inviter       string    `json:"inviter"`
+	TxID          string    `json:"txID"`
+	SetTime       time.Time `json:"setTime"`
+}
+
+// ReferralRates 추천 단계별 페이백 비율 (basis point, 10000 = 100%)
+// RatesBps[0] 은 직접 초대한 유저, RatesBps[1] 은 그 유저를 초대한 유저에게 적용된다
+type ReferralRates struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	RatesBps      []int64   `json:"ratesBps"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// ReferralBalance 자동 계산된 추천 페이백 중 지급 대기 금액과 지급된 금액
+type ReferralBalance struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	Pending       int64     `json:"pending"`
+	Paid          int64     `json:"paid"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// FundingPayment 펀딩 결제 기록과 그 결제로 계산된 추천 페이백
+type FundingPayment struct {
+	SchemaVersion          int               `json:"schemaVersion"`
+	PayID                  string            `json:"payId"`
+	NickName               string            `json:"nickName"`
+	Amount                 int64             `json:"amount"`
+	IsBasePaymentCompleted bool              `json:"isBasePaymentCompleted"`
+	Referrals              []FundingReferral `json:"referrals"`
+	TxID                   string            `json:"txID"`
+	RecordedTime           time.Time         `json:"recordedTime"`
+}
+
+const (
+	inviterPrefix         = "inviter"
+	inviteePrefix         = "invitee"
+	referralRatesPrefix   = "referralRates"
+	referralBalancePrefix = "referralBalance"
+	fundingPaymentPrefix  = "fundingPayment"
+
+	// 페이백을 받을 수 있는 최대 추천 단계
+	maxReferralLevels = 5
+
+	basisPoints = 10000
+)
+
+// SetInviter 유저를 초대한 유저를 지정하는 함수, 한 번 지정하면 바꿀 수 없고 순환 관계는 허용하지 않는다
+func (c *TokenERC1155Contract) SetInviter(ctx contractapi.TransactionContextInterface, nickName string, inviter string) (*Inviter, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		fieldAs("nickName", "inviter", inviter).
+		check(nickName != inviter, "inviter", "distinct", "must differ from nickName").
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleReferral, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{nickName, inviter} {
+		user, err := c.GetUser(ctx, name)
+		if err != nil {
+			return nil, wrapError(err, "failed to get user information")
+		}
+		if user.UserId == "" {
+			return nil, newError(mymberr.UserNotFound, params("nickName", name), "user %s does not exist", name)
+		}
+	}
+
+	current, err := getInviter(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, newError(mymberr.InvalidState, params("nickName", nickName, "inviter", current.Inviter),
+			"inviter of %s is already set to %s", nickName, current.Inviter)
+	}
+
+	// 초대한 유저의 상위 초대 관계에 자신이 있으면 순환이 생긴다
+	for ancestor := inviter; ancestor != ""; {
+		if ancestor == nickName {
+			return nil, newError(mymberr.InvalidArgument, params("field", "inviter", "inviter", inviter),
+				"%s is invited by %s directly or indirectly", inviter, nickName)
+		}
+		parent, err := getInviter(ctx, ancestor)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		ancestor = parent.Inviter
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record := Inviter{
+		SchemaVersion: inviterSchemaVersion,
+		NickName:      nickName,
+		Inviter:       inviter,
+		TxID:          ctx.GetStub().GetTxID(),
+		SetTime:       now,
+	}
+
+	inviterKey, err := ctx.GetStub().CreateCompositeKey(inviterPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal inviter")
+	}
+	if err := ctx.GetStub().PutState(inviterKey, recordBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for inviter")
+	}
+
+	inviteeKey, err := ctx.GetStub().CreateCompositeKey(inviteePrefix, []string{inviter, nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(inviteeKey, []byte{0x00}); err != nil {
+		return nil, wrapError(err, "failed to put state for invitee index")
+	}
+
+	return &record, nil
+}
+
+// GetInviter 유저를 초대한 유저를 조회하는 함수
+func (c *TokenERC1155Contract) GetInviter(ctx contractapi.TransactionContextInterface, nickName string) (*Inviter, error) {
+
+	record, err := getInviter(ctx, nickName)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, newError(mymberr.InviterNotFound, params("nickName", nickName), "inviter of %s is not set", nickName)
+	}
+	return record, nil
+}
+
+// GetInvitees 유저가 직접 초대한 유저들의 닉네임을 조회하는 함수
+func (c *TokenERC1155Contract) GetInvitees(ctx contractapi.TransactionContextInterface, nickName string) ([]string, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(inviteePrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	invitees := []string{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		invitees = append(invitees, attributes[1])
+	}
+
+	return invitees, nil
+}
+
+// SetReferralRates 추천 단계별 페이백 비율을 지정하는 함수, 합계는 10000(100%) 을 넘을 수 없다
+func (c *TokenERC1155Contract) SetReferralRates(ctx contractapi.TransactionContextInterface, ratesBps []int64) (*ReferralRates, error) {
+
+	v := newValidator().
+		check(len(ratesBps) > 0, "ratesBps", "required", "must not be empty").
+		check(len(ratesBps) <= maxReferralLevels, "ratesBps", "maxItems", fmt.Sprintf("must contain at most %d levels", maxReferralLevels))
+	// 더하기 전에 단계별 비율을 확인해야 큰 비율의 합이 int64 범위를 넘어 한도 아래로 돌아오지 않는다
+	var total int64
+	exceeded := false
+	for i, rate := range ratesBps {
+		field := fmt.Sprintf("ratesBps[%d]", i)
+		v.nonNegative(field, rate).
+			check(rate <= basisPoints, field, "max", fmt.Sprintf("must not exceed %d", basisPoints))
+		if rate > basisPoints {
+			exceeded = true
+		} else if rate > 0 {
+			total += rate
+		}
+	}
+	v.check(exceeded || total <= basisPoints, "ratesBps", "max", fmt.Sprintf("must not add up to more than %d", basisPoints))
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := ReferralRates{
+		SchemaVersion: referralRatesSchemaVersion,
+		RatesBps:      ratesBps,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	ratesKey, err := ctx.GetStub().CreateCompositeKey(referralRatesPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	ratesBytes, err := json.Marshal(rates)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal referral rates")
+	}
+	if err := ctx.GetStub().PutState(ratesKey, ratesBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for referral rates")
+	}
+
+	return &rates, nil
+}
+
+// GetReferralRates 추천 단계별 페이백 비율을 조회하는 함수
+func (c *TokenERC1155Contract) GetReferralRates(ctx contractapi.TransactionContextInterface) (*ReferralRates, error) {
+
+	ratesKey, err := ctx.GetStub().CreateCompositeKey(referralRatesPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	ratesBytes, err := ctx.GetStub().GetState(ratesKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read referral rates")
+	}
+	if ratesBytes == nil {
+		return nil, newError(mymberr.InvalidState, nil, "referral rate table is not set")
+	}
+
+	var rates ReferralRates
+	if err := unmarshalVersioned(referralRatesPrefix, ratesBytes, &rates); err != nil {
+		return nil, wrapError(err, "failed to unmarshal referral rates")
+	}
+	return &rates, nil
+}
+
+// RecordFundingPayment 펀딩 결제를 기록하고 초대 관계를 따라 단계별 추천 페이백을 계산하는 함수
+// 계산된 페이백은 "<payId>-L<단계>" payId 의 추천 기록으로 저장되고 받는 유저의 대기 잔액에 더해진다
+func (c *TokenERC1155Contract) RecordFundingPayment(ctx contractapi.TransactionContextInterface, payID string, nickName string,
+	amount int64, isBasePaymentCompleted bool) (*FundingPayment, error) {
+
+	err := newValidator().
+		field("payID", payID).
+		check(!levelPayIDPattern.MatchString(payID), "payID", "reserved", "must not end with -L<level>, reserved for generated paybacks").
+		field("nickName", nickName).
+		positive("amount", amount).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleReferral, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	paymentKey, err := ctx.GetStub().CreateCompositeKey(fundingPaymentPrefix, []string{payID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	existing, err := ctx.GetStub().GetState(paymentKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read funding payment")
+	}
+	if existing != nil {
+		return nil, newError(mymberr.PaymentExists, params("payId", payID), "funding payment %s already exists", payID)
+	}
+
+	rates, err := c.GetReferralRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 곱이 int64 범위를 넘으면 페이백이 음수가 되어 조용히 건너뛰므로 미리 막는다
+	for _, rate := range rates.RatesBps {
+		if rate > 0 && amount > math.MaxInt64/rate {
+			return nil, newError(mymberr.InvalidArgument, params("field", "amount"), "amount %d exceeds the payback range at rate %d", amount, rate)
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := FundingPayment{
+		SchemaVersion:          fundingPaymentSchemaVersion,
+		PayID:                  payID,
+		NickName:               nickName,
+		Amount:                 amount,
+		IsBasePaymentCompleted: isBasePaymentCompleted,
+		Referrals:              []FundingReferral{},
+		TxID:                   ctx.GetStub().GetTxID(),
+		RecordedTime:           now,
+	}
+
+	// 초대 관계는 순환이 없으므로 한 유저가 두 단계에 나오지 않는다
+	invitee := nickName
+	for level := 1; level <= len(rates.RatesBps); level++ {
+		record, err := getInviter(ctx, invitee)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+		invitee = record.Inviter
+
+		payback := amount * rates.RatesBps[level-1] / basisPoints
+		if payback <= 0 {
+			continue
+		}
+
+		referral := FundingReferral{
+			SchemaVersion:          fundingReferralSchemaVersion,
+			PayID:                  fmt.Sprintf("%s-L%d", payID, level),
+			ReferralFrom:           nickName,
+			ReferralTo:             record.Inviter,
+			ReferralPayback:        payback,
+			Level:                  level,
+			IsBasePaymentCompleted: isBasePaymentCompleted,
+			RecordedTxID:           ctx.GetStub().GetTxID(),
+			RecordedTime:           now,
+		}
+		if err := insertReferral(ctx, &referral); err != nil {
+			return nil, err
+		}
+
+		balance, err := getReferralBalance(ctx, record.Inviter)
+		if err != nil {
+			return nil, err
+		}
+		balance.Pending += payback
+		balance.UpdatedTime = now
+		if err := putReferralBalance(ctx, balance); err != nil {
+			return nil, err
+		}
+
+		payment.Referrals = append(payment.Referrals, referral)
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal funding payment")
+	}
+	if err := ctx.GetStub().PutState(paymentKey, paymentBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for funding payment")
+	}
+
+	if err := ctx.GetStub().SetEvent("RecordFundingPayment", paymentBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &payment, nil
+}
+
+// GetReferralBalance 자동 계산된 추천 페이백의 대기, 지급 잔액을 조회하는 함수
+func (c *TokenERC1155Contract) GetReferralBalance(ctx contractapi.TransactionContextInterface, nickName string) (*ReferralBalance, error) {
+	return getReferralBalance(ctx, nickName)
+}
+
+// 초대 관계를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getInviter(ctx contractapi.TransactionContextInterface, nickName string) (*Inviter, error) {
+	inviterKey, err := ctx.GetStub().CreateCompositeKey(inviterPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	recordBytes, err := ctx.GetStub().GetState(inviterKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read inviter")
+	}
+	if recordBytes == nil {
+		return nil, nil
+	}
+
+	var record Inviter
+	if err := unmarshalVersioned(inviterPrefix, recordBytes, &record); err != nil {
+		return nil, wrapError(err, "failed to unmarshal inviter")
+	}
+	return &record, nil
+}
+
+// 추천 페이백 잔액을 조회하는 도우미 함수, 없으면 0 인 잔액을 반환
+func getReferralBalance(ctx contractapi.TransactionContextInterface, nickName string) (*ReferralBalance, error) {
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(referralBalancePrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	balanceBytes, err := ctx.GetStub().GetState(balanceKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read referral balance")
+	}
+	if balanceBytes == nil {
+		return &ReferralBalance{SchemaVersion: referralBalanceSchemaVersion, NickName: nickName}, nil
+	}
+
+	var balance ReferralBalance
+	if err := unmarshalVersioned(referralBalancePrefix, balanceBytes, &balance); err != nil {
+		return nil, wrapError(err, "failed to unmarshal referral balance")
+	}
+	return &balance, nil
+}
+
+func putReferralBalance(ctx contractapi.TransactionContextInterface, balance *ReferralBalance) error {
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(referralBalancePrefix, []string{balance.NickName})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	balanceBytes, err := json.Marshal(balance)
+	if err != nil {
+		return wrapError(err, "failed to marshal referral balance")
+	}
+	if err := ctx.GetStub().PutState(balanceKey, balanceBytes); err != nil {
+		return wrapError(err, "failed to put state for referral balance")
+	}
+	return nil
+}
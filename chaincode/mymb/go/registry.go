@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Category 토큰 카테고리 레지스트리 항목
+type Category struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Code          string    `json:"code"`
+	NameKo        string    `json:"nameKo"`
+	NameEn        string    `json:"nameEn"`
+	ParentCode    string    `json:"parentCode"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// TokenType 토큰 종류 레지스트리 항목, 전송 정책은 tokenType 범위의 TransferPolicy 로 저장된다
+// Fungible 종류의 토큰은 하나하나가 고유하지 않으므로 공동 소유 풀에 넣을 수 없다
+type TokenType struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Code          string    `json:"code"`
+	NameKo        string    `json:"nameKo"`
+	NameEn        string    `json:"nameEn"`
+	Fungible      bool      `json:"fungible"`
+	ClientID      string    `json:"clientID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+const (
+	categoryPrefix  = "category"
+	tokenTypePrefix = "tokenType"
+
+	// 카테고리 계층의 최대 깊이
+	maxCategoryDepth = 8
+)
+
+// SetCategory 카테고리를 등록하거나 수정하는 함수, parentCode 가 비어 있으면 최상위 카테고리
+func (c *TokenERC1155Contract) SetCategory(ctx contractapi.TransactionContextInterface, code string, nameKo string, nameEn string, parentCode string) (*Category, error) {
+
+	v := newValidator().
+		fieldAs("categoryCode", "code", code).
+		fieldAs("displayName", "nameKo", nameKo).
+		fieldAs("displayName", "nameEn", nameEn)
+	if parentCode != "" {
+		v.fieldAs("categoryCode", "parentCode", parentCode).
+			check(parentCode != code, "parentCode", "cycle", "must not be the category itself")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	// 부모를 따라 올라가면서 존재 여부와 순환을 확인한다
+	ancestor := parentCode
+	for depth := 0; ancestor != ""; depth++ {
+		if depth >= maxCategoryDepth {
+			return nil, newError(mymberr.InvalidArgument, params("field", "parentCode"), "category hierarchy deeper than %d levels", maxCategoryDepth)
+		}
+		parent, err := getCategory(ctx, ancestor)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, newError(mymberr.CategoryNotFound, params("categoryCode", ancestor), "category %s does not exist", ancestor)
+		}
+		if parent.ParentCode == code {
+			return nil, newError(mymberr.InvalidArgument, params("field", "parentCode", "categoryCode", code),
+				"category %s cannot be a descendant of itself", code)
+		}
+		ancestor = parent.ParentCode
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	category := Category{
+		SchemaVersion: categorySchemaVersion,
+		Code:          code,
+		NameKo:        nameKo,
+		NameEn:        nameEn,
+		ParentCode:    parentCode,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	categoryKey, err := ctx.GetStub().CreateCompositeKey(categoryPrefix, []string{code})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	categoryBytes, err := json.Marshal(category)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal category")
+	}
+	if err := ctx.GetStub().PutState(categoryKey, categoryBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for category")
+	}
+
+	return &category, nil
+}
+
+// GetCategory 카테고리를 조회하는 함수
+func (c *TokenERC1155Contract) GetCategory(ctx contractapi.TransactionContextInterface, code string) (*Category, error) {
+
+	category, err := getCategory(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, newError(mymberr.CategoryNotFound, params("categoryCode", code), "category %s does not exist", code)
+	}
+	return category, nil
+}
+
+// GetAllCategories 등록된 모든 카테고리를 조회하는 함수
+func (c *TokenERC1155Contract) GetAllCategories(ctx contractapi.TransactionContextInterface) ([]Category, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(categoryPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	categories := []Category{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var category Category
+		if err := unmarshalVersioned(categoryPrefix, queryResponse.Value, &category); err != nil {
+			return nil, wrapError(err, "failed to unmarshal category")
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+// SetTokenType 토큰 종류를 등록하거나 수정하는 함수
+// 전송 제한 인자는 SetTransferPolicy 와 같으며, 모두 제한하지 않으면 기존 tokenType 전송 정책을 그대로 둔다
+// 정책을 없애려면 DeleteTransferPolicy 를 호출한다
+func (c *TokenERC1155Contract) SetTokenType(ctx contractapi.TransactionContextInterface, code string, nameKo string, nameEn string, fungible bool,
+	nonTransferable bool, lockedUntil int64, lockDurationSeconds int64, maxTransfers int) (*TokenType, error) {
+
+	err := newValidator().
+		fieldAs("tokenType", "code", code).
+		fieldAs("displayName", "nameKo", nameKo).
+		fieldAs("displayName", "nameEn", nameEn).
+		nonNegative("lockedUntil", lockedUntil).
+		nonNegative("lockDurationSeconds", lockDurationSeconds).
+		nonNegative("maxTransfers", int64(maxTransfers)).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenType := TokenType{
+		SchemaVersion: tokenTypeSchemaVersion,
+		Code:          code,
+		NameKo:        nameKo,
+		NameEn:        nameEn,
+		Fungible:      fungible,
+		ClientID:      clientID,
+		UpdatedTime:   now,
+	}
+
+	tokenTypeKey, err := ctx.GetStub().CreateCompositeKey(tokenTypePrefix, []string{code})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	tokenTypeBytes, err := json.Marshal(tokenType)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal token type")
+	}
+	if err := ctx.GetStub().PutState(tokenTypeKey, tokenTypeBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for token type")
+	}
+
+	if nonTransferable || lockedUntil > 0 || lockDurationSeconds > 0 || maxTransfers > 0 {
+		if _, err := c.SetTransferPolicy(ctx, policyScopeTokenType, code, nonTransferable, lockedUntil, lockDurationSeconds, maxTransfers); err != nil {
+			return nil, err
+		}
+	}
+
+	return &tokenType, nil
+}
+
+// GetTokenType 토큰 종류를 조회하는 함수, 전송 정책은 GetTransferPolicy 로 조회한다
+func (c *TokenERC1155Contract) GetTokenType(ctx contractapi.TransactionContextInterface, code string) (*TokenType, error) {
+
+	tokenType, err := getTokenType(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if tokenType == nil {
+		return nil, newError(mymberr.TokenTypeNotFound, params("tokenType", code), "token type %s does not exist", code)
+	}
+	return tokenType, nil
+}
+
+// GetAllTokenTypes 등록된 모든 토큰 종류를 조회하는 함수
+func (c *TokenERC1155Contract) GetAllTokenTypes(ctx contractapi.TransactionContextInterface) ([]TokenType, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenTypePrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	tokenTypes := []TokenType{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var tokenType TokenType
+		if err := unmarshalVersioned(tokenTypePrefix, queryResponse.Value, &tokenType); err != nil {
+			return nil, wrapError(err, "failed to unmarshal token type")
+		}
+		tokenTypes = append(tokenTypes, tokenType)
+	}
+
+	return tokenTypes, nil
+}
+
+// 카테고리를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getCategory(ctx contractapi.TransactionContextInterface, code string) (*Category, error) {
+	categoryKey, err := ctx.GetStub().CreateCompositeKey(categoryPrefix, []string{code})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	categoryBytes, err := ctx.GetStub().GetState(categoryKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read category")
+	}
+	if categoryBytes == nil {
+		return nil, nil
+	}
+
+	var category Category
+	if err := unmarshalVersioned(categoryPrefix, categoryBytes, &category); err != nil {
+		return nil, wrapError(err, "failed to unmarshal category")
+	}
+	return &category, nil
+}
+
+// 토큰 종류를 조회하는 도우미 함수, 없으면 nil 을 반환
+func getTokenType(ctx contractapi.TransactionContextInterface, code string) (*TokenType, error) {
+	tokenTypeKey, err := ctx.GetStub().CreateCompositeKey(tokenTypePrefix, []string{code})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	tokenTypeBytes, err := ctx.GetStub().GetState(tokenTypeKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read token type")
+	}
+	if tokenTypeBytes == nil {
+		return nil, nil
+	}
+
+	var tokenType TokenType
+	if err := unmarshalVersioned(tokenTypePrefix, tokenTypeBytes, &tokenType); err != nil {
+		return nil, wrapError(err, "failed to unmarshal token type")
+	}
+	return &tokenType, nil
+}
+
+// 카테고리와 토큰 종류가 레지스트리에 등록되어 있는지 확인하는 도우미 함수
+func checkCodesRegistered(ctx contractapi.TransactionContextInterface, categoryCode string, tokenType string) error {
+	category, err := getCategory(ctx, categoryCode)
+	if err != nil {
+		return err
+	}
+	registeredType, err := getTokenType(ctx, tokenType)
+	if err != nil {
+		return err
+	}
+	return newValidator().
+		check(category != nil, "categoryCode", "registered", "category "+categoryCode+" is not registered").
+		check(registeredType != nil, "tokenType", "registered", "token type "+tokenType+" is not registered").
+		err()
+}
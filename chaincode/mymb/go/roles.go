@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// 클라이언트 인증서에 발급되는 역할 속성 이름
+	roleAttribute = "mymb.role"
+
+	roleAdmin = "admin"
+)
+
+// 요청한 클라이언트가 해당 역할을 가지고 있는지 확인하는 도우미 함수
+func assertRole(ctx contractapi.TransactionContextInterface, role string) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role); err != nil {
+		return newError(mymberr.Unauthorized, params("role", role), "submitting client not authorized, does not have %s role", role)
+	}
+	return nil
+}
+
+// 요청한 클라이언트가 역할 중 하나라도 가지고 있는지 확인하는 도우미 함수
+func assertAnyRole(ctx contractapi.TransactionContextInterface, roles ...string) error {
+	for _, role := range roles {
+		if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role); err == nil {
+			return nil
+		}
+	}
+	return newError(mymberr.Unauthorized, params("role", strings.Join(roles, ",")), "submitting client not authorized, requires one of %v roles", roles)
+}
+
+// 요청한 클라이언트의 ID 를 반환하는 도우미 함수
+func getClientID(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", wrapError(err, "failed to get client identity")
+	}
+	return clientID, nil
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 저장 객체별 현재 스키마 버전
+// schemaVersion 필드가 없는 기존 레코드는 버전 1 로 본다
+const (
+	tokenSchemaVersion              = 2
+	userSchemaVersion               = 3
+	auctionSchemaVersion            = 1
+	transferPolicySchemaVersion     = 1
+	accountStatusSchemaVersion      = 1
+	pauseStateSchemaVersion         = 1
+	migrationSchemaVersion          = 1
+	metadataSchemaVersion           = 1
+	metadataURISchemaVersion        = 1
+	categorySchemaVersion           = 1
+	tokenTypeSchemaVersion          = 1
+	airdropCampaignSchemaVersion    = 1
+	airdropRecipientSchemaVersion   = 1
+	snapshotSchemaVersion           = 1
+	snapshotLeafSchemaVersion       = 1
+	fundingReferralSchemaVersion    = 1
+	inviterSchemaVersion            = 1
+	referralRatesSchemaVersion      = 1
+	referralBalanceSchemaVersion    = 1
+	fundingPaymentSchemaVersion     = 1
+	trustEdgeSchemaVersion          = 1
+	kycSchemaVersion                = 1
+	kycRequirementSchemaVersion     = 1
+	userPIISchemaVersion            = 1
+	enterpriseSchemaVersion         = 1
+	enterpriseMemberSchemaVersion   = 1
+	tokenLoanSchemaVersion          = 1
+	fractionPoolSchemaVersion       = 1
+	fractionShareSchemaVersion      = 1
+	buyoutOfferSchemaVersion        = 1
+	categoryHoldingSchemaVersion    = 1
+	counterSchemaVersion            = 1
+	multisigPolicySchemaVersion     = 1
+	adminProposalSchemaVersion      = 1
+	fundingEndorsementSchemaVersion = 1
+	erc20ConfigSchemaVersion        = 1
+	settlementAccountSchemaVersion  = 1
+	settlementSchemaVersion         = 1
+	bridgeConfigSchemaVersion       = 1
+	bridgeEscrowSchemaVersion       = 1
+	bridgeRequestSchemaVersion      = 1
+	pointGrantWindowSchemaVersion   = 1
+
+	// 유저 블록은 닉네임을 그대로 키로 쓰므로 스키마 구분용 이름만 둔다
+	userObjectType = "user"
+
+	migrationPrefix = "migration"
+)
+
+// 레코드를 한 버전 올리는 함수, 키는 올리기 전 버전
+type schemaUpcaster func(record map[string]interface{})
+
+type objectSchema struct {
+	current   int
+	upcasters map[int]schemaUpcaster
+}
+
+var objectSchemas = map[string]objectSchema{
+	tokenPrefix: {
+		current:   tokenSchemaVersion,
+		upcasters: map[int]schemaUpcaster{1: upcastTokenV1},
+	},
+	userObjectType: {
+		current:   userSchemaVersion,
+		upcasters: map[int]schemaUpcaster{1: upcastUserV1, 2: upcastUserV2},
+	},
+	auctionPrefix:            {current: auctionSchemaVersion},
+	transferPolicyPrefix:     {current: transferPolicySchemaVersion},
+	accountStatusPrefix:      {current: accountStatusSchemaVersion},
+	pauseStatePrefix:         {current: pauseStateSchemaVersion},
+	migrationPrefix:          {current: migrationSchemaVersion},
+	metadataPrefix:           {current: metadataSchemaVersion},
+	metadataURIPrefix:        {current: metadataURISchemaVersion},
+	categoryPrefix:           {current: categorySchemaVersion},
+	tokenTypePrefix:          {current: tokenTypeSchemaVersion},
+	airdropCampaignPrefix:    {current: airdropCampaignSchemaVersion},
+	airdropRecipientPrefix:   {current: airdropRecipientSchemaVersion},
+	snapshotPrefix:           {current: snapshotSchemaVersion},
+	snapshotLeafPrefix:       {current: snapshotLeafSchemaVersion},
+	referralPrefix:           {current: fundingReferralSchemaVersion},
+	inviterPrefix:            {current: inviterSchemaVersion},
+	referralRatesPrefix:      {current: referralRatesSchemaVersion},
+	referralBalancePrefix:    {current: referralBalanceSchemaVersion},
+	fundingPaymentPrefix:     {current: fundingPaymentSchemaVersion},
+	trustPrefix:              {current: trustEdgeSchemaVersion},
+	kycPrefix:                {current: kycSchemaVersion},
+	kycRequirementPrefix:     {current: kycRequirementSchemaVersion},
+	piiPrefix:                {current: userPIISchemaVersion},
+	enterprisePrefix:         {current: enterpriseSchemaVersion},
+	enterpriseMemberPrefix:   {current: enterpriseMemberSchemaVersion},
+	loanPrefix:               {current: tokenLoanSchemaVersion},
+	fractionPoolPrefix:       {current: fractionPoolSchemaVersion},
+	fractionSharePrefix:      {current: fractionShareSchemaVersion},
+	buyoutOfferPrefix:        {current: buyoutOfferSchemaVersion},
+	categoryHoldingPrefix:    {current: categoryHoldingSchemaVersion},
+	counterPrefix:            {current: counterSchemaVersion},
+	multisigPolicyPrefix:     {current: multisigPolicySchemaVersion},
+	proposalPrefix:           {current: adminProposalSchemaVersion},
+	fundingEndorsementPrefix: {current: fundingEndorsementSchemaVersion},
+	erc20ConfigPrefix:        {current: erc20ConfigSchemaVersion},
+	settlementAccountPrefix:  {current: settlementAccountSchemaVersion},
+	settlementPrefix:         {current: settlementSchemaVersion},
+	bridgeConfigPrefix:       {current: bridgeConfigSchemaVersion},
+	bridgeEscrowPrefix:       {current: bridgeEscrowSchemaVersion},
+	bridgeRequestPrefix:      {current: bridgeRequestSchemaVersion},
+	pointGrantWindowPrefix:   {current: pointGrantWindowSchemaVersion},
+}
+
+// MigrationProgress Migrate 한 번의 배치 결과와 누적 진행 상황
+type MigrationProgress struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	FromVersion   int       `json:"fromVersion"`
+	Phase         string    `json:"phase"`
+	Scanned       int       `json:"scanned"`
+	Migrated      int       `json:"migrated"`
+	TotalScanned  int       `json:"totalScanned"`
+	TotalMigrated int       `json:"totalMigrated"`
+	Bookmark      string    `json:"bookmark"`
+	Done          bool      `json:"done"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// 토큰 v1 -> v2: 전송 횟수와 마지막 전송 시각 추가
+func upcastTokenV1(record map[string]interface{}) {
+	if _, ok := record["transferCount"]; !ok {
+		record["transferCount"] = 0
+	}
+	if _, ok := record["lastTransferTime"]; !ok {
+		record["lastTransferTime"] = time.Time{}
+	}
+}
+
+// 유저 v1 -> v2: 비어 있는 보유 토큰 목록과 포인트를 기본값으로 채움
+func upcastUserV1(record map[string]interface{}) {
+	if owned, ok := record["ownedToken"]; !ok || owned == nil {
+		record["ownedToken"] = []string{}
+	}
+	if _, ok := record["mymPoint"]; !ok {
+		record["mymPoint"] = 0
+	}
+}
+
+// v2 유저에는 개인 정보 해시가 없다
+func upcastUserV2(record map[string]interface{}) {
+	if _, ok := record["piiHash"]; !ok {
+		record["piiHash"] = ""
+	}
+}
+
+// Migrate fromVersion 인 토큰, 유저 레코드를 현재 스키마 버전으로 다시 쓰는 함수
+// pageSize 개씩 나누어 처리하며 반환된 bookmark 로 다음 배치를 이어서 실행한다
+func (c *TokenERC1155Contract) Migrate(ctx contractapi.TransactionContextInterface, fromVersion int, pageSize int, bookmark string) (*MigrationProgress, error) {
+
+	err := newValidator().
+		positive("fromVersion", int64(fromVersion)).
+		positive("pageSize", int64(pageSize)).
+		field("bookmark", bookmark).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	// bookmark 는 "<phase>|<마지막으로 처리한 키>" 형식, 토큰을 먼저 처리하고 유저를 처리한다
+	phase, lastKey := tokenPrefix, ""
+	if bookmark != "" {
+		parts := strings.SplitN(bookmark, "|", 2)
+		if len(parts) != 2 || (parts[0] != tokenPrefix && parts[0] != userObjectType) {
+			return nil, newError(mymberr.InvalidArgument, params("field", "bookmark"), "invalid bookmark %s", bookmark)
+		}
+		phase, lastKey = parts[0], parts[1]
+	}
+
+	progress, err := getMigrationProgress(ctx, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	// bookmark 없이 시작하면 새로운 마이그레이션으로 보고 누적 값을 초기화한다
+	if bookmark == "" {
+		progress = &MigrationProgress{SchemaVersion: migrationSchemaVersion, FromVersion: fromVersion}
+	}
+	progress.Phase = phase
+	progress.Scanned = 0
+	progress.Migrated = 0
+
+	var nextKey string
+	if phase == tokenPrefix {
+		nextKey, err = migrateTokens(ctx, progress, lastKey, pageSize)
+	} else {
+		nextKey, err = migrateUsers(ctx, progress, lastKey, pageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case nextKey != "":
+		progress.Bookmark = phase + "|" + nextKey
+	case phase == tokenPrefix:
+		progress.Bookmark = userObjectType + "|"
+	default:
+		progress.Bookmark = ""
+		progress.Done = true
+	}
+
+	progress.TotalScanned += progress.Scanned
+	progress.TotalMigrated += progress.Migrated
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress.UpdatedTime = now
+
+	progressKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{fmt.Sprint(fromVersion)})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	progressBytes, err := json.Marshal(progress)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal migration progress")
+	}
+	if err := ctx.GetStub().PutState(progressKey, progressBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for migration progress")
+	}
+
+	fmt.Printf("migration from v%d: %s scanned %d, migrated %d\n", fromVersion, phase, progress.Scanned, progress.Migrated)
+	return progress, nil
+}
+
+// GetMigrationProgress fromVersion 마이그레이션의 누적 진행 상황을 조회하는 함수
+func (c *TokenERC1155Contract) GetMigrationProgress(ctx contractapi.TransactionContextInterface, fromVersion int) (*MigrationProgress, error) {
+	return getMigrationProgress(ctx, fromVersion)
+}
+
+// 토큰 레코드를 lastKey 다음부터 최대 pageSize 개 마이그레이션하고, 남은 레코드가 있으면 마지막 키를 반환
+func migrateTokens(ctx contractapi.TransactionContextInterface, progress *MigrationProgress, lastKey string, pageSize int) (string, error) {
+
+	// 업데이트 트랜잭션에서는 페이지네이션 조회를 쓸 수 없으므로 bookmark 이전 키는 건너뛴다
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenPrefix, []string{})
+	if err != nil {
+		return "", wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return "", wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return "", wrapError(err, "failed to split composite key")
+		}
+		tokenNumber := attributes[0]
+		if lastKey != "" && tokenNumber <= lastKey {
+			continue
+		}
+		if progress.Scanned == pageSize {
+			return lastKey, nil
+		}
+
+		progress.Scanned++
+		lastKey = tokenNumber
+
+		if recordSchemaVersion(queryResponse.Value) != progress.FromVersion {
+			continue
+		}
+
+		token, err := unmarshalToken(queryResponse.Value)
+		if err != nil {
+			return "", err
+		}
+		if err := putToken(ctx, token); err != nil {
+			return "", err
+		}
+		progress.Migrated++
+	}
+
+	return "", nil
+}
+
+// 유저 레코드를 lastKey 다음부터 최대 pageSize 개 마이그레이션하고, 남은 레코드가 있으면 마지막 키를 반환
+func migrateUsers(ctx contractapi.TransactionContextInterface, progress *MigrationProgress, lastKey string, pageSize int) (string, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(lastKey, "")
+	if err != nil {
+		return "", wrapError(err, "failed to get state by range")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return "", wrapError(err, "failed to get next query response")
+		}
+
+		if queryResponse.Key == lastKey {
+			continue
+		}
+		if progress.Scanned == pageSize {
+			return lastKey, nil
+		}
+
+		progress.Scanned++
+		lastKey = queryResponse.Key
+
+		if recordSchemaVersion(queryResponse.Value) != progress.FromVersion {
+			continue
+		}
+
+		user, err := unmarshalUser(queryResponse.Value)
+		if err != nil {
+			return "", err
+		}
+		if err := putUser(ctx, user); err != nil {
+			return "", err
+		}
+		progress.Migrated++
+	}
+
+	return "", nil
+}
+
+// 마이그레이션 누적 진행 상황을 조회하는 도우미 함수
+func getMigrationProgress(ctx contractapi.TransactionContextInterface, fromVersion int) (*MigrationProgress, error) {
+	progressKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{fmt.Sprint(fromVersion)})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	progressBytes, err := ctx.GetStub().GetState(progressKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read migration progress")
+	}
+	if progressBytes == nil {
+		return &MigrationProgress{SchemaVersion: migrationSchemaVersion, FromVersion: fromVersion}, nil
+	}
+
+	var progress MigrationProgress
+	if err := unmarshalVersioned(migrationPrefix, progressBytes, &progress); err != nil {
+		return nil, wrapError(err, "failed to unmarshal migration progress")
+	}
+	return &progress, nil
+}
+
+// 저장된 레코드의 스키마 버전을 반환하는 도우미 함수, 필드가 없으면 1
+func recordSchemaVersion(data []byte) int {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.SchemaVersion == 0 {
+		return 1
+	}
+	return header.SchemaVersion
+}
+
+// 저장된 레코드를 현재 스키마 버전으로 올려서 out 에 디코딩하는 도우미 함수
+func unmarshalVersioned(objectType string, data []byte, out interface{}) error {
+
+	schema, ok := objectSchemas[objectType]
+	if !ok {
+		return newError(mymberr.Internal, params("objectType", objectType), "unknown object type %s", objectType)
+	}
+
+	version := recordSchemaVersion(data)
+	if version > schema.current {
+		return newError(mymberr.UnsupportedSchema, params("objectType", objectType, "schemaVersion", strconv.Itoa(version)), "%s record has schema version %d newer than supported version %d", objectType, version, schema.current)
+	}
+
+	if version < schema.current {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return wrapError(err, "failed to unmarshal %s record", objectType)
+		}
+		for v := version; v < schema.current; v++ {
+			if upcast, ok := schema.upcasters[v]; ok {
+				upcast(record)
+			}
+		}
+
+		var err error
+		data, err = json.Marshal(record)
+		if err != nil {
+			return wrapError(err, "failed to marshal upcasted %s record", objectType)
+		}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return wrapError(err, "failed to unmarshal %s record", objectType)
+	}
+	return nil
+}
+
+// 토큰 레코드를 현재 스키마로 디코딩하는 도우미 함수
+func unmarshalToken(data []byte) (*Token1155, error) {
+	var token Token1155
+	if err := unmarshalVersioned(tokenPrefix, data, &token); err != nil {
+		return nil, err
+	}
+	token.SchemaVersion = tokenSchemaVersion
+	return &token, nil
+}
+
+// 유저 레코드를 현재 스키마로 디코딩하는 도우미 함수
+func unmarshalUser(data []byte) (*User, error) {
+	var user User
+	if err := unmarshalVersioned(userObjectType, data, &user); err != nil {
+		return nil, err
+	}
+	if user.OwnedToken == nil {
+		user.OwnedToken = []string{}
+	}
+	user.SchemaVersion = userSchemaVersion
+	return &user, nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ERC20Config 정산에 쓰는 같은 채널의 token-erc-20 체인코드, Channel 은 비어 있거나 현재 채널이어야 한다
+// 다른 채널의 체인코드를 호출하면 조회만 되고 쓰기는 버려지므로 정산이 토큰 전송과 함께 기록되지 않는다
+type ERC20Config struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	ChaincodeName string    `json:"chaincodeName"`
+	Channel       string    `json:"channel"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// SettlementAccount 유저의 ERC-20 계정, token-erc-20 의 ClientAccountID 값
+type SettlementAccount struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	NickName      string    `json:"nickName"`
+	AccountID     string    `json:"accountID"`
+	UpdatedTime   time.Time `json:"updatedTime"`
+}
+
+// Settlement ERC-20 으로 정산된 토큰 구매 기록
+type Settlement struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	PurchaseID    string    `json:"purchaseID"`
+	TokenNumber   string    `json:"tokenNumber"`
+	Seller        string    `json:"seller"`
+	Buyer         string    `json:"buyer"`
+	Amount        int64     `json:"amount"`
+	FromAccount   string    `json:"fromAccount"`
+	ToAccount     string    `json:"toAccount"`
+	Spender       string    `json:"spender"`
+	SettledTime   time.Time `json:"settledTime"`
+	TxID          string    `json:"txID"`
+}
+
+const (
+	erc20ConfigPrefix       = "erc20Config"
+	settlementAccountPrefix = "settlementAccount"
+	settlementPrefix        = "settlement"
+
+	// ERC-20 정산을 실행하고 유저의 정산 계정을 등록할 수 있는 역할
+	roleSettlement = "settlement"
+)
+
+// SetERC20Config 정산에 쓸 token-erc-20 체인코드를 설정하는 함수
+func (c *TokenERC1155Contract) SetERC20Config(ctx contractapi.TransactionContextInterface, chaincodeName string, channel string) (*ERC20Config, error) {
+
+	err := newValidator().
+		field("chaincodeName", chaincodeName).
+		fieldAs("channelName", "channel", channel).
+		check(channel == "" || channel == ctx.GetStub().GetChannelID(), "channel", "sameChannel", "must be empty or the current channel").
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := ERC20Config{
+		SchemaVersion: erc20ConfigSchemaVersion,
+		ChaincodeName: chaincodeName,
+		Channel:       channel,
+		UpdatedTime:   now,
+	}
+	configKey, err := ctx.GetStub().CreateCompositeKey(erc20ConfigPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal erc20 config")
+	}
+	if err := ctx.GetStub().PutState(configKey, configBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for erc20 config")
+	}
+	return &config, nil
+}
+
+// GetERC20Config 정산에 쓰는 token-erc-20 체인코드 설정을 조회하는 함수
+func (c *TokenERC1155Contract) GetERC20Config(ctx contractapi.TransactionContextInterface) (*ERC20Config, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(erc20ConfigPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	configBytes, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read erc20 config")
+	}
+	if configBytes == nil {
+		return nil, newError(mymberr.InvalidState, nil, "erc20 chaincode is not configured")
+	}
+
+	var config ERC20Config
+	if err := unmarshalVersioned(erc20ConfigPrefix, configBytes, &config); err != nil {
+		return nil, wrapError(err, "failed to unmarshal erc20 config")
+	}
+	return &config, nil
+}
+
+// SetSettlementAccount 유저의 ERC-20 계정을 등록하는 함수, accountID 는 유저가 token-erc-20 의 ClientAccountID 로 얻은 값
+func (c *TokenERC1155Contract) SetSettlementAccount(ctx contractapi.TransactionContextInterface, nickName string, accountID string) (*SettlementAccount, error) {
+
+	err := newValidator().
+		field("nickName", nickName).
+		field("accountID", accountID).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleSettlement, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	user, err := c.GetUser(ctx, nickName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user information")
+	}
+	if user.UserId == "" {
+		return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account := SettlementAccount{
+		SchemaVersion: settlementAccountSchemaVersion,
+		NickName:      nickName,
+		AccountID:     accountID,
+		UpdatedTime:   now,
+	}
+	accountKey, err := ctx.GetStub().CreateCompositeKey(settlementAccountPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	accountBytes, err := json.Marshal(account)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal settlement account")
+	}
+	if err := ctx.GetStub().PutState(accountKey, accountBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for settlement account")
+	}
+	return &account, nil
+}
+
+// GetSettlementAccount 유저의 ERC-20 계정을 조회하는 함수
+func (c *TokenERC1155Contract) GetSettlementAccount(ctx contractapi.TransactionContextInterface, nickName string) (*SettlementAccount, error) {
+	accountKey, err := ctx.GetStub().CreateCompositeKey(settlementAccountPrefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	accountBytes, err := ctx.GetStub().GetState(accountKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read settlement account")
+	}
+	if accountBytes == nil {
+		return nil, newError(mymberr.SettlementAccountNotFound, params("nickName", nickName), "settlement account of %s does not exist", nickName)
+	}
+
+	var account SettlementAccount
+	if err := unmarshalVersioned(settlementAccountPrefix, accountBytes, &account); err != nil {
+		return nil, wrapError(err, "failed to unmarshal settlement account")
+	}
+	return &account, nil
+}
+
+// SettlePurchase 토큰을 현재 소유자에게서 buyer 에게 넘기고 buyer 의 ERC-20 을 amount 만큼 소유자에게 보내는 함수
+// token-erc-20 은 트랜잭션을 제출한 클라이언트를 spender 로 보므로, buyer 는 미리 이 함수를 호출할 클라이언트에게 Approve 해 두어야 한다
+// 토큰 전송과 TransferFrom 은 한 트랜잭션의 읽기/쓰기 집합에 함께 들어가므로 둘 중 하나가 실패하면 둘 다 반영되지 않는다
+func (c *TokenERC1155Contract) SettlePurchase(ctx contractapi.TransactionContextInterface, purchaseID string, tokenNumber string, buyer string, amount int64) (*Settlement, error) {
+
+	err := newValidator().
+		field("purchaseID", purchaseID).
+		field("tokenNumber", tokenNumber).
+		fieldAs("nickName", "buyer", buyer).
+		positive("amount", amount).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertAnyRole(ctx, roleSettlement, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	existing, err := getSettlement(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.SettlementExists, params("purchaseID", purchaseID), "purchase %s is already settled", purchaseID)
+	}
+
+	config, err := c.GetERC20Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.GetToken(ctx, tokenNumber)
+	if err != nil {
+		return nil, err
+	}
+	seller := token.Owner
+	if seller == buyer {
+		return nil, newError(mymberr.InvalidArgument, params("field", "buyer"), "buyer %s already owns token %s", buyer, tokenNumber)
+	}
+
+	buyerAccount, err := c.GetSettlementAccount(ctx, buyer)
+	if err != nil {
+		return nil, err
+	}
+	sellerAccount, err := c.GetSettlementAccount(ctx, seller)
+	if err != nil {
+		return nil, err
+	}
+
+	spender, err := getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// TransferFrom 의 오류 메시지에는 코드가 없으므로 허용량은 미리 확인해 INSUFFICIENT_ALLOWANCE 로 돌려준다
+	allowance, err := getERC20Allowance(ctx, config, buyerAccount.AccountID, spender)
+	if err != nil {
+		return nil, err
+	}
+	if allowance < amount {
+		return nil, newError(mymberr.InsufficientAllowance,
+			params("buyer", buyer, "allowance", strconv.FormatInt(allowance, 10), "amount", strconv.FormatInt(amount, 10)),
+			"allowance %d granted by %s is less than %d", allowance, buyer, amount)
+	}
+
+	if err := c.TransferToken(ctx, seller, buyer, tokenNumber); err != nil {
+		return nil, err
+	}
+
+	if _, err := invokeERC20(ctx, config, "TransferFrom", buyerAccount.AccountID, sellerAccount.AccountID, strconv.FormatInt(amount, 10)); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := Settlement{
+		SchemaVersion: settlementSchemaVersion,
+		PurchaseID:    purchaseID,
+		TokenNumber:   tokenNumber,
+		Seller:        seller,
+		Buyer:         buyer,
+		Amount:        amount,
+		FromAccount:   buyerAccount.AccountID,
+		ToAccount:     sellerAccount.AccountID,
+		Spender:       spender,
+		SettledTime:   now,
+		TxID:          ctx.GetStub().GetTxID(),
+	}
+	settlementKey, err := ctx.GetStub().CreateCompositeKey(settlementPrefix, []string{purchaseID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	settlementBytes, err := json.Marshal(settlement)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal settlement")
+	}
+	if err := ctx.GetStub().PutState(settlementKey, settlementBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for settlement")
+	}
+	if err := ctx.GetStub().SetEvent("SettlePurchase", settlementBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &settlement, nil
+}
+
+// GetSettlement 구매 정산 기록을 조회하는 함수
+func (c *TokenERC1155Contract) GetSettlement(ctx contractapi.TransactionContextInterface, purchaseID string) (*Settlement, error) {
+	settlement, err := getSettlement(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		return nil, newError(mymberr.SettlementNotFound, params("purchaseID", purchaseID), "settlement of purchase %s does not exist", purchaseID)
+	}
+	return settlement, nil
+}
+
+// 구매 정산 기록을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getSettlement(ctx contractapi.TransactionContextInterface, purchaseID string) (*Settlement, error) {
+	settlementKey, err := ctx.GetStub().CreateCompositeKey(settlementPrefix, []string{purchaseID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	settlementBytes, err := ctx.GetStub().GetState(settlementKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read settlement")
+	}
+	if settlementBytes == nil {
+		return nil, nil
+	}
+
+	var settlement Settlement
+	if err := unmarshalVersioned(settlementPrefix, settlementBytes, &settlement); err != nil {
+		return nil, wrapError(err, "failed to unmarshal settlement")
+	}
+	return &settlement, nil
+}
+
+// token-erc-20 의 Allowance 를 조회하는 도우미 함수
+func getERC20Allowance(ctx contractapi.TransactionContextInterface, config *ERC20Config, owner string, spender string) (int64, error) {
+	payload, err := invokeERC20(ctx, config, "Allowance", owner, spender)
+	if err != nil {
+		return 0, err
+	}
+	allowance, err := strconv.ParseInt(strings.TrimSpace(string(payload)), 10, 64)
+	if err != nil {
+		return 0, newError(mymberr.CrossChaincodeFailed, params("chaincode", config.ChaincodeName, "function", "Allowance"), "invalid allowance %q", payload)
+	}
+	return allowance, nil
+}
+
+// token-erc-20 체인코드의 함수를 호출하는 도우미 함수, 실패 응답은 CROSS_CHAINCODE_FAILED 로 바꾼다
+func invokeERC20(ctx contractapi.TransactionContextInterface, config *ERC20Config, function string, args ...string) ([]byte, error) {
+	if config.Channel != "" && config.Channel != ctx.GetStub().GetChannelID() {
+		return nil, newError(mymberr.InvalidState, params("channel", config.Channel), "erc20 chaincode on channel %s cannot commit writes in this channel", config.Channel)
+	}
+
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := ctx.GetStub().InvokeChaincode(config.ChaincodeName, invokeArgs, config.Channel)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, newError(mymberr.CrossChaincodeFailed, params("chaincode", config.ChaincodeName, "function", function),
+			"%s %s failed: %s", config.ChaincodeName, function, response.Message)
+	}
+	return response.Payload, nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/merkle"
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Snapshot 소유권 또는 포인트 잔액의 머클 루트 스냅샷
+type Snapshot struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	SnapshotID    string    `json:"snapshotID"`
+	Kind          string    `json:"kind"`
+	Root          string    `json:"root"`
+	LeafCount     int       `json:"leafCount"`
+	TxID          string    `json:"txID"`
+	TakenTime     time.Time `json:"takenTime"`
+}
+
+// SnapshotLeaf 스냅샷에 포함된 리프 하나
+// ownership 스냅샷은 (NickName, TokenNumber), balance 스냅샷은 (NickName, MymPoint) 를 담는다
+type SnapshotLeaf struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	SnapshotID    string `json:"snapshotID"`
+	Index         int    `json:"index"`
+	NickName      string `json:"nickName"`
+	TokenNumber   string `json:"tokenNumber"`
+	MymPoint      int64  `json:"mymPoint"`
+}
+
+// SnapshotProof 리프 하나에 대한 머클 증명, merkle 패키지로 오프라인 검증한다
+type SnapshotProof struct {
+	SnapshotID string             `json:"snapshotID"`
+	Kind       string             `json:"kind"`
+	Root       string             `json:"root"`
+	TakenTime  time.Time          `json:"takenTime"`
+	Leaf       SnapshotLeaf       `json:"leaf"`
+	Proof      []merkle.ProofStep `json:"proof"`
+}
+
+const (
+	snapshotPrefix     = "snapshot"
+	snapshotLeafPrefix = "snapshotLeaf"
+
+	snapshotOwnership = "ownership"
+	snapshotBalance   = "balance"
+)
+
+// TakeSnapshot 현재 토큰 소유권(ownership) 또는 유저 MymPoint 잔액(balance)으로 머클 트리를 만들어 루트를 저장하는 함수
+// 리프는 키 순서대로 저장되며 GetOwnershipProof, GetBalanceProof 로 증명을 조회할 수 있다
+func (c *TokenERC1155Contract) TakeSnapshot(ctx contractapi.TransactionContextInterface, snapshotID string, kind string) (*Snapshot, error) {
+
+	err := newValidator().
+		field("snapshotID", snapshotID).
+		oneOf("kind", kind, snapshotOwnership, snapshotBalance).
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	existing, err := getSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newError(mymberr.SnapshotExists, params("snapshotID", snapshotID), "snapshot %s already exists", snapshotID)
+	}
+
+	leaves := []SnapshotLeaf{}
+	if kind == snapshotOwnership {
+		tokens, err := c.GetAllTokens(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range tokens {
+			leaves = append(leaves, SnapshotLeaf{NickName: token.Owner, TokenNumber: token.TokenNumber})
+		}
+	} else {
+		users, err := c.GetAllUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			leaves = append(leaves, SnapshotLeaf{NickName: user.NickName, MymPoint: user.MymPoint})
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range leaves {
+		leaves[i].SchemaVersion = snapshotLeafSchemaVersion
+		leaves[i].SnapshotID = snapshotID
+		leaves[i].Index = i
+		if err := putSnapshotLeaf(ctx, &leaves[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := Snapshot{
+		SchemaVersion: snapshotSchemaVersion,
+		SnapshotID:    snapshotID,
+		Kind:          kind,
+		Root:          snapshotTree(kind, leaves).Root(),
+		LeafCount:     len(leaves),
+		TxID:          ctx.GetStub().GetTxID(),
+		TakenTime:     now,
+	}
+
+	snapshotKey, err := ctx.GetStub().CreateCompositeKey(snapshotPrefix, []string{snapshotID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	snapshotBytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal snapshot")
+	}
+	if err := ctx.GetStub().PutState(snapshotKey, snapshotBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for snapshot")
+	}
+
+	if err := ctx.GetStub().SetEvent("TakeSnapshot", snapshotBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &snapshot, nil
+}
+
+// GetSnapshot 스냅샷의 머클 루트와 시각을 조회하는 함수
+func (c *TokenERC1155Contract) GetSnapshot(ctx contractapi.TransactionContextInterface, snapshotID string) (*Snapshot, error) {
+
+	snapshot, err := getSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, newError(mymberr.SnapshotNotFound, params("snapshotID", snapshotID), "snapshot %s does not exist", snapshotID)
+	}
+	return snapshot, nil
+}
+
+// GetAllSnapshots 모든 스냅샷을 조회하는 함수
+func (c *TokenERC1155Contract) GetAllSnapshots(ctx contractapi.TransactionContextInterface) ([]Snapshot, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(snapshotPrefix, []string{})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	snapshots := []Snapshot{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var snapshot Snapshot
+		if err := unmarshalVersioned(snapshotPrefix, queryResponse.Value, &snapshot); err != nil {
+			return nil, wrapError(err, "failed to unmarshal snapshot")
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// GetOwnershipProof owner 가 스냅샷 시점에 tokenNumber 를 가지고 있었다는 머클 증명을 조회하는 함수
+func (c *TokenERC1155Contract) GetOwnershipProof(ctx contractapi.TransactionContextInterface, snapshotID string, owner string, tokenNumber string) (*SnapshotProof, error) {
+	return c.getSnapshotProof(ctx, snapshotID, snapshotOwnership, func(leaf SnapshotLeaf) bool {
+		return leaf.NickName == owner && leaf.TokenNumber == tokenNumber
+	}, params("snapshotID", snapshotID, "nickName", owner, "tokenNumber", tokenNumber))
+}
+
+// GetBalanceProof 유저의 스냅샷 시점 MymPoint 잔액에 대한 머클 증명을 조회하는 함수
+func (c *TokenERC1155Contract) GetBalanceProof(ctx contractapi.TransactionContextInterface, snapshotID string, nickName string) (*SnapshotProof, error) {
+	return c.getSnapshotProof(ctx, snapshotID, snapshotBalance, func(leaf SnapshotLeaf) bool {
+		return leaf.NickName == nickName
+	}, params("snapshotID", snapshotID, "nickName", nickName))
+}
+
+// 스냅샷의 리프를 모두 읽어 트리를 다시 만들고 조건에 맞는 리프의 증명을 반환하는 함수
+func (c *TokenERC1155Contract) getSnapshotProof(ctx contractapi.TransactionContextInterface, snapshotID string, kind string,
+	match func(SnapshotLeaf) bool, errParams map[string]string) (*SnapshotProof, error) {
+
+	snapshot, err := c.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Kind != kind {
+		return nil, newError(mymberr.InvalidArgument, params("field", "snapshotID", "kind", snapshot.Kind),
+			"snapshot %s is a %s snapshot, not %s", snapshotID, snapshot.Kind, kind)
+	}
+
+	leaves, err := getSnapshotLeaves(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, leaf := range leaves {
+		if !match(leaf) {
+			continue
+		}
+		proof, err := snapshotTree(kind, leaves).Proof(leaf.Index)
+		if err != nil {
+			return nil, wrapError(err, "failed to build merkle proof")
+		}
+		return &SnapshotProof{
+			SnapshotID: snapshotID,
+			Kind:       kind,
+			Root:       snapshot.Root,
+			TakenTime:  snapshot.TakenTime,
+			Leaf:       leaf,
+			Proof:      proof,
+		}, nil
+	}
+
+	return nil, newError(mymberr.SnapshotLeafNotFound, errParams, "no matching leaf in snapshot %s", snapshotID)
+}
+
+// 스냅샷 리프들로 머클 트리를 만드는 도우미 함수
+func snapshotTree(kind string, leaves []SnapshotLeaf) *merkle.Tree {
+	encoded := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		if kind == snapshotOwnership {
+			encoded[i] = merkle.OwnershipLeaf(leaf.NickName, leaf.TokenNumber)
+		} else {
+			encoded[i] = merkle.BalanceLeaf(leaf.NickName, leaf.MymPoint)
+		}
+	}
+	return merkle.New(encoded)
+}
+
+// 스냅샷을 조회하는 도우미 함수, 없으면 nil 을 반환
+func getSnapshot(ctx contractapi.TransactionContextInterface, snapshotID string) (*Snapshot, error) {
+	snapshotKey, err := ctx.GetStub().CreateCompositeKey(snapshotPrefix, []string{snapshotID})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	snapshotBytes, err := ctx.GetStub().GetState(snapshotKey)
+	if err != nil {
+		return nil, wrapError(err, "failed to read snapshot")
+	}
+	if snapshotBytes == nil {
+		return nil, nil
+	}
+
+	var snapshot Snapshot
+	if err := unmarshalVersioned(snapshotPrefix, snapshotBytes, &snapshot); err != nil {
+		return nil, wrapError(err, "failed to unmarshal snapshot")
+	}
+	return &snapshot, nil
+}
+
+// 스냅샷 리프를 인덱스 순서대로 읽는 도우미 함수
+func getSnapshotLeaves(ctx contractapi.TransactionContextInterface, snapshotID string) ([]SnapshotLeaf, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(snapshotLeafPrefix, []string{snapshotID})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	leaves := []SnapshotLeaf{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		var leaf SnapshotLeaf
+		if err := unmarshalVersioned(snapshotLeafPrefix, queryResponse.Value, &leaf); err != nil {
+			return nil, wrapError(err, "failed to unmarshal snapshot leaf")
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves, nil
+}
+
+// 리프 키에 자리수를 맞춘 인덱스를 써서 범위 조회가 인덱스 순서가 되게 한다
+func putSnapshotLeaf(ctx contractapi.TransactionContextInterface, leaf *SnapshotLeaf) error {
+	leafKey, err := ctx.GetStub().CreateCompositeKey(snapshotLeafPrefix, []string{leaf.SnapshotID, fmt.Sprintf("%010d", leaf.Index)})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	leafBytes, err := json.Marshal(leaf)
+	if err != nil {
+		return wrapError(err, "failed to marshal snapshot leaf")
+	}
+	if err := ctx.GetStub().PutState(leafKey, leafBytes); err != nil {
+		return wrapError(err, "failed to put state for snapshot leaf")
+	}
+	return nil
+}
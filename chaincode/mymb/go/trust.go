@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TrustEdge truster 가 trustee 를 신뢰한다는 기록
+type TrustEdge struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Truster       string    `json:"truster"`
+	Trustee       string    `json:"trustee"`
+	TxID          string    `json:"txID"`
+	CreatedTime   time.Time `json:"createdTime"`
+}
+
+const (
+	// trust~truster~trustee 에 기록을, trustedBy~trustee~truster 에 역방향 인덱스를 저장한다
+	trustPrefix     = "trust"
+	trustedByPrefix = "trustedBy"
+)
+
+// TrustUser truster 가 trustee 를 신뢰하도록 기록하는 함수
+func (c *TokenERC1155Contract) TrustUser(ctx contractapi.TransactionContextInterface, truster string, trustee string) (*TrustEdge, error) {
+
+	err := newValidator().
+		fieldAs("nickName", "truster", truster).
+		fieldAs("nickName", "trustee", trustee).
+		check(truster != trustee, "trustee", "distinct", "must differ from truster").
+		err()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nickName := range []string{truster, trustee} {
+		user, err := c.GetUser(ctx, nickName)
+		if err != nil {
+			return nil, wrapError(err, "failed to get user information")
+		}
+		if user.UserId == "" {
+			return nil, newError(mymberr.UserNotFound, params("nickName", nickName), "user %s does not exist", nickName)
+		}
+	}
+
+	if err := checkNotFrozen(ctx, truster); err != nil {
+		return nil, err
+	}
+
+	trusted, err := isTrusted(ctx, truster, trustee)
+	if err != nil {
+		return nil, err
+	}
+	if trusted {
+		return nil, newError(mymberr.InvalidState, params("truster", truster, "trustee", trustee), "%s already trusts %s", truster, trustee)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	edge := TrustEdge{
+		SchemaVersion: trustEdgeSchemaVersion,
+		Truster:       truster,
+		Trustee:       trustee,
+		TxID:          ctx.GetStub().GetTxID(),
+		CreatedTime:   now,
+	}
+
+	trustKey, err := ctx.GetStub().CreateCompositeKey(trustPrefix, []string{truster, trustee})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	edgeBytes, err := json.Marshal(edge)
+	if err != nil {
+		return nil, wrapError(err, "failed to marshal trust edge")
+	}
+	if err := ctx.GetStub().PutState(trustKey, edgeBytes); err != nil {
+		return nil, wrapError(err, "failed to put state for trust edge")
+	}
+
+	trustedByKey, err := ctx.GetStub().CreateCompositeKey(trustedByPrefix, []string{trustee, truster})
+	if err != nil {
+		return nil, wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().PutState(trustedByKey, []byte{0x00}); err != nil {
+		return nil, wrapError(err, "failed to put state for trust index")
+	}
+
+	if err := ctx.GetStub().SetEvent("TrustUser", edgeBytes); err != nil {
+		return nil, wrapError(err, "failed to set event")
+	}
+
+	return &edge, nil
+}
+
+// UntrustUser truster 의 trustee 에 대한 신뢰를 철회하는 함수
+func (c *TokenERC1155Contract) UntrustUser(ctx contractapi.TransactionContextInterface, truster string, trustee string) error {
+
+	err := newValidator().
+		fieldAs("nickName", "truster", truster).
+		fieldAs("nickName", "trustee", trustee).
+		err()
+	if err != nil {
+		return err
+	}
+
+	if err := checkNotFrozen(ctx, truster); err != nil {
+		return err
+	}
+
+	trusted, err := isTrusted(ctx, truster, trustee)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		return newError(mymberr.NotTrusted, params("truster", truster, "trustee", trustee), "%s does not trust %s", truster, trustee)
+	}
+
+	trustKey, err := ctx.GetStub().CreateCompositeKey(trustPrefix, []string{truster, trustee})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(trustKey); err != nil {
+		return wrapError(err, "failed to delete trust edge")
+	}
+	trustedByKey, err := ctx.GetStub().CreateCompositeKey(trustedByPrefix, []string{trustee, truster})
+	if err != nil {
+		return wrapError(err, "failed to create composite key")
+	}
+	if err := ctx.GetStub().DelState(trustedByKey); err != nil {
+		return wrapError(err, "failed to delete trust index")
+	}
+
+	return nil
+}
+
+// GetTrustedUsers 유저가 신뢰하는 유저들을 조회하는 함수
+func (c *TokenERC1155Contract) GetTrustedUsers(ctx contractapi.TransactionContextInterface, nickName string) ([]string, error) {
+	return getTrustNeighbours(ctx, trustPrefix, nickName)
+}
+
+// GetTrustedByUsers 유저를 신뢰하는 유저들을 조회하는 함수
+func (c *TokenERC1155Contract) GetTrustedByUsers(ctx contractapi.TransactionContextInterface, nickName string) ([]string, error) {
+	return getTrustNeighbours(ctx, trustedByPrefix, nickName)
+}
+
+// GetMutualTrusts 유저와 서로 신뢰하는 유저들을 조회하는 함수
+func (c *TokenERC1155Contract) GetMutualTrusts(ctx contractapi.TransactionContextInterface, nickName string) ([]string, error) {
+
+	trusted, err := getTrustNeighbours(ctx, trustPrefix, nickName)
+	if err != nil {
+		return nil, err
+	}
+	trustedBy, err := getTrustNeighbours(ctx, trustedByPrefix, nickName)
+	if err != nil {
+		return nil, err
+	}
+
+	mutual := []string{}
+	for _, other := range trusted {
+		if contains(trustedBy, other) {
+			mutual = append(mutual, other)
+		}
+	}
+	return mutual, nil
+}
+
+// IsTrusted truster 가 trustee 를 신뢰하는지 조회하는 함수
+func (c *TokenERC1155Contract) IsTrusted(ctx contractapi.TransactionContextInterface, truster string, trustee string) (bool, error) {
+	return isTrusted(ctx, truster, trustee)
+}
+
+// IsMutualTrust 두 유저가 서로 신뢰하는지 조회하는 함수
+func (c *TokenERC1155Contract) IsMutualTrust(ctx contractapi.TransactionContextInterface, nickName string, other string) (bool, error) {
+
+	trusted, err := isTrusted(ctx, nickName, other)
+	if err != nil || !trusted {
+		return false, err
+	}
+	return isTrusted(ctx, other, nickName)
+}
+
+// GiftToken 신뢰하는 유저에게 토큰을 선물하는 함수, from 이 to 를 신뢰해야 하며 그 외에는 TransferToken 과 같다
+func (c *TokenERC1155Contract) GiftToken(ctx contractapi.TransactionContextInterface, from string, to string, tokenNumber string) error {
+
+	if err := checkTrusted(ctx, from, to); err != nil {
+		return err
+	}
+	return c.TransferToken(ctx, from, to, tokenNumber)
+}
+
+// truster 가 trustee 를 신뢰하는지 확인하는 도우미 함수
+func isTrusted(ctx contractapi.TransactionContextInterface, truster string, trustee string) (bool, error) {
+	trustKey, err := ctx.GetStub().CreateCompositeKey(trustPrefix, []string{truster, trustee})
+	if err != nil {
+		return false, wrapError(err, "failed to create composite key")
+	}
+	edgeBytes, err := ctx.GetStub().GetState(trustKey)
+	if err != nil {
+		return false, wrapError(err, "failed to read trust edge")
+	}
+	return edgeBytes != nil, nil
+}
+
+// 다른 기능의 전제 조건으로 신뢰 관계를 요구할 때 쓰는 도우미 함수
+func checkTrusted(ctx contractapi.TransactionContextInterface, truster string, trustee string) error {
+	trusted, err := isTrusted(ctx, truster, trustee)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		return newError(mymberr.NotTrusted, params("truster", truster, "trustee", trustee), "%s does not trust %s", truster, trustee)
+	}
+	return nil
+}
+
+// 신뢰 관계 키의 두 번째 속성(상대 유저)을 모으는 도우미 함수
+func getTrustNeighbours(ctx contractapi.TransactionContextInterface, prefix string, nickName string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, []string{nickName})
+	if err != nil {
+		return nil, wrapError(err, "failed to get state by partial composite key")
+	}
+	defer resultsIterator.Close()
+
+	neighbours := []string{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, wrapError(err, "failed to get next query response")
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, wrapError(err, "failed to split composite key")
+		}
+		neighbours = append(neighbours, attributes[1])
+	}
+
+	return neighbours, nil
+}
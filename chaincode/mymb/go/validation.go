@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/MYMB2022/mymb-bc-chaincode/chaincode/mymb/go/mymberr"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// fieldRule 인자 종류별 검증 규칙
+type fieldRule struct {
+	required bool
+	minLen   int
+	maxLen   int
+	pattern  *regexp.Regexp
+	format   string
+	schemes  []string
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	codePattern       = regexp.MustCompile(`^[A-Z0-9_]+$`)
+	nickNamePattern   = regexp.MustCompile(`^[\p{L}\p{N}_.-]+$`)
+	functionPattern   = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	sha256Pattern     = regexp.MustCompile(`^[0-9a-f]+$`)
+	phonePattern      = regexp.MustCompile(`^\+?[0-9-]+$`)
+	digitsPattern     = regexp.MustCompile(`^[0-9-]+$`)
+	mspIDPattern      = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	channelPattern    = regexp.MustCompile(`^[a-z][a-z0-9.-]*$`)
+	base64Pattern     = regexp.MustCompile(`^[A-Za-z0-9+/=]+$`)
+	// RecordFundingPayment 가 만드는 단계별 페이백 payId 의 접미사
+	levelPayIDPattern = regexp.MustCompile(`-L[0-9]+$`)
+)
+
+// 인자 이름별로 선언된 검증 규칙
+var fieldRules = map[string]fieldRule{
+	"tokenNumber":     {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"nickName":        {required: true, minLen: 2, maxLen: 30, pattern: nickNamePattern, format: "letters, digits, '_', '.' or '-'"},
+	"userID":          {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"categoryCode":    {required: true, maxLen: 32, pattern: codePattern, format: "upper-case letters, digits or '_'"},
+	"tokenType":       {required: true, maxLen: 32, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"fundingID":       {maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"ticketID":        {maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"sellStage":       {maxLen: 32, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"imageURL":        {maxLen: 2048, schemes: []string{"https", "ipfs"}},
+	"auctionID":       {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"txID":            {required: true, maxLen: 128, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"scopeID":         {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"function":        {required: true, maxLen: 64, pattern: functionPattern, format: "an exported contract function name"},
+	"reason":          {required: true, maxLen: 512},
+	"actor":           {required: true, maxLen: 128},
+	"bookmark":        {maxLen: 256},
+	"name":            {required: true, maxLen: 128},
+	"description":     {maxLen: 2048},
+	"imageHash":       {required: true, minLen: 64, maxLen: 64, pattern: sha256Pattern, format: "lower-case hex digits"},
+	"traitType":       {required: true, maxLen: 64},
+	"traitValue":      {maxLen: 256},
+	"uriTemplate":     {required: true, maxLen: 2048, schemes: []string{"https", "ipfs"}},
+	"displayName":     {required: true, maxLen: 64},
+	"campaignID":      {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"snapshotID":      {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"payID":           {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"payoutReference": {required: true, maxLen: 128},
+	"issuer":          {required: true, maxLen: 128},
+	"realName":        {required: true, maxLen: 128},
+	"phoneNumber":     {required: true, maxLen: 20, pattern: phonePattern, format: "digits, '-' or a leading '+'"},
+	"bankCode":        {required: true, maxLen: 16, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"bankAccount":     {required: true, maxLen: 32, pattern: digitsPattern, format: "digits or '-'"},
+	"salt":            {required: true, minLen: 32, maxLen: 128},
+	"enterpriseID":    {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"poolID":          {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"proposalID":      {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"mspID":           {required: true, maxLen: 64, pattern: mspIDPattern, format: "letters, digits, '_', '.' or '-'"},
+	"chaincodeName":   {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"channelName":     {maxLen: 64, pattern: channelPattern, format: "lower-case letters, digits, '.' or '-'"},
+	"accountID":       {required: true, maxLen: 1024, pattern: base64Pattern, format: "base64 characters"},
+	"purchaseID":      {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+	"requestID":       {required: true, maxLen: 64, pattern: identifierPattern, format: "letters, digits, '_' or '-'"},
+}
+
+// validator 인자들을 검증하고 오류를 모아 한 번에 반환한다
+type validator struct {
+	errs []mymberr.FieldError
+}
+
+func newValidator() *validator {
+	return &validator{}
+}
+
+// field 인자 이름에 선언된 규칙으로 값을 검증한다
+func (v *validator) field(name string, value string) *validator {
+	return v.fieldAs(name, name, value)
+}
+
+// fieldAs rule 에 선언된 규칙으로 field 값을 검증한다 (예: ownedToken[0] 을 tokenNumber 규칙으로)
+func (v *validator) fieldAs(rule string, field string, value string) *validator {
+	r, ok := fieldRules[rule]
+	if !ok {
+		panic(fmt.Sprintf("no validation rule declared for %s", rule))
+	}
+
+	if value == "" {
+		if r.required {
+			v.add(field, "required", "must not be empty")
+		}
+		return v
+	}
+
+	length := utf8.RuneCountInString(value)
+	if r.minLen > 0 && length < r.minLen {
+		v.add(field, "minLength", fmt.Sprintf("must be at least %d characters", r.minLen))
+	}
+	if r.maxLen > 0 && length > r.maxLen {
+		v.add(field, "maxLength", fmt.Sprintf("must be at most %d characters", r.maxLen))
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		v.add(field, "format", "must contain only "+r.format)
+	}
+	if len(r.schemes) > 0 {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" || !contains(r.schemes, parsed.Scheme) {
+			v.add(field, "urlScheme", fmt.Sprintf("must be an absolute URL with scheme %v", r.schemes))
+		}
+	}
+	return v
+}
+
+// list 목록의 각 항목을 rule 규칙으로 검증하고 중복을 확인한다
+func (v *validator) list(rule string, field string, values []string) *validator {
+	seen := map[string]bool{}
+	for i, value := range values {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		v.fieldAs(rule, name, value)
+		if seen[value] {
+			v.add(name, "unique", fmt.Sprintf("duplicate value %s", value))
+		}
+		seen[value] = true
+	}
+	return v
+}
+
+// nonNegative 값이 음수가 아닌지 검증한다
+func (v *validator) nonNegative(field string, value int64) *validator {
+	if value < 0 {
+		v.add(field, "min", "must not be negative")
+	}
+	return v
+}
+
+// positive 값이 0 보다 큰지 검증한다
+func (v *validator) positive(field string, value int64) *validator {
+	if value <= 0 {
+		v.add(field, "min", "must be greater than zero")
+	}
+	return v
+}
+
+// oneOf 값이 허용된 값 중 하나인지 검증한다
+func (v *validator) oneOf(field string, value string, allowed ...string) *validator {
+	if !contains(allowed, value) {
+		v.add(field, "oneOf", fmt.Sprintf("must be one of %v", allowed))
+	}
+	return v
+}
+
+// check 조건이 거짓이면 오류를 추가한다
+func (v *validator) check(ok bool, field string, rule string, message string) *validator {
+	if !ok {
+		v.add(field, rule, message)
+	}
+	return v
+}
+
+func (v *validator) add(field string, rule string, message string) {
+	v.errs = append(v.errs, mymberr.FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// err 모인 오류가 있으면 필드 목록을 담은 INVALID_ARGUMENT 에러를 반환한다
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &mymberr.Error{Code: mymberr.InvalidArgument, Message: "invalid arguments", Fields: v.errs}
+}
+
+// 참조하는 토큰들이 모두 존재하는지 확인하는 도우미 함수
+func checkTokensExist(ctx contractapi.TransactionContextInterface, field string, tokenNumbers []string) error {
+	v := newValidator()
+	for i, tokenNumber := range tokenNumbers {
+		tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{tokenNumber})
+		if err != nil {
+			return wrapError(err, "failed to create composite key")
+		}
+		tokenBytes, err := ctx.GetStub().GetState(tokenKey)
+		if err != nil {
+			return wrapError(err, "failed to get state")
+		}
+		v.check(tokenBytes != nil, fmt.Sprintf("%s[%d]", field, i), "exists", fmt.Sprintf("token %s does not exist", tokenNumber))
+	}
+	return v.err()
+}